@@ -96,12 +96,12 @@ var RootCmd = &cobra.Command{
 						URL: URL,
 					}
 				}
-				html, err := svc.Fetch(req)
+				resp, err := svc.Fetch(cx, req)
 				if err != nil {
 					fmt.Fprintf(os.Stderr, "error: %v\n", err)
 					os.Exit(1)
 				}
-				b, err := ioutil.ReadAll(html)
+				b, err := ioutil.ReadAll(resp.Body)
 				fmt.Println(string(b))
 				select {
 				case <-cx.Done():
@@ -48,6 +48,11 @@ var (
 	fetchDelay          int
 	randomizeFetchDelay bool
 	ignoreFetchDelay    bool
+	retryDelay          int
+	hostConcurrency     int
+	maxTasks            int
+	userAgents          []string
+	sinks               []string
 )
 
 // RootCmd represents the base command when called without any subcommands
@@ -128,6 +133,11 @@ func init() {
 	RootCmd.Flags().IntVarP(&fetchDelay, "FETCH_DELAY", "", 500, "Specifies sleep time in milliseconds for multiple requests for the same domain.")
 	RootCmd.Flags().BoolVarP(&randomizeFetchDelay, "RANDOMIZE_FETCH_DELAY", "", true, "RandomizeFetchDelay setting decreases the chance of a crawler being blocked. This way a random delay ranging from 0.5 * FetchDelay to 1.5 * FetchDelay seconds is used between consecutive requests to the same domain. If FetchDelay is zero this option has no effect.")
 	RootCmd.Flags().BoolVarP(&ignoreFetchDelay, "IGNORE_FETCH_DELAY", "", false, "Ignores fetchDelay setting intended for debug purpose. Please set it to false in Production")
+	RootCmd.Flags().IntVarP(&hostConcurrency, "HOST_CONCURRENCY", "", 2, "The maximum number of simultaneous requests to the same host.")
+	RootCmd.Flags().IntVarP(&maxTasks, "MAX_TASKS", "", 4, "The maximum number of background tasks parsed at the same time. Other tasks wait in queued status.")
+	RootCmd.Flags().StringSliceVar(&userAgents, "USER_AGENTS", nil, "Pool of User-Agent strings rotated per request. DataflowKitBot is sent if it is empty.")
+	RootCmd.Flags().StringSliceVar(&sinks, "SINKS", nil, "Output sinks available to payloads as name=type:dsn, f.e. results=sqlite:/data/results.db. Types: sqlite, postgres")
+	RootCmd.Flags().IntVarP(&retryDelay, "RETRY_DELAY", "", 1000, "Specifies delay in milliseconds before the first retry of failed fetches. It is doubled for every next retry.")
 
 	//viper.AutomaticEnv() // read in environment variables that match
 
@@ -163,5 +173,10 @@ func init() {
 	viper.BindPFlag("FETCH_DELAY", RootCmd.Flags().Lookup("FETCH_DELAY"))
 	viper.BindPFlag("RANDOMIZE_FETCH_DELAY", RootCmd.Flags().Lookup("RANDOMIZE_FETCH_DELAY"))
 	viper.BindPFlag("IGNORE_FETCH_DELAY", RootCmd.Flags().Lookup("IGNORE_FETCH_DELAY"))
+	viper.BindPFlag("HOST_CONCURRENCY", RootCmd.Flags().Lookup("HOST_CONCURRENCY"))
+	viper.BindPFlag("MAX_TASKS", RootCmd.Flags().Lookup("MAX_TASKS"))
+	viper.BindPFlag("RETRY_DELAY", RootCmd.Flags().Lookup("RETRY_DELAY"))
+	viper.BindPFlag("USER_AGENTS", RootCmd.Flags().Lookup("USER_AGENTS"))
+	viper.BindPFlag("SINKS", RootCmd.Flags().Lookup("SINKS"))
 
 }
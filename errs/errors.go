@@ -8,6 +8,11 @@
 
 package errs
 
+import (
+	"net/http"
+	"strconv"
+)
+
 // BadRequest 400 The server cannot or will not process the request due to an apparent client error (e.g., malformed request syntax, size too large, invalid request message framing, or deceptive request routing).
 type BadRequest struct {
 	Err error
@@ -63,6 +68,17 @@ func (e *NotFound) Error() string {
 	return "404 Not found: " + e.URL
 }
 
+// Conflict 409
+//
+// Request conflicts with the current state of the resource, f.e. results of the task are requested before it is finished.
+type Conflict struct {
+	Err string
+}
+
+func (e *Conflict) Error() string {
+	return "409 Conflict: " + e.Err
+}
+
 // InternalServerError 500
 // A generic error message, given when an unexpected condition was encountered and no more specific message is suitable
 type InternalServerError struct {
@@ -87,12 +103,42 @@ func (e *BadGateway) Error() string {
 //
 // This error response is given when the server is acting as a gateway and cannot get a response in time.
 type GatewayTimeout struct {
+	//Wait is a condition of the rendered page which is not met in time
+	Wait string
 }
 
 func (e *GatewayTimeout) Error() string {
+	if e.Wait != "" {
+		return "504 Timeout exceeded waiting for " + e.Wait
+	}
 	return "504 Timeout exceeded rendering page"
 }
 
+// StatusError is returned if remote server responded with HTTP status code which has no specific error type.
+type StatusError struct {
+	StatusCode int
+	Err        string
+	//Internal is true if the status is returned by fetch service itself rather than by remote server
+	Internal bool
+}
+
+func (e *StatusError) Error() string {
+	if e.Err != "" {
+		return e.Err
+	}
+	return strconv.Itoa(e.StatusCode) + " " + http.StatusText(e.StatusCode)
+}
+
+// NetworkError is returned if web page cannot be downloaded because of network failure like connection refused, reset or timed out.
+type NetworkError struct {
+	URL string
+	Err error
+}
+
+func (e *NetworkError) Error() string {
+	return "Network error: " + e.URL + ": " + e.Err.Error()
+}
+
 //Parser Errors generated by Dataflow kit Parser service
 //
 //ParserError returned if web page cannot be parsed correctly due to wrong payload structure
@@ -115,6 +161,15 @@ func (e *BadPayload) Error() string {
 	return "400: " + string(e.ParserError)
 }
 
+// Canceled is returned if a task is cancelled by client or its deadline is exceeded before all the pages are scraped.
+type Canceled struct {
+	Err error
+}
+
+func (e *Canceled) Error() string {
+	return "Scraping stopped: " + e.Err.Error()
+}
+
 // ErrStorageResult represent storage results reader errors
 type ErrStorageResult struct {
 	Err string
@@ -43,9 +43,20 @@
 //
 // The return type of the extractor is a list of string matches (i.e. []string).
 //
+// - Number parses the first number in the text of each part. Decimal and thousands separators depend on Locale, f.e. "1.299,50" is 1299.5 for "de".
+//
+// - Price parses amount and ISO 4217 currency code of each part, f.e. "$1,299.00" is {"amount":1299, "currency":"USD"}.
+//
+// - Date parses text or datetime attribute of each part by the given layouts or as a relative date like "3 days ago" and returns RFC3339 timestamps.
+//
+// - Boolean returns true for parts with text matching Truthy pattern like "yes" or "in stock" and false otherwise.
+//
+// - Table turns rows of HTML tables into records keyed by header texts or by mapped column names. colspan and rowspan are expanded.
+//
 //Filters
 //
-//Filters are used to manipulate text data when extracting.
+//Filters are used to manipulate extracted data. They are applied in order to results of any extractor. Text filters are applied to every string of list results.
+//Filters with arguments are specified like "replace('\s+', ' ')". Quoted arguments may contain commas. Backslash escapes quotes inside them.
 //
 //The following filters are available:
 //
@@ -57,9 +68,23 @@
 //
 //- trim returns a copy of the Extractor's text/ Attr, with all leading and trailing white space removed
 //
-//Filters are available for Text, Link and Image extractor types.
+//- normalizeWhitespace replaces sequences of white space with a single space and trims the text.
+//
+//- stripHTML removes HTML tags, htmlUnescape converts entities like "&amp;" to characters, urlDecode decodes URL encoded text.
+//
+//- replace(regex, repl) replaces matches of the regular expression. repl may refer to submatches like "$1".
+//
+//- substring(start, length) returns characters starting at position start (counted from 0). The rest of the text is returned if length is omitted.
+//
+//- truncate(n, ellipsis) cuts the text to n characters and appends optional ellipsis to truncated texts.
+//
+//- prefix(s) and suffix(s) add s to the beginning or to the end of the text.
+//
+//- split(sep) turns the text into a list. join(sep) turns a list into the text.
+//
+//- default(value) replaces empty results with value.
 //
-//Image alt attribute, Link Text and Text are influenced by specified filters.
+//Invalid filters are rejected when payload is parsed.
 package extract
 
 // EOF
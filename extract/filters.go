@@ -1,28 +1,331 @@
 package extract
 
 import (
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
 	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
 )
 
-func filterText(data string, filters []string) string {
-	for _, filter := range filters {
-		switch strings.ToLower(filter) {
-		case "trim":
-			data = strings.TrimSpace(data)
-		case "lowercase":
-			data = strings.ToLower(data)
-		case "uppercase":
-			data = strings.ToUpper(data)
-		case "capitalize":
-			data = strings.Title(data)
+// Filter transforms values returned by extractors. Filters are specified as strings like "trim" or "replace('\s+', ' ')".
+// Text filters are applied to every string of the list results. Other values are kept as they are.
+type Filter struct {
+	// Name of the filter in lower case.
+	Name string
+	// Args are arguments of the filter.
+	Args  []string
+	apply func(value interface{}) interface{}
+}
+
+type filterDef struct {
+	//min and max number of arguments
+	min, max int
+	new      func(args []string) (func(value interface{}) interface{}, error)
+}
+
+// filterDefs is the registry of available filters. Names are case insensitive.
+var filterDefs = map[string]filterDef{
+	"trim":       {0, 0, textFilter(strings.TrimSpace)},
+	"lowercase":  {0, 0, textFilter(strings.ToLower)},
+	"uppercase":  {0, 0, textFilter(strings.ToUpper)},
+	"capitalize": {0, 0, textFilter(strings.Title)},
+	"normalizewhitespace": {0, 0, textFilter(func(s string) string {
+		return strings.Join(strings.Fields(s), " ")
+	})},
+	"striphtml":    {0, 0, textFilter(stripHTML)},
+	"htmlunescape": {0, 0, textFilter(html.UnescapeString)},
+	"urldecode": {0, 0, textFilter(func(s string) string {
+		if d, err := url.QueryUnescape(s); err == nil {
+			return d
+		}
+		return s
+	})},
+	"replace": {2, 2, func(args []string) (func(value interface{}) interface{}, error) {
+		re, err := regexp.Compile(args[0])
+		if err != nil {
+			return nil, err
+		}
+		return textFilter(func(s string) string { return re.ReplaceAllString(s, args[1]) })(nil)
+	}},
+	"substring": {1, 2, func(args []string) (func(value interface{}) interface{}, error) {
+		n, err := intArgs(args)
+		if err != nil {
+			return nil, err
+		}
+		return textFilter(func(s string) string {
+			r := []rune(s)
+			start := clamp(n[0], len(r))
+			end := len(r)
+			if len(n) == 2 {
+				end = clamp(start+n[1], len(r))
+			}
+			return string(r[start:end])
+		})(nil)
+	}},
+	"truncate": {1, 2, func(args []string) (func(value interface{}) interface{}, error) {
+		n, err := intArgs(args[:1])
+		if err != nil {
+			return nil, err
 		}
+		ellipsis := ""
+		if len(args) == 2 {
+			ellipsis = args[1]
+		}
+		return textFilter(func(s string) string {
+			if utf8.RuneCountInString(s) <= n[0] {
+				return s
+			}
+			return string([]rune(s)[:n[0]]) + ellipsis
+		})(nil)
+	}},
+	"prefix": {1, 1, func(args []string) (func(value interface{}) interface{}, error) {
+		return textFilter(func(s string) string { return args[0] + s })(nil)
+	}},
+	"suffix": {1, 1, func(args []string) (func(value interface{}) interface{}, error) {
+		return textFilter(func(s string) string { return s + args[0] })(nil)
+	}},
+	"split": {1, 1, func(args []string) (func(value interface{}) interface{}, error) {
+		return mapStrings(func(s string) []string { return strings.Split(s, args[0]) }), nil
+	}},
+	"join": {1, 1, func(args []string) (func(value interface{}) interface{}, error) {
+		return func(value interface{}) interface{} {
+			items, ok := listItems(value)
+			if !ok {
+				return value
+			}
+			s := make([]string, len(items))
+			for i, item := range items {
+				s[i] = toString(item)
+			}
+			return strings.Join(s, args[0])
+		}, nil
+	}},
+	"default": {1, 1, func(args []string) (func(value interface{}) interface{}, error) {
+		return func(value interface{}) interface{} {
+			if items, ok := listItems(value); value == nil || value == "" || ok && len(items) == 0 {
+				return args[0]
+			}
+			return value
+		}, nil
+	}},
+}
+
+// textFilter returns constructor of the filter which transforms every string of the value with fn.
+func textFilter(fn func(string) string) func(args []string) (func(value interface{}) interface{}, error) {
+	return func(args []string) (func(value interface{}) interface{}, error) {
+		return mapStrings(func(s string) []string { return []string{fn(s)} }), nil
 	}
-	return data
 }
 
-func filterTextMW(data string, filters ...func(data string) string) string {
-	for _, filter := range filters {
-		data = filter(data)
+// mapStrings returns function which replaces strings of the value with results of fn. Single string is returned if fn returns one result for a single string.
+func mapStrings(fn func(string) []string) func(value interface{}) interface{} {
+	return func(value interface{}) interface{} {
+		if s, ok := value.(string); ok {
+			r := fn(s)
+			if len(r) == 1 {
+				return r[0]
+			}
+			return r
+		}
+		items, ok := listItems(value)
+		if !ok {
+			return value
+		}
+		result := []interface{}{}
+		strs := []string{}
+		for _, item := range items {
+			s, ok := item.(string)
+			if !ok {
+				result = append(result, item)
+				strs = nil
+				continue
+			}
+			for _, r := range fn(s) {
+				result = append(result, r)
+				if strs != nil {
+					strs = append(strs, r)
+				}
+			}
+		}
+		//lists of strings keep their type, so links may be followed by details
+		if strs != nil {
+			return strs
+		}
+		return result
+	}
+}
+
+// listItems returns elements of the value if it is a slice.
+func listItems(value interface{}) ([]interface{}, bool) {
+	if items, ok := value.([]interface{}); ok {
+		return items, true
+	}
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Slice {
+		return nil, false
+	}
+	items := make([]interface{}, v.Len())
+	for i := range items {
+		items[i] = v.Index(i).Interface()
+	}
+	return items, true
+}
+
+func toString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return fmt.Sprint(value)
+}
+
+func intArgs(args []string) ([]int, error) {
+	n := make([]int, len(args))
+	for i, a := range args {
+		v, err := strconv.Atoi(a)
+		if err != nil || v < 0 {
+			return nil, fmt.Errorf("%s is not a non-negative integer", a)
+		}
+		n[i] = v
+	}
+	return n, nil
+}
+
+func clamp(n, max int) int {
+	if n > max {
+		return max
+	}
+	return n
+}
+
+// stripHTML removes tags from s and unescapes entities.
+func stripHTML(s string) string {
+	var b strings.Builder
+	z := html.NewTokenizer(strings.NewReader(s))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return b.String()
+		case html.TextToken:
+			b.Write(z.Text())
+		}
+	}
+}
+
+// ParseFilter parses filter specification like "truncate(100, '...')". Arguments are separated by commas. Quoted arguments may contain commas and parentheses.
+func ParseFilter(spec string) (Filter, error) {
+	spec = strings.TrimSpace(spec)
+	name, rest := spec, ""
+	if i := strings.Index(spec, "("); i >= 0 {
+		if !strings.HasSuffix(spec, ")") {
+			return Filter{}, fmt.Errorf("Invalid filter %s. Missing closing parenthesis", spec)
+		}
+		name, rest = strings.TrimSpace(spec[:i]), spec[i+1:len(spec)-1]
+	}
+	def, ok := filterDefs[strings.ToLower(name)]
+	if !ok {
+		return Filter{}, fmt.Errorf("Unknown filter %s", name)
+	}
+	args, err := filterArgs(rest)
+	if err != nil {
+		return Filter{}, fmt.Errorf("Invalid filter %s. %s", spec, err.Error())
+	}
+	if len(args) < def.min || len(args) > def.max {
+		return Filter{}, fmt.Errorf("Invalid filter %s. %d to %d arguments expected", spec, def.min, def.max)
+	}
+	apply, err := def.new(args)
+	if err != nil {
+		return Filter{}, fmt.Errorf("Invalid filter %s. %s", spec, err.Error())
+	}
+	return Filter{Name: strings.ToLower(name), Args: args, apply: apply}, nil
+}
+
+// filterArgs splits arguments of the filter. Quotes of quoted arguments are removed. Backslash escapes quotes and backslash, other backslashes are kept as is for regular expressions.
+func filterArgs(s string) ([]string, error) {
+	args := []string{}
+	if strings.TrimSpace(s) == "" {
+		return args, nil
+	}
+	for {
+		s = strings.TrimLeft(s, " \t")
+		var arg string
+		if s != "" && (s[0] == '\'' || s[0] == '"') {
+			quote := s[0]
+			var b strings.Builder
+			i := 1
+			for ; i < len(s) && s[i] != quote; i++ {
+				if s[i] == '\\' && i+1 < len(s) && (s[i+1] == quote || s[i+1] == '\\') {
+					i++
+				}
+				b.WriteByte(s[i])
+			}
+			if i == len(s) {
+				return nil, errors.New("Unterminated string")
+			}
+			arg, s = b.String(), strings.TrimLeft(s[i+1:], " \t")
+			if s != "" && s[0] != ',' {
+				return nil, errors.New("Comma expected after " + string(quote) + arg + string(quote))
+			}
+		} else {
+			i := strings.Index(s, ",")
+			if i < 0 {
+				i = len(s)
+			}
+			arg, s = strings.TrimSpace(s[:i]), s[i:]
+		}
+		args = append(args, arg)
+		if s == "" {
+			return args, nil
+		}
+		//skip comma
+		s = s[1:]
+	}
+}
+
+// ParseFilters parses filter specifications.
+func ParseFilters(specs []string) ([]Filter, error) {
+	filters := []Filter{}
+	for _, spec := range specs {
+		f, err := ParseFilter(spec)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, f)
+	}
+	return filters, nil
+}
+
+// Apply returns filtered value.
+func (f Filter) Apply(value interface{}) interface{} {
+	return f.apply(value)
+}
+
+// ApplyFilters applies filters to the value in order.
+func ApplyFilters(value interface{}, filters []Filter) interface{} {
+	for _, f := range filters {
+		value = f.Apply(value)
+	}
+	return value
+}
+
+// filterText applies text filters to data. Invalid filters and filters which don't return a string are skipped.
+func filterText(data string, filters []string) string {
+	for _, spec := range filters {
+		f, err := ParseFilter(spec)
+		if err != nil {
+			continue
+		}
+		if s, ok := f.Apply(data).(string); ok {
+			data = s
+		}
 	}
 	return data
 }
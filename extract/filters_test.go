@@ -1,7 +1,6 @@
 package extract
 
 import (
-	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -48,8 +47,49 @@ func Test_filterText(t *testing.T) {
 	}
 }
 
-func Test_filterTextMW(t *testing.T) {
-	filtered := filterTextMW("\n\t Test ChAinEd fILterS \n\t\t", strings.TrimSpace, strings.ToLower)
-	assert.Equal(t, "test chained filters", filtered)
+func TestParseFilter(t *testing.T) {
+	for spec, args := range map[string][]string{
+		"trim":                         {},
+		" Replace( '\\s+' , ' ' ) ": {`\s+`, " "},
+		`prefix("a, \"b\")")`:         {`a, "b")`},
+		"substring(1, 3)":              {"1", "3"},
+		"default('')":                  {""},
+		"truncate(10,...)":             {"10", "..."},
+	} {
+		f, err := ParseFilter(spec)
+		assert.NoError(t, err, spec)
+		assert.Equal(t, args, f.Args, spec)
+	}
+	for _, spec := range []string{"unknown", "trim(1)", "replace('(', '')", "substring(a)", "truncate(-1)",
+		"prefix('a'", "prefix('a)", "join(',' ';')", "split", "default()", "default(1, 2)"} {
+		_, err := ParseFilter(spec)
+		assert.Error(t, err, spec)
+	}
+}
 
+func TestApplyFilters(t *testing.T) {
+	apply := func(value interface{}, specs ...string) interface{} {
+		filters, err := ParseFilters(specs)
+		assert.NoError(t, err)
+		return ApplyFilters(value, filters)
+	}
+	assert.Equal(t, "Price: 12", apply("  <b>Price:</b>\n 12&nbsp;", "stripHTML", "normalizeWhitespace"))
+	assert.Equal(t, "12.50", apply("$12.50 USD", `replace("[^\d.]", "")`))
+	assert.Equal(t, "Dr Who", apply("Who, Dr", `replace('(\w+), (\w+)', '$2 $1')`))
+	assert.Equal(t, "234", apply("12345", "substring(1, 3)"))
+	assert.Equal(t, "45", apply("12345", "substring(3)"))
+	assert.Equal(t, "", apply("12345", "substring(7)"))
+	assert.Equal(t, "Лев…", apply("Лев Толстой", "truncate(3, '…')"))
+	assert.Equal(t, "short", apply("short", "truncate(10)"))
+	assert.Equal(t, []string{"red", "green", "blue"}, apply("red, green ,blue", "split(',')", "trim"))
+	assert.Equal(t, []string{"a", "b", "c"}, apply([]string{"a;b", "c"}, "split(';')"))
+	assert.Equal(t, "a|b|c", apply("a b c", "split(' ')", "join('|')"))
+	assert.Equal(t, "1.5;2", apply([]float64{1.5, 2}, "join(;)"))
+	assert.Equal(t, "n/a", apply(nil, "default('n/a')"))
+	assert.Equal(t, "n/a", apply([]string{}, "default('n/a')"))
+	assert.Equal(t, "x", apply("x", "default('n/a')"))
+	assert.Equal(t, "http://example.com/a b", apply("/a+b", "urlDecode", "prefix('http://example.com')"))
+	assert.Equal(t, "<p>Tom & Jerry</p>!", apply("&lt;p&gt;Tom &amp; Jerry&lt;/p&gt;", "htmlUnescape", "suffix(!)"))
+	assert.Equal(t, []interface{}{"A", 1.0}, apply([]interface{}{"a", 1.0}, "uppercase"))
+	assert.Equal(t, 12.5, apply(12.5, "uppercase"))
 }
@@ -0,0 +1,240 @@
+package extract
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// TableColumn maps a column of the table to a key of records. Column is found by header text or by index counted from 0 if Header is empty.
+type TableColumn struct {
+	Header string `json:"header"`
+	Index  int    `json:"index"`
+	// Name is a key of the column values in records. Header is used if it is empty.
+	Name string `json:"name"`
+}
+
+// Key returns the key of the column values in records.
+func (c TableColumn) Key() string {
+	switch {
+	case c.Name != "":
+		return c.Name
+	case c.Header != "":
+		return c.Header
+	}
+	return fmt.Sprintf("Column %d", c.Index+1)
+}
+
+// Table is an Extractor that turns rows of HTML tables in the selection into records.
+// Header is read from <thead> or from the first row of the table. Cells spanning several columns or rows with colspan and rowspan are repeated in every cell they cover.
+// Records are keyed by header texts. Columns without header are named "Column N". Nested tables are not parsed.
+// The return type of the extractor is a list of records (i.e. []map[string]interface{}).
+type Table struct {
+	// Columns select and rename columns of the records. All columns are returned if it is empty.
+	Columns []TableColumn
+	// If no rows are found, then return the empty list from Extract, instead of 'nil'.
+	IncludeIfEmpty bool
+}
+
+// Extract returns records of the tables from specified selection. Tables are either selected elements or the first table inside each of them.
+func (e Table) Extract(sel *goquery.Selection) (interface{}, error) {
+	records := []map[string]interface{}{}
+	sel.Each(func(i int, s *goquery.Selection) {
+		if goquery.NodeName(s) != "table" {
+			s = s.Find("table").First()
+		}
+		if s.Length() > 0 {
+			records = append(records, e.records(s)...)
+		}
+	})
+	if len(records) == 0 && !e.IncludeIfEmpty {
+		return nil, nil
+	}
+	return records, nil
+}
+
+var _ Extractor = Table{}
+
+// records converts rows of the table to records.
+func (e Table) records(table *goquery.Selection) []map[string]interface{} {
+	headRows, bodyRows := tableRows(table)
+	grid := tableGrid(append(headRows, bodyRows...))
+	head, body := grid[:len(headRows)], grid[len(headRows):]
+	//the first row is the header of tables without <thead>
+	if len(head) == 0 && len(body) > 0 {
+		head, body = body[:1], body[1:]
+	}
+	width := 0
+	for _, row := range grid {
+		if len(row) > width {
+			width = len(row)
+		}
+	}
+	header := tableHeader(head, width)
+	columns := e.columns(header)
+	records := []map[string]interface{}{}
+	for _, row := range body {
+		record := map[string]interface{}{}
+		empty := true
+		for _, c := range columns {
+			value := ""
+			if c.Index < len(row) {
+				value = row[c.Index]
+			}
+			empty = empty && value == ""
+			record[c.Name] = value
+		}
+		if !empty {
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+// columns returns indexes and names of output columns.
+func (e Table) columns(header []string) []TableColumn {
+	if len(e.Columns) == 0 {
+		columns := make([]TableColumn, len(header))
+		for i, h := range header {
+			columns[i] = TableColumn{Header: h, Index: i, Name: h}
+		}
+		return columns
+	}
+	columns := []TableColumn{}
+	for _, c := range e.Columns {
+		if c.Header != "" {
+			c.Index = -1
+			for i, h := range header {
+				if strings.EqualFold(h, strings.TrimSpace(c.Header)) {
+					c.Index = i
+					break
+				}
+			}
+			//missing columns are skipped
+			if c.Index < 0 {
+				continue
+			}
+		}
+		c.Name = c.Key()
+		columns = append(columns, c)
+	}
+	return columns
+}
+
+// tableRows returns header and body rows of the table. Rows of nested tables are skipped.
+func tableRows(table *goquery.Selection) (head, body []*html.Node) {
+	for c := table.Nodes[0].FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode {
+			continue
+		}
+		switch c.Data {
+		case "thead":
+			head = append(head, sectionRows(c)...)
+		case "tbody", "tfoot":
+			body = append(body, sectionRows(c)...)
+		case "tr":
+			body = append(body, c)
+		}
+	}
+	return head, body
+}
+
+// sectionRows returns rows of thead, tbody or tfoot. Section is a row itself if it contains cells without <tr>.
+func sectionRows(section *html.Node) []*html.Node {
+	rows := []*html.Node{}
+	hasCells := false
+	for c := section.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode {
+			continue
+		}
+		switch c.Data {
+		case "tr":
+			rows = append(rows, c)
+		case "th", "td":
+			hasCells = true
+		}
+	}
+	if hasCells {
+		rows = append([]*html.Node{section}, rows...)
+	}
+	return rows
+}
+
+// tableGrid returns texts of the cells of the rows. Spanning cells are copied to every position they cover.
+func tableGrid(rows []*html.Node) [][]string {
+	grid := make([][]string, len(rows))
+	set := func(r, c int, text string) {
+		for len(grid[r]) <= c {
+			grid[r] = append(grid[r], "")
+		}
+		grid[r][c] = text
+	}
+	//filled marks positions covered by cells spanning from the rows above
+	filled := make([]map[int]bool, len(rows))
+	for i := range filled {
+		filled[i] = map[int]bool{}
+	}
+	for r, row := range rows {
+		col := 0
+		for c := row.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode || (c.Data != "td" && c.Data != "th") {
+				continue
+			}
+			for filled[r][col] {
+				col++
+			}
+			cell := goquery.NewDocumentFromNode(c).Selection
+			text := strings.Join(strings.Fields(cell.Text()), " ")
+			colspan, rowspan := span(cell, "colspan"), span(cell, "rowspan")
+			for i := r; i < r+rowspan && i < len(rows); i++ {
+				for j := col; j < col+colspan; j++ {
+					set(i, j, text)
+					filled[i][j] = true
+				}
+			}
+			col += colspan
+		}
+	}
+	return grid
+}
+
+// span returns value of colspan or rowspan attribute. Default value is 1.
+func span(cell *goquery.Selection, attr string) int {
+	n, err := strconv.Atoi(strings.TrimSpace(cell.AttrOr(attr, "1")))
+	if err != nil || n < 1 {
+		return 1
+	}
+	//the limit of HTML specification
+	if n > 1000 {
+		return 1000
+	}
+	return n
+}
+
+// tableHeader returns names of width columns. Texts of header rows are joined for columns with several header rows like grouped columns.
+func tableHeader(head [][]string, width int) []string {
+	header := make([]string, width)
+	seen := map[string]int{}
+	for i := range header {
+		parts := []string{}
+		for _, row := range head {
+			if i < len(row) && row[i] != "" && (len(parts) == 0 || parts[len(parts)-1] != row[i]) {
+				parts = append(parts, row[i])
+			}
+		}
+		name := strings.Join(parts, " ")
+		if name == "" {
+			name = fmt.Sprintf("Column %d", i+1)
+		}
+		//duplicate names are numbered
+		seen[name]++
+		if seen[name] > 1 {
+			name = fmt.Sprintf("%s %d", name, seen[name])
+		}
+		header[i] = name
+	}
+	return header
+}
@@ -0,0 +1,52 @@
+package extract
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTable(t *testing.T) {
+	sel := selFrom(`<table>
+	<thead>
+		<tr><th rowspan="2">Name</th><th colspan="2">Contacts</th><th></th></tr>
+		<tr><th>Phone</th><th>Email</th></tr>
+	</thead>
+	<tbody>
+		<tr><td>Alexa</td><td>808-9109</td><td>sed@example.net</td><td>x</td></tr>
+		<tr><td rowspan="2">Inga</td><td colspan="2">n/a</td></tr>
+		<tr><td>809-8755</td><td>a@example.com <table><tr><td>nested</td></tr></table></td></tr>
+		<tr></tr>
+	</tbody>
+	</table>`)
+	ret, err := Table{}.Extract(sel.Find("table").First())
+	assert.NoError(t, err)
+	assert.Equal(t, []map[string]interface{}{
+		{"Name": "Alexa", "Contacts Phone": "808-9109", "Contacts Email": "sed@example.net", "Column 4": "x"},
+		{"Name": "Inga", "Contacts Phone": "n/a", "Contacts Email": "n/a", "Column 4": ""},
+		{"Name": "Inga", "Contacts Phone": "809-8755", "Contacts Email": "a@example.com nested", "Column 4": ""},
+	}, ret)
+
+	ret, err = Table{Columns: []TableColumn{
+		{Header: "name", Name: "person"},
+		{Header: "Contacts Email"},
+		{Index: 1, Name: "phone"},
+		{Header: "Missing"},
+	}}.Extract(sel)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"person": "Alexa", "Contacts Email": "sed@example.net", "phone": "808-9109"}, ret.([]map[string]interface{})[0])
+
+	//header of tables without thead is the first row. Cells may be direct children of thead as well.
+	sel = selFrom(`<div><table><tr><td>A</td><td>A</td></tr><tr><td>1</td><td>2</td><td>3</td></tr></table></div>
+	<table><thead><th>B</th></thead><tbody><tr><td>4</td></tr></tbody></table>`)
+	ret, err = Table{}.Extract(sel.Find("div, body > table"))
+	assert.NoError(t, err)
+	assert.Equal(t, []map[string]interface{}{
+		{"A": "1", "A 2": "2", "Column 3": "3"},
+		{"B": "4"},
+	}, ret)
+
+	ret, err = Table{}.Extract(selFrom(`<p>no tables</p>`).Find("p"))
+	assert.NoError(t, err)
+	assert.Nil(t, ret)
+}
@@ -0,0 +1,351 @@
+package extract
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// decimalComma lists languages which use comma as decimal separator.
+var decimalComma = map[string]bool{
+	"bg": true, "cs": true, "da": true, "de": true, "el": true, "es": true, "et": true, "fi": true, "fr": true,
+	"hr": true, "hu": true, "id": true, "it": true, "lt": true, "lv": true, "nb": true, "nl": true, "no": true,
+	"pl": true, "pt": true, "ro": true, "ru": true, "sk": true, "sl": true, "sr": true, "sv": true, "tr": true,
+	"uk": true, "vi": true,
+}
+
+// separators returns decimal and thousands separators of the locale like "de", "de-CH" or "en_US". English separators are used by default.
+func separators(locale string) (decimal, thousands string) {
+	locale = strings.ToLower(strings.Replace(locale, "_", "-", -1))
+	//Switzerland and Liechtenstein use apostrophe for thousands
+	if strings.HasSuffix(locale, "-ch") || strings.HasSuffix(locale, "-li") {
+		return ".", "'"
+	}
+	if decimalComma[strings.SplitN(locale, "-", 2)[0]] {
+		return ",", "."
+	}
+	return ".", ","
+}
+
+//spaces separate groups of digits only if exactly three digits follow, so "Page 1 2 3" is not a single number
+var numberRe = regexp.MustCompile(`[-−+]?(?:\d{1,3}(?:[\s\x{00a0}\x{202f}]\d{3}\b)+|\d+)(?:[.,']\d+)*`)
+
+// parseNumber finds the first number in text. Thousands separators and spaces between digit groups are skipped.
+func parseNumber(text, decimal string) (float64, bool) {
+	number := numberRe.FindString(text)
+	if number == "" {
+		return 0, false
+	}
+	var b strings.Builder
+	for _, r := range number {
+		switch {
+		case r >= '0' && r <= '9', r == '+', r == '-':
+			b.WriteRune(r)
+		case r == '−':
+			b.WriteRune('-')
+		case string(r) == decimal:
+			b.WriteRune('.')
+		}
+	}
+	n, err := strconv.ParseFloat(b.String(), 64)
+	return n, err == nil
+}
+
+// Number is an Extractor that parses numbers from text of each part in the selection.
+// Thousands separators are skipped, so "1,299.00" is 1299. Parts without digits are skipped.
+// The return type of the extractor is float64 or a list of numbers (i.e. []float64).
+type Number struct {
+	// Locale defines decimal and thousands separators, f.e. "de" parses "1.299,50" as 1299.5. Default locale is "en".
+	Locale string
+	// If no numbers are found, then return the empty list from Extract, instead of 'nil'.
+	IncludeIfEmpty bool
+}
+
+// Extract returns numbers from specified selection.
+func (e Number) Extract(sel *goquery.Selection) (interface{}, error) {
+	decimal, _ := separators(e.Locale)
+	results := []float64{}
+	sel.Each(func(i int, s *goquery.Selection) {
+		if n, ok := parseNumber(s.Text(), decimal); ok {
+			results = append(results, n)
+		}
+	})
+	if len(results) == 0 && !e.IncludeIfEmpty {
+		return nil, nil
+	}
+	if len(results) == 1 {
+		return results[0], nil
+	}
+	return results, nil
+}
+
+var _ Extractor = Number{}
+
+// PriceValue is an amount of money in the currency identified by ISO 4217 code.
+type PriceValue struct {
+	Amount   float64 `json:"amount"`
+	Currency string  `json:"currency,omitempty"`
+}
+
+// currencySymbols maps currency signs to ISO 4217 codes. Longer signs are matched first.
+var currencySymbols = map[string]string{
+	"$": "USD", "US$": "USD", "€": "EUR", "£": "GBP", "¥": "JPY", "₽": "RUB", "руб": "RUB", "₴": "UAH", "₹": "INR",
+	"₩": "KRW", "₺": "TRY", "₪": "ILS", "zł": "PLN", "Kč": "CZK", "R$": "BRL", "C$": "CAD", "CA$": "CAD",
+	"A$": "AUD", "AU$": "AUD", "NZ$": "NZD", "HK$": "HKD", "S$": "SGD", "Fr.": "CHF", "₫": "VND", "฿": "THB",
+}
+
+var currencyCodes = map[string]bool{
+	"USD": true, "EUR": true, "GBP": true, "JPY": true, "CNY": true, "RUB": true, "UAH": true, "INR": true, "KRW": true,
+	"TRY": true, "ILS": true, "PLN": true, "CZK": true, "BRL": true, "CAD": true, "AUD": true, "NZD": true, "HKD": true,
+	"SGD": true, "CHF": true, "SEK": true, "NOK": true, "DKK": true, "HUF": true, "RON": true, "BGN": true, "MXN": true,
+	"ZAR": true, "THB": true, "VND": true, "IDR": true, "MYR": true, "PHP": true, "AED": true, "SAR": true,
+}
+
+var (
+	currencyCodeRe = regexp.MustCompile(`\b[A-Z]{3}\b`)
+	//symbols sorted by length descending, so "US$" is found before "$"
+	symbols = func() []string {
+		s := []string{}
+		for sym := range currencySymbols {
+			s = append(s, sym)
+		}
+		sort.Slice(s, func(i, j int) bool {
+			if len(s[i]) != len(s[j]) {
+				return len(s[i]) > len(s[j])
+			}
+			return s[i] < s[j]
+		})
+		return s
+	}()
+)
+
+// currency detects ISO 4217 code of the currency mentioned in text by code or sign.
+func currency(text string) string {
+	for _, code := range currencyCodeRe.FindAllString(text, -1) {
+		if currencyCodes[code] {
+			return code
+		}
+	}
+	for _, sym := range symbols {
+		if strings.Contains(text, sym) {
+			return currencySymbols[sym]
+		}
+	}
+	return ""
+}
+
+// Price is an Extractor that parses amount and currency from text of each part in the selection, f.e. "$1,299.00" is {"amount":1299, "currency":"USD"}.
+// The return type of the extractor is PriceValue or a list of prices (i.e. []PriceValue).
+type Price struct {
+	// Locale defines decimal and thousands separators of amounts like in Number extractor.
+	Locale string
+	// Currency is ISO 4217 code used if no currency is found in the text.
+	Currency string
+	// If no prices are found, then return the empty list from Extract, instead of 'nil'.
+	IncludeIfEmpty bool
+}
+
+// Extract returns prices from specified selection.
+func (e Price) Extract(sel *goquery.Selection) (interface{}, error) {
+	decimal, _ := separators(e.Locale)
+	results := []PriceValue{}
+	sel.Each(func(i int, s *goquery.Selection) {
+		text := s.Text()
+		amount, ok := parseNumber(text, decimal)
+		if !ok {
+			return
+		}
+		p := PriceValue{Amount: amount, Currency: currency(text)}
+		if p.Currency == "" {
+			p.Currency = strings.ToUpper(e.Currency)
+		}
+		results = append(results, p)
+	})
+	if len(results) == 0 && !e.IncludeIfEmpty {
+		return nil, nil
+	}
+	if len(results) == 1 {
+		return results[0], nil
+	}
+	return results, nil
+}
+
+var _ Extractor = Price{}
+
+// DefaultDateLayouts are tried by Date extractor if no layouts are specified.
+var DefaultDateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC822Z,
+	time.RFC822,
+	"Monday, January 2, 2006",
+	"January 2, 2006 3:04 PM",
+	"January 2, 2006",
+	"Jan 2, 2006 3:04 PM",
+	"Jan 2, 2006",
+	"2 January 2006",
+	"2 Jan 2006",
+	"02.01.2006 15:04",
+	"02.01.2006",
+	"01/02/2006",
+}
+
+var relativeDateRe = regexp.MustCompile(`(?i)\b(?:(in)\s+)?(\d+|an?|one)\s+(second|sec|minute|min|hour|hr|day|week|month|year)s?\b(?:\s+(ago))?`)
+
+// relativeDate parses dates like "3 days ago", "in 2 hours", "yesterday" relative to now.
+func relativeDate(text string, now time.Time) (time.Time, bool) {
+	lower := strings.ToLower(strings.TrimSpace(text))
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	switch lower {
+	case "now", "just now":
+		return now, true
+	case "today":
+		return midnight, true
+	case "yesterday":
+		return midnight.AddDate(0, 0, -1), true
+	case "tomorrow":
+		return midnight.AddDate(0, 0, 1), true
+	}
+	m := relativeDateRe.FindStringSubmatch(lower)
+	//one of "in" or "ago" is required
+	if m == nil || (m[1] == "") == (m[4] == "") {
+		return time.Time{}, false
+	}
+	n, err := strconv.Atoi(m[2])
+	if err != nil {
+		n = 1
+	}
+	if m[4] != "" {
+		n = -n
+	}
+	switch m[3] {
+	case "second", "sec":
+		return now.Add(time.Duration(n) * time.Second), true
+	case "minute", "min":
+		return now.Add(time.Duration(n) * time.Minute), true
+	case "hour", "hr":
+		return now.Add(time.Duration(n) * time.Hour), true
+	case "day":
+		return now.AddDate(0, 0, n), true
+	case "week":
+		return now.AddDate(0, 0, 7*n), true
+	case "month":
+		return now.AddDate(0, n, 0), true
+	}
+	return now.AddDate(n, 0, 0), true
+}
+
+// Date is an Extractor that parses dates from each part in the selection and returns them as RFC3339 timestamps.
+// datetime attribute of <time> elements is used if it is present. Otherwise text is parsed by layouts or as a relative date like "3 days ago" or "yesterday".
+// The return type of the extractor is a string or a list of timestamps (i.e. []string).
+type Date struct {
+	// Layouts are Go time layouts like "02.01.2006 15:04". DefaultDateLayouts are used if it is empty.
+	Layouts []string
+	// Location is a time zone of dates without explicit offset and of relative dates. UTC is used by default.
+	Location *time.Location
+	// Now returns current time for relative dates. time.Now is used by default.
+	Now func() time.Time
+	// If no dates are found, then return the empty list from Extract, instead of 'nil'.
+	IncludeIfEmpty bool
+}
+
+func (e Date) parse(text string) (time.Time, bool) {
+	loc := e.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	text = strings.Join(strings.Fields(text), " ")
+	layouts := e.Layouts
+	if len(layouts) == 0 {
+		layouts = DefaultDateLayouts
+	}
+	for _, layout := range layouts {
+		if t, err := time.ParseInLocation(layout, text, loc); err == nil {
+			return t, true
+		}
+	}
+	now := time.Now
+	if e.Now != nil {
+		now = e.Now
+	}
+	return relativeDate(text, now().In(loc))
+}
+
+// Extract returns dates from specified selection.
+func (e Date) Extract(sel *goquery.Selection) (interface{}, error) {
+	results := []string{}
+	sel.Each(func(i int, s *goquery.Selection) {
+		text, found := s.Attr("datetime")
+		if !found {
+			text = s.Text()
+		}
+		if t, ok := e.parse(text); ok {
+			results = append(results, t.Format(time.RFC3339))
+		}
+	})
+	if len(results) == 0 && !e.IncludeIfEmpty {
+		return nil, nil
+	}
+	if len(results) == 1 {
+		return results[0], nil
+	}
+	return results, nil
+}
+
+var _ Extractor = Date{}
+
+// DefaultTruthy matches texts which Boolean extractor considers true by default.
+var DefaultTruthy = regexp.MustCompile(`(?i)^\s*(yes|y|true|1|on|in stock|available|✓|✔)\s*$`)
+
+// Boolean is an Extractor that returns true for parts with text matching Truthy pattern and false otherwise.
+// The return type of the extractor is bool or a list of booleans (i.e. []bool).
+type Boolean struct {
+	// Truthy is a pattern of true values. DefaultTruthy is used if it is nil.
+	Truthy *regexp.Regexp
+	// If selection is empty, then return the empty list from Extract, instead of 'nil'.
+	IncludeIfEmpty bool
+}
+
+// Extract returns booleans from specified selection.
+func (e Boolean) Extract(sel *goquery.Selection) (interface{}, error) {
+	truthy := e.Truthy
+	if truthy == nil {
+		truthy = DefaultTruthy
+	}
+	results := []bool{}
+	sel.Each(func(i int, s *goquery.Selection) {
+		results = append(results, truthy.MatchString(s.Text()))
+	})
+	if len(results) == 0 && !e.IncludeIfEmpty {
+		return nil, nil
+	}
+	if len(results) == 1 {
+		return results[0], nil
+	}
+	return results, nil
+}
+
+var _ Extractor = Boolean{}
+
+// ParseLocation returns time zone by IANA name like "Europe/Berlin" or fixed offset like "+02:00".
+func ParseLocation(tz string) (*time.Location, error) {
+	if t, err := time.Parse("-07:00", tz); err == nil {
+		_, offset := t.Zone()
+		return time.FixedZone(tz, offset), nil
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return nil, errors.New("Unknown time zone " + tz)
+	}
+	return loc, nil
+}
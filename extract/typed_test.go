@@ -0,0 +1,127 @@
+package extract
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNumber(t *testing.T) {
+	sel := selFrom(`<p>1,299.50</p><p>Total: −12 items</p><p>n/a</p><p>3 000 000</p>`)
+	ret, err := Number{}.Extract(sel.Find("p"))
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{1299.5, -12, 3000000}, ret)
+
+	for locale, expected := range map[string]float64{
+		"de":    1299.5,
+		"fr_FR": 1299.5,
+		"en-US": 1.2995,
+	} {
+		ret, err = Number{Locale: locale}.Extract(selFrom(`<p>1.299,5</p>`).Find("p"))
+		assert.NoError(t, err)
+		assert.Equal(t, expected, ret, locale)
+	}
+	ret, err = Number{Locale: "de-CH"}.Extract(selFrom(`<p>1'299.50 CHF</p>`).Find("p"))
+	assert.NoError(t, err)
+	assert.Equal(t, 1299.5, ret)
+
+	//spaces separate groups of thousands only, other numbers after space are not joined
+	for _, c := range []struct {
+		text     string
+		locale   string
+		expected float64
+	}{
+		{"12.50 3 items left", "en", 12.5},
+		{"12.50 3 items left", "de", 1250},
+		{"Page 1 2 3", "en", 1},
+		{"1 2345", "en", 1},
+		{"1\u00a0299,50 €", "fr", 1299.5},
+		{"12\u202f000 pcs", "fr", 12000},
+		{"Total 1 000 000 000", "en", 1000000000},
+	} {
+		ret, err = Number{Locale: c.locale}.Extract(selFrom(`<p>` + c.text + `</p>`).Find("p"))
+		assert.NoError(t, err)
+		assert.Equal(t, c.expected, ret, c.text)
+	}
+
+	ret, err = Number{}.Extract(selFrom(`<p>none</p>`).Find("p"))
+	assert.NoError(t, err)
+	assert.Nil(t, ret)
+	ret, err = Number{IncludeIfEmpty: true}.Extract(selFrom(`<p>none</p>`).Find("p"))
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{}, ret)
+}
+
+func TestPrice(t *testing.T) {
+	sel := selFrom(`<p>$1,299.00</p><p>1.299,99 €</p><p>US$ 5</p><p>CHF 12.50</p><p>R$ 10</p><p>99</p><p>free</p>`)
+	ret, err := Price{Locale: "en", Currency: "gbp"}.Extract(sel.Find("p"))
+	assert.NoError(t, err)
+	assert.Equal(t, []PriceValue{
+		{Amount: 1299, Currency: "USD"},
+		{Amount: 1.29999, Currency: "EUR"},
+		{Amount: 5, Currency: "USD"},
+		{Amount: 12.5, Currency: "CHF"},
+		{Amount: 10, Currency: "BRL"},
+		{Amount: 99, Currency: "GBP"},
+	}, ret)
+
+	ret, err = Price{Locale: "de"}.Extract(selFrom(`<p>1.299,99 €</p>`).Find("p"))
+	assert.NoError(t, err)
+	assert.Equal(t, PriceValue{Amount: 1299.99, Currency: "EUR"}, ret)
+}
+
+func TestDate(t *testing.T) {
+	sel := selFrom(`<time datetime="2018-03-01T10:00:00Z">yesterday</time><p>2018-03-05</p><p>March 7, 2018</p><p>someday</p>`)
+	ret, err := Date{}.Extract(sel.Find("time, p"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"2018-03-01T10:00:00Z", "2018-03-05T00:00:00Z", "2018-03-07T00:00:00Z"}, ret)
+
+	berlin, err := ParseLocation("Europe/Berlin")
+	assert.NoError(t, err)
+	ret, err = Date{Layouts: []string{"02.01.2006 15:04"}, Location: berlin}.Extract(selFrom(`<p>05.03.2018 14:30</p>`).Find("p"))
+	assert.NoError(t, err)
+	assert.Equal(t, "2018-03-05T14:30:00+01:00", ret)
+
+	now := func() time.Time { return time.Date(2018, 3, 10, 12, 0, 0, 0, time.UTC) }
+	for text, expected := range map[string]string{
+		"3 days ago":         "2018-03-07T12:00:00Z",
+		"an hour ago":        "2018-03-10T11:00:00Z",
+		"posted 2 weeks ago": "2018-02-24T12:00:00Z",
+		"in 5 minutes":       "2018-03-10T12:05:00Z",
+		"Yesterday":          "2018-03-09T00:00:00Z",
+		"today":              "2018-03-10T00:00:00Z",
+		"just now":           "2018-03-10T12:00:00Z",
+	} {
+		ret, err = Date{Now: now}.Extract(selFrom(`<p>` + text + `</p>`).Find("p"))
+		assert.NoError(t, err)
+		assert.Equal(t, expected, ret, text)
+	}
+	ret, err = Date{Now: now}.Extract(selFrom(`<p>3 days</p>`).Find("p"))
+	assert.NoError(t, err)
+	assert.Nil(t, ret)
+
+	loc, err := ParseLocation("+05:30")
+	assert.NoError(t, err)
+	ret, err = Date{Location: loc}.Extract(selFrom(`<p>2018-03-05 10:00</p>`).Find("p"))
+	assert.NoError(t, err)
+	assert.Equal(t, "2018-03-05T10:00:00+05:30", ret)
+	_, err = ParseLocation("Mars/Olympus")
+	assert.Error(t, err)
+}
+
+func TestBoolean(t *testing.T) {
+	sel := selFrom(`<p>In stock</p><p> yes </p><p>Out of stock</p><p>✓</p>`)
+	ret, err := Boolean{}.Extract(sel.Find("p"))
+	assert.NoError(t, err)
+	assert.Equal(t, []bool{true, true, false, true}, ret)
+
+	ret, err = Boolean{Truthy: regexp.MustCompile(`(?i)^ja$`)}.Extract(selFrom(`<p>Ja</p>`).Find("p"))
+	assert.NoError(t, err)
+	assert.Equal(t, true, ret)
+
+	ret, err = Boolean{}.Extract(selFrom(`<p>x</p>`).Find("span"))
+	assert.NoError(t, err)
+	assert.Nil(t, ret)
+}
@@ -0,0 +1,328 @@
+package fetch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mafredri/cdp"
+	"github.com/mafredri/cdp/protocol/network"
+	"github.com/mafredri/cdp/protocol/runtime"
+	"github.com/slotix/dataflowkit/errs"
+)
+
+// Types of actions performed by Chrome fetcher.
+const (
+	//WaitForAction waits until element matching Selector appears on the page.
+	WaitForAction = "waitFor"
+	//ClickAction clicks the element matching Selector.
+	ClickAction = "click"
+	//TypeAction enters Value into the input matching Selector.
+	TypeAction = "type"
+	//SelectAction chooses the option with Value in the select element matching Selector.
+	SelectAction = "select"
+	//ScrollAction scrolls the page to the bottom Times times.
+	ScrollAction = "scroll"
+	//NetworkIdleAction waits until no network requests are sent for Wait milliseconds.
+	NetworkIdleAction = "networkIdle"
+	//EvalAction evaluates JavaScript expression passed in Value. Promises are awaited.
+	EvalAction = "eval"
+)
+
+// Action is performed by Chrome fetcher on the page after navigation. Actions are performed in order before the content of the page is returned.
+// Click, type and select actions wait for their element up to Timeout.
+//
+// Example:
+//
+// "actions":[{"type":"click", "selector":".cookies button"}, {"type":"type", "selector":"#search", "value":"books"}, {"type":"click", "selector":"#submit"}, {"type":"waitFor", "selector":".results"}]
+type Action struct {
+	Type string `json:"type"`
+	//Selector is CSS selector of the element. It is required by waitFor, click, type and select actions.
+	Selector string `json:"selector,omitempty"`
+	//Value is a text typed into the input, a value of the option to select or JavaScript expression to evaluate.
+	Value string `json:"value,omitempty"`
+	//Times is a number of scrolls. Default value is 1.
+	Times int `json:"times,omitempty"`
+	//Wait is a time in milliseconds. It is a pause after every scroll or a period without network requests for networkIdle. Default value is 500.
+	Wait int `json:"wait,omitempty"`
+	//Timeout in milliseconds of waiting for the element or network idle. Default value is 10000.
+	Timeout int `json:"timeout,omitempty"`
+}
+
+// actionPage is a page actions are performed on.
+type actionPage interface {
+	//evaluate returns JSON value of JavaScript expression.
+	evaluate(ctx context.Context, expression string) (json.RawMessage, error)
+	//networkIdle waits until no requests are sent or loading for the idle period.
+	networkIdle(ctx context.Context, idle time.Duration) error
+}
+
+// validateActions checks if all the actions have parameters they require.
+func validateActions(actions []Action) error {
+	for i, a := range actions {
+		var err error
+		switch a.Type {
+		case WaitForAction, ClickAction, TypeAction, SelectAction:
+			if a.Selector == "" {
+				err = errors.New("selector is required")
+			}
+		case EvalAction:
+			if a.Value == "" {
+				err = errors.New("value is required")
+			}
+		case ScrollAction, NetworkIdleAction:
+		default:
+			err = errors.New("unknown action type")
+		}
+		if err != nil {
+			return &errs.BadRequest{Err: fmt.Errorf("Action %d %s: %s", i, a.Type, err.Error())}
+		}
+	}
+	return nil
+}
+
+// runActions performs actions on the page one by one. Processing is stopped on the first failed action.
+func runActions(ctx context.Context, p actionPage, actions []Action) error {
+	for i, a := range actions {
+		if err := a.run(ctx, p); err != nil {
+			//cancelled fetch is reported as is
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return &errs.Error{Err: fmt.Sprintf("Action %d %s failed: %s", i, a.Type, err.Error())}
+		}
+	}
+	return nil
+}
+
+func (a Action) run(ctx context.Context, p actionPage) error {
+	switch a.Type {
+	case WaitForAction:
+		return a.waitFor(ctx, p)
+	case ClickAction:
+		return a.withElement(ctx, p, "click on "+a.Selector+" failed", `el.scrollIntoView(); el.click(); return true;`)
+	case TypeAction:
+		return a.withElement(ctx, p, a.Selector+" cannot be set to "+a.Value, `el.focus(); el.value = `+jsString(a.Value)+`;
+			el.dispatchEvent(new Event('input', {bubbles: true}));
+			el.dispatchEvent(new Event('change', {bubbles: true}));
+			return true;`)
+	case SelectAction:
+		return a.withElement(ctx, p, a.Selector+" cannot be set to "+a.Value, `el.value = `+jsString(a.Value)+`;
+			if (el.value !== `+jsString(a.Value)+`) { return false; }
+			el.dispatchEvent(new Event('input', {bubbles: true}));
+			el.dispatchEvent(new Event('change', {bubbles: true}));
+			return true;`)
+	case ScrollAction:
+		times := a.Times
+		if times == 0 {
+			times = 1
+		}
+		for i := 0; i < times; i++ {
+			if _, err := p.evaluate(ctx, `window.scrollTo(0, document.body.scrollHeight)`); err != nil {
+				return err
+			}
+			if err := sleep(ctx, a.wait()); err != nil {
+				return err
+			}
+		}
+		return nil
+	case NetworkIdleAction:
+		ctx, cancel := context.WithTimeout(ctx, a.timeout())
+		defer cancel()
+		return p.networkIdle(ctx, a.wait())
+	case EvalAction:
+		_, err := p.evaluate(ctx, a.Value)
+		return err
+	}
+	return fmt.Errorf("unknown action type %s", a.Type)
+}
+
+// waitFor polls the page until the element appears.
+func (a Action) waitFor(ctx context.Context, p actionPage) error {
+	ctx, cancel := context.WithTimeout(ctx, a.timeout())
+	defer cancel()
+	if err := poll(ctx, p, selectorExpression(a.Selector)); err != nil {
+		return fmt.Errorf("%s is not found", a.Selector)
+	}
+	return nil
+}
+
+// poll evaluates JavaScript expression every 100 milliseconds until it returns true or ctx is done.
+// Evaluation errors are ignored as the page may be reloaded or not rendered yet.
+func poll(ctx context.Context, p actionPage, expression string) error {
+	for {
+		result, err := p.evaluate(ctx, expression)
+		if err == nil && string(result) == "true" {
+			return nil
+		}
+		if err = sleep(ctx, 100*time.Millisecond); err != nil {
+			return err
+		}
+	}
+}
+
+// selectorExpression returns JavaScript expression which checks if element matching CSS selector exists.
+func selectorExpression(selector string) string {
+	return `document.querySelector(` + jsString(selector) + `) !== null`
+}
+
+// withElement waits for the element and runs the function body with the element passed as el. The function returns false if it fails.
+// failure is the error message of the action if the function fails.
+func (a Action) withElement(ctx context.Context, p actionPage, failure string, body string) error {
+	if err := a.waitFor(ctx, p); err != nil {
+		return err
+	}
+	ok, err := p.evaluate(ctx, `(function(el) {`+body+`})(document.querySelector(`+jsString(a.Selector)+`))`)
+	if err != nil {
+		return err
+	}
+	if string(ok) != "true" {
+		return errors.New(failure)
+	}
+	return nil
+}
+
+func (a Action) wait() time.Duration {
+	if a.Wait == 0 {
+		return 500 * time.Millisecond
+	}
+	return time.Duration(a.Wait) * time.Millisecond
+}
+
+func (a Action) timeout() time.Duration {
+	if a.Timeout == 0 {
+		return 10 * time.Second
+	}
+	return time.Duration(a.Timeout) * time.Millisecond
+}
+
+// jsString quotes the string as JavaScript string literal.
+func jsString(s string) string {
+	quoted, _ := json.Marshal(s)
+	return string(quoted)
+}
+
+// sleep pauses until the duration is elapsed or ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// cdpPage performs actions in Chrome tab.
+type cdpPage struct {
+	client *cdp.Client
+}
+
+func (p cdpPage) evaluate(ctx context.Context, expression string) (json.RawMessage, error) {
+	reply, err := p.client.Runtime.Evaluate(ctx, runtime.NewEvaluateArgs(expression).SetReturnByValue(true).SetAwaitPromise(true))
+	if err != nil {
+		return nil, err
+	}
+	if e := reply.ExceptionDetails; e != nil {
+		if e.Exception != nil && e.Exception.Description != nil {
+			return nil, errors.New(*e.Exception.Description)
+		}
+		return nil, errors.New(e.Text)
+	}
+	return reply.Result.Value, nil
+}
+
+func (p cdpPage) networkIdle(ctx context.Context, idle time.Duration) error {
+	w, err := watchNetwork(ctx, p.client)
+	if err != nil {
+		return err
+	}
+	defer w.close()
+	return w.wait(ctx, idle)
+}
+
+// networkWatcher tracks network requests of the page.
+type networkWatcher struct {
+	sent     network.RequestWillBeSentClient
+	finished network.LoadingFinishedClient
+	failed   network.LoadingFailedClient
+}
+
+// watchNetwork starts tracking network requests of the page. Requests sent before are not tracked.
+func watchNetwork(ctx context.Context, client *cdp.Client) (*networkWatcher, error) {
+	var (
+		w   = &networkWatcher{}
+		err error
+	)
+	if w.sent, err = client.Network.RequestWillBeSent(ctx); err != nil {
+		return nil, err
+	}
+	if w.finished, err = client.Network.LoadingFinished(ctx); err != nil {
+		w.sent.Close()
+		return nil, err
+	}
+	if w.failed, err = client.Network.LoadingFailed(ctx); err != nil {
+		w.sent.Close()
+		w.finished.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *networkWatcher) close() {
+	w.sent.Close()
+	w.finished.Close()
+	w.failed.Close()
+}
+
+// wait blocks until no requests are sent or loading for the idle period.
+func (w *networkWatcher) wait(ctx context.Context, idle time.Duration) error {
+	sent, finished, failed := w.sent, w.finished, w.failed
+	//requests sent before watching are not tracked. They are awaited for the idle period too.
+	//Events of different types may be received out of order. So finished requests are remembered.
+	pending := make(map[network.RequestID]bool)
+	done := make(map[network.RequestID]bool)
+	timer := time.NewTimer(idle)
+	defer timer.Stop()
+	for {
+		select {
+		case <-sent.Ready():
+			ev, err := sent.Recv()
+			if err != nil {
+				return err
+			}
+			if !done[ev.RequestID] {
+				pending[ev.RequestID] = true
+			}
+		case <-finished.Ready():
+			ev, err := finished.Recv()
+			if err != nil {
+				return err
+			}
+			delete(pending, ev.RequestID)
+			done[ev.RequestID] = true
+		case <-failed.Ready():
+			ev, err := failed.Recv()
+			if err != nil {
+				return err
+			}
+			delete(pending, ev.RequestID)
+			done[ev.RequestID] = true
+		case <-timer.C:
+			if len(pending) == 0 {
+				return nil
+			}
+			timer.Reset(idle)
+			continue
+		case <-ctx.Done():
+			return errors.New("network is still busy")
+		}
+		if !timer.Stop() {
+			<-timer.C
+		}
+		timer.Reset(idle)
+	}
+}
@@ -0,0 +1,103 @@
+package fetch
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/slotix/dataflowkit/errs"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakePage imitates page in Chrome tab. Element is found by waitFor polls as soon as found returns true.
+type fakePage struct {
+	found     func() bool
+	evaluated []string
+	idle      time.Duration
+	//failed makes actions on elements fail
+	failed bool
+}
+
+func (p *fakePage) evaluate(ctx context.Context, expression string) (json.RawMessage, error) {
+	p.evaluated = append(p.evaluated, expression)
+	if strings.HasPrefix(expression, "document.querySelector") {
+		if p.found != nil && p.found() {
+			return json.RawMessage(`true`), nil
+		}
+		return json.RawMessage(`false`), nil
+	}
+	if p.failed && strings.HasPrefix(expression, "(function(el)") {
+		return json.RawMessage(`false`), nil
+	}
+	return json.RawMessage(`true`), nil
+}
+
+func (p *fakePage) networkIdle(ctx context.Context, idle time.Duration) error {
+	p.idle = idle
+	return nil
+}
+
+func TestValidateActions(t *testing.T) {
+	assert.NoError(t, validateActions([]Action{
+		{Type: ClickAction, Selector: "#more"},
+		{Type: ScrollAction, Times: 3},
+		{Type: NetworkIdleAction},
+		{Type: EvalAction, Value: "window.stop()"},
+	}))
+	assert.IsType(t, &errs.BadRequest{}, validateActions([]Action{{Type: TypeAction, Value: "books"}}))
+	assert.IsType(t, &errs.BadRequest{}, validateActions([]Action{{Type: EvalAction}}))
+	assert.IsType(t, &errs.BadRequest{}, validateActions([]Action{{Type: "hover", Selector: "a"}}))
+}
+
+func TestRunActions(t *testing.T) {
+	p := &fakePage{}
+	err := runActions(context.Background(), p, []Action{
+		{Type: ScrollAction, Times: 3, Wait: 1},
+		{Type: NetworkIdleAction, Wait: 200},
+		{Type: EvalAction, Value: "window.stop()"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"window.scrollTo(0, document.body.scrollHeight)",
+		"window.scrollTo(0, document.body.scrollHeight)",
+		"window.scrollTo(0, document.body.scrollHeight)",
+		"window.stop()",
+	}, p.evaluated)
+	assert.Equal(t, 200*time.Millisecond, p.idle)
+
+	//missing element fails the action after timeout
+	err = runActions(context.Background(), p, []Action{{Type: ClickAction, Selector: "#missing", Timeout: 300}})
+	assert.IsType(t, &errs.Error{}, err)
+	assert.Contains(t, err.Error(), "Action 0 click failed: #missing is not found")
+
+	//failure messages are specific to actions
+	failing := &fakePage{found: func() bool { return true }, failed: true}
+	err = runActions(context.Background(), failing, []Action{{Type: ClickAction, Selector: "#more"}})
+	assert.Contains(t, err.Error(), "Action 0 click failed: click on #more failed")
+	err = runActions(context.Background(), failing, []Action{{Type: SelectAction, Selector: "#sort", Value: "price"}})
+	assert.Contains(t, err.Error(), "#sort cannot be set to price")
+
+	//cancelled fetch is not reported as failed action
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = runActions(ctx, p, []Action{{Type: WaitForAction, Selector: "#missing"}})
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestActionWaitFor(t *testing.T) {
+	//element appears after the third poll
+	polls := 0
+	p := &fakePage{found: func() bool {
+		polls++
+		return polls >= 3
+	}}
+	err := runActions(context.Background(), p, []Action{
+		{Type: TypeAction, Selector: "#search", Value: `say "hi"`},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, polls)
+	assert.Contains(t, p.evaluated[len(p.evaluated)-1], `el.value = "say \"hi\""`)
+	assert.Contains(t, p.evaluated[len(p.evaluated)-1], `document.querySelector("#search")`)
+}
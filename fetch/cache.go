@@ -0,0 +1,305 @@
+package fetch
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/slotix/dataflowkit/storage"
+	"github.com/slotix/dataflowkit/utils"
+	"github.com/spf13/viper"
+)
+
+// Cache modes of Request. They override caching rules of the web server.
+const (
+	//CacheRefresh forces fetching of the page from the web server. Cached copy is replaced with the new one.
+	CacheRefresh = "refresh"
+	//CacheStale returns cached copy of the page even if it is stale. The page is fetched only if it is not cached yet.
+	CacheStale = "stale"
+)
+
+//CacheMiddleware stores fetched pages in the storage and returns them while they are fresh according to RFC 7234.
+//Stale pages are revalidated with conditional requests if the web server returned ETag or Last-Modified validators.
+func CacheMiddleware() ServiceMiddleware {
+	return func(next Service) Service {
+		return cacheMiddleware{next}
+	}
+}
+
+type cacheMiddleware struct {
+	Service
+}
+
+// cacheEntry is a response stored in the cache.
+type cacheEntry struct {
+	StatusCode int
+	URL        string
+	Header     http.Header
+	Body       []byte
+	//RequestTime and ResponseTime are used to calculate the age of the response
+	RequestTime  time.Time
+	ResponseTime time.Time
+}
+
+//Fetch returns cached page if it is fresh. Otherwise the page is fetched and stored in the cache.
+func (mw cacheMiddleware) Fetch(ctx context.Context, req Request) (*Response, error) {
+	if !cacheable(req) {
+		return mw.Service.Fetch(ctx, req)
+	}
+	s := storage.NewStore(viper.GetString("STORAGE_TYPE"))
+	defer s.Close()
+	rec := storage.Record{
+		Type: storage.CACHE,
+		Key:  cacheKey(req),
+	}
+	var cached *cacheEntry
+	if req.Cache != CacheRefresh {
+		cached = readCache(s, rec)
+	}
+	if cached != nil {
+		if req.Cache == CacheStale || cached.fresh(time.Now()) {
+			return cached.response(), nil
+		}
+		req.header = cached.validators()
+	}
+	requestTime := time.Now()
+	resp, err := mw.Service.Fetch(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		resp.Body.Close()
+		//stored headers are updated with the ones of 304 response
+		for k, v := range resp.Header {
+			cached.Header[k] = v
+		}
+		cached.RequestTime = requestTime
+		cached.ResponseTime = time.Now()
+		writeCache(s, rec, cached)
+		return cached.response(), nil
+	}
+	if !storable(req, resp) {
+		return resp, nil
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	writeCache(s, rec, &cacheEntry{
+		StatusCode:   resp.StatusCode,
+		URL:          resp.URL,
+		Header:       resp.Header,
+		Body:         body,
+		RequestTime:  requestTime,
+		ResponseTime: time.Now(),
+	})
+	return resp, nil
+}
+
+// cacheable checks if response to req may be cached.
+// Only GET requests of Base fetcher are cached. Pages rendered by Chrome and pages requested with personal cookies or form data are always fetched.
+// Responses to requests with Authorization or Cookie headers are cached only if the web server allows it explicitly. See storable.
+func cacheable(req Request) bool {
+	if viper.GetBool("IGNORE_CACHE_INFO") {
+		return false
+	}
+	return req.Type != "chrome" &&
+		req.method() == "GET" &&
+		req.FormData == "" &&
+		req.Body == "" &&
+		req.UserToken == ""
+}
+
+// storable checks if response may be stored in the cache. The cache is shared by all users, so private responses are not stored.
+// Responses to requests with credentials are stored only if they are marked public, have s-maxage or must-revalidate directives as RFC 7234 section 3.2 requires.
+func storable(req Request, resp *Response) bool {
+	if resp.StatusCode != http.StatusOK {
+		return false
+	}
+	cc := cacheControl(resp.Header)
+	if _, ok := cc["no-store"]; ok {
+		return false
+	}
+	if _, ok := cc["private"]; ok {
+		return false
+	}
+	if !withCredentials(req) {
+		return true
+	}
+	for _, directive := range []string{"public", "s-maxage", "must-revalidate"} {
+		if _, ok := cc[directive]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// withCredentials checks if request headers contain Authorization or Cookie header.
+func withCredentials(req Request) bool {
+	for k := range req.Headers {
+		switch http.CanonicalHeaderKey(k) {
+		case "Authorization", "Cookie":
+			return true
+		}
+	}
+	return false
+}
+
+// cacheKey returns storage key of the cached page. Request headers are the part of the key as they may change the content, f.e. Accept-Language.
+// Pinned proxy and proxy group are the part of the key too, as pages fetched from other countries may differ.
+func cacheKey(req Request) string {
+	key := []string{"GET " + req.getURL(), "User-Agent: " + req.userAgent()}
+	for k, v := range req.Headers {
+		key = append(key, http.CanonicalHeaderKey(k)+": "+v)
+	}
+	//names with spaces never clash with header names
+	if req.Proxy != "" {
+		key = append(key, "Pinned proxy: "+req.Proxy)
+	}
+	if req.ProxyGroup != "" {
+		key = append(key, "Proxy group: "+req.ProxyGroup)
+	}
+	sort.Strings(key[2:])
+	return hex.EncodeToString(utils.GenerateMD5([]byte(strings.Join(key, "\n"))))
+}
+
+// readCache returns cached page. Nil is returned if there is no cached page or it is expired in the storage.
+func readCache(s storage.Store, rec storage.Record) *cacheEntry {
+	value, err := s.Read(rec)
+	if err != nil || len(value) == 0 {
+		return nil
+	}
+	if s.Expired(rec) {
+		return nil
+	}
+	entry := &cacheEntry{}
+	if err = json.Unmarshal(value, entry); err != nil {
+		logger.Warningf("Failed to read cached page %s. %s", rec.Key, err.Error())
+		return nil
+	}
+	return entry
+}
+
+func writeCache(s storage.Store, rec storage.Record, entry *cacheEntry) {
+	value, err := json.Marshal(entry)
+	if err == nil {
+		rec.Value = value
+		rec.ExpTime = viper.GetInt64("ITEM_EXPIRE_IN")
+		err = s.Write(rec)
+	}
+	if err != nil {
+		logger.Warningf("Failed to cache %s. %s", entry.URL, err.Error())
+	}
+}
+
+// response returns cached page as a Response.
+func (e *cacheEntry) response() *Response {
+	return &Response{
+		StatusCode: e.StatusCode,
+		URL:        e.URL,
+		Header:     e.Header,
+		Cookies:    (&http.Response{Header: e.Header}).Cookies(),
+		Started:    e.RequestTime,
+		Duration:   e.ResponseTime.Sub(e.RequestTime),
+		Body:       ioutil.NopCloser(bytes.NewReader(e.Body)),
+		FromCache:  true,
+	}
+}
+
+// validators returns headers of conditional request which revalidates cached page.
+func (e *cacheEntry) validators() http.Header {
+	h := http.Header{}
+	if etag := e.Header.Get("ETag"); etag != "" {
+		h.Set("If-None-Match", etag)
+	}
+	if lastModified := e.Header.Get("Last-Modified"); lastModified != "" {
+		h.Set("If-Modified-Since", lastModified)
+	}
+	return h
+}
+
+// fresh checks if the age of cached page doesn't exceed its freshness lifetime.
+func (e *cacheEntry) fresh(now time.Time) bool {
+	return e.age(now) < freshnessLifetime(e.Header, e.ResponseTime)
+}
+
+// age calculates current age of cached page as described in RFC 7234 section 4.2.3.
+func (e *cacheEntry) age(now time.Time) time.Duration {
+	date := headerTime(e.Header, "Date", e.ResponseTime)
+	apparentAge := e.ResponseTime.Sub(date)
+	if apparentAge < 0 {
+		apparentAge = 0
+	}
+	ageValue, _ := strconv.Atoi(e.Header.Get("Age"))
+	correctedAge := time.Duration(ageValue)*time.Second + e.ResponseTime.Sub(e.RequestTime)
+	if correctedAge < apparentAge {
+		correctedAge = apparentAge
+	}
+	return correctedAge + now.Sub(e.ResponseTime)
+}
+
+// freshnessLifetime calculates how long response is fresh as described in RFC 7234 section 4.2.1.
+// max-age directive takes precedence over Expires header. If none of them is specified, heuristic lifetime of 10% of the time since Last-Modified is used.
+func freshnessLifetime(h http.Header, responseTime time.Time) time.Duration {
+	cc := cacheControl(h)
+	if _, ok := cc["no-cache"]; ok {
+		return 0
+	}
+	if maxAge, ok := cc["max-age"]; ok {
+		seconds, err := strconv.Atoi(maxAge)
+		if err != nil {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	date := headerTime(h, "Date", responseTime)
+	if h.Get("Expires") != "" {
+		//invalid Expires value means the response is already expired
+		expires := headerTime(h, "Expires", date)
+		return expires.Sub(date)
+	}
+	if h.Get("Last-Modified") != "" {
+		lastModified := headerTime(h, "Last-Modified", date)
+		return date.Sub(lastModified) / 10
+	}
+	return 0
+}
+
+// cacheControl parses Cache-Control header directives.
+func cacheControl(h http.Header) map[string]string {
+	cc := map[string]string{}
+	for _, value := range h["Cache-Control"] {
+		for _, directive := range strings.Split(value, ",") {
+			directive = strings.TrimSpace(directive)
+			if directive == "" {
+				continue
+			}
+			kv := strings.SplitN(directive, "=", 2)
+			name := strings.ToLower(kv[0])
+			if len(kv) == 2 {
+				cc[name] = strings.Trim(kv[1], `"`)
+			} else {
+				cc[name] = ""
+			}
+		}
+	}
+	return cc
+}
+
+// headerTime parses date header. Default value is returned if the header is missing or invalid.
+func headerTime(h http.Header, name string, def time.Time) time.Time {
+	t, err := http.ParseTime(h.Get(name))
+	if err != nil {
+		return def
+	}
+	return t
+}
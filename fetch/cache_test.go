@@ -0,0 +1,168 @@
+package fetch
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFreshnessLifetime(t *testing.T) {
+	now := time.Date(2018, 5, 1, 10, 0, 0, 0, time.UTC)
+	date := now.Format(http.TimeFormat)
+	tests := []struct {
+		header   http.Header
+		lifetime time.Duration
+	}{
+		{http.Header{"Cache-Control": {"public, max-age=600"}}, 10 * time.Minute},
+		{http.Header{"Cache-Control": {"no-cache, max-age=600"}}, 0},
+		{http.Header{"Cache-Control": {"max-age=60"}, "Date": {date}, "Expires": {now.Add(time.Hour).Format(http.TimeFormat)}}, time.Minute},
+		{http.Header{"Date": {date}, "Expires": {now.Add(time.Hour).Format(http.TimeFormat)}}, time.Hour},
+		{http.Header{"Date": {date}, "Expires": {"0"}}, 0},
+		{http.Header{"Date": {date}, "Last-Modified": {now.Add(-10 * time.Hour).Format(http.TimeFormat)}}, time.Hour},
+		{http.Header{}, 0},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.lifetime, freshnessLifetime(tt.header, now), "%v", tt.header)
+	}
+}
+
+func TestCacheEntryAge(t *testing.T) {
+	responseTime := time.Date(2018, 5, 1, 10, 0, 0, 0, time.UTC)
+	e := &cacheEntry{
+		Header: http.Header{
+			"Date": {responseTime.Add(-time.Minute).Format(http.TimeFormat)},
+			"Age":  {"30"},
+		},
+		RequestTime:  responseTime.Add(-time.Second),
+		ResponseTime: responseTime,
+	}
+	assert.Equal(t, 2*time.Minute, e.age(responseTime.Add(time.Minute)))
+	e.Header.Set("Cache-Control", "max-age=180")
+	assert.True(t, e.fresh(responseTime.Add(time.Minute)))
+	assert.False(t, e.fresh(responseTime.Add(2*time.Minute)))
+}
+
+func TestCacheKey(t *testing.T) {
+	req := Request{URL: "http://example.com", Headers: map[string]string{"accept-language": "de"}}
+	key := cacheKey(req)
+	assert.Equal(t, key, cacheKey(Request{URL: "http://example.com", Headers: map[string]string{"Accept-Language": "de"}}))
+	keys := map[string]bool{key: true}
+	for _, r := range []Request{
+		{URL: "http://example.com"},
+		{URL: "http://example.com", Headers: req.Headers, ProxyGroup: "de"},
+		{URL: "http://example.com", Headers: req.Headers, ProxyGroup: "us"},
+		{URL: "http://example.com", Headers: req.Headers, Proxy: "http://proxy-de:3128"},
+	} {
+		keys[cacheKey(r)] = true
+	}
+	assert.Len(t, keys, 5)
+}
+
+func TestCacheMiddleware(t *testing.T) {
+	baseDir := viper.GetString("DISKV_BASE_DIR")
+	defer viper.Set("DISKV_BASE_DIR", baseDir)
+	viper.Set("DISKV_BASE_DIR", "./cache_test")
+	defer os.RemoveAll("./cache_test")
+	viper.Set("ITEM_EXPIRE_IN", 3600)
+	viper.Set("PROXY", "")
+
+	var hits, notModified int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		switch r.URL.Path {
+		case "/fresh":
+			w.Header().Set("Cache-Control", "max-age=3600")
+		case "/etag":
+			w.Header().Set("Cache-Control", "no-cache")
+			w.Header().Set("ETag", `"v1"`)
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				atomic.AddInt32(&notModified, 1)
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		case "/nostore":
+			w.Header().Set("Cache-Control", "no-store")
+		case "/private":
+			w.Header().Set("Cache-Control", "private, max-age=3600")
+		case "/public":
+			w.Header().Set("Cache-Control", "public, max-age=3600")
+		}
+		w.Write(helloContent)
+	}))
+	defer ts.Close()
+	svc := CacheMiddleware()(FetchService{})
+
+	fetch := func(req Request) *Response {
+		resp, err := svc.Fetch(context.Background(), req)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		body, err := ioutil.ReadAll(resp.Body)
+		assert.NoError(t, err)
+		assert.Equal(t, helloContent, body)
+		return resp
+	}
+
+	//fresh page is returned from cache
+	assert.False(t, fetch(Request{URL: ts.URL + "/fresh"}).FromCache)
+	assert.True(t, fetch(Request{URL: ts.URL + "/fresh"}).FromCache)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&hits))
+	//refresh forces fetching
+	assert.False(t, fetch(Request{URL: ts.URL + "/fresh", Cache: CacheRefresh}).FromCache)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&hits))
+
+	//stale page is revalidated
+	assert.False(t, fetch(Request{URL: ts.URL + "/etag"}).FromCache)
+	assert.True(t, fetch(Request{URL: ts.URL + "/etag"}).FromCache)
+	assert.Equal(t, int32(4), atomic.LoadInt32(&hits))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&notModified))
+	//stale page is allowed
+	assert.True(t, fetch(Request{URL: ts.URL + "/etag", Cache: CacheStale}).FromCache)
+	assert.Equal(t, int32(4), atomic.LoadInt32(&hits))
+
+	//no-store responses and chrome requests are not cached
+	fetch(Request{URL: ts.URL + "/nostore"})
+	assert.False(t, fetch(Request{URL: ts.URL + "/nostore"}).FromCache)
+	assert.Equal(t, int32(6), atomic.LoadInt32(&hits))
+	assert.False(t, cacheable(Request{Type: "chrome", URL: ts.URL}))
+	assert.False(t, cacheable(Request{URL: ts.URL, FormData: "a=1"}))
+
+	//private responses and responses to requests with credentials are not stored in shared cache
+	atomic.StoreInt32(&hits, 0)
+	fetch(Request{URL: ts.URL + "/private"})
+	assert.False(t, fetch(Request{URL: ts.URL + "/private"}).FromCache)
+	auth := map[string]string{"authorization": "Bearer token"}
+	fetch(Request{URL: ts.URL + "/fresh", Headers: auth})
+	assert.False(t, fetch(Request{URL: ts.URL + "/fresh", Headers: auth}).FromCache)
+	cookie := map[string]string{"Cookie": "session=1"}
+	fetch(Request{URL: ts.URL + "/fresh", Headers: cookie})
+	assert.False(t, fetch(Request{URL: ts.URL + "/fresh", Headers: cookie}).FromCache)
+	assert.Equal(t, int32(6), atomic.LoadInt32(&hits))
+	//unless the web server allows it
+	fetch(Request{URL: ts.URL + "/public", Headers: auth})
+	assert.True(t, fetch(Request{URL: ts.URL + "/public", Headers: auth}).FromCache)
+	assert.Equal(t, int32(7), atomic.LoadInt32(&hits))
+}
+
+func TestStorable(t *testing.T) {
+	ok := func(cc string) *Response {
+		return &Response{StatusCode: http.StatusOK, Header: http.Header{"Cache-Control": {cc}}}
+	}
+	auth := Request{Headers: map[string]string{"Authorization": "Basic dXNlcg=="}}
+	assert.True(t, storable(Request{}, ok("max-age=60")))
+	assert.False(t, storable(Request{}, ok("private")))
+	assert.False(t, storable(Request{}, ok("no-store")))
+	assert.False(t, storable(auth, ok("max-age=60")))
+	assert.True(t, storable(auth, ok("public")))
+	assert.True(t, storable(auth, ok("s-maxage=60")))
+	assert.True(t, storable(auth, ok("must-revalidate")))
+	assert.False(t, storable(auth, ok("public, private")))
+}
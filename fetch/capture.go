@@ -0,0 +1,154 @@
+package fetch
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"math"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/mafredri/cdp"
+	"github.com/mafredri/cdp/protocol/emulation"
+	"github.com/mafredri/cdp/protocol/page"
+	"github.com/slotix/dataflowkit/errs"
+	"github.com/slotix/dataflowkit/storage"
+	"github.com/slotix/dataflowkit/utils"
+	"github.com/spf13/viper"
+)
+
+// Formats of artifacts captured by Chrome fetcher.
+const (
+	CapturePNG  = "png"
+	CaptureJPEG = "jpeg"
+	CapturePDF  = "pdf"
+)
+
+// Capture makes Chrome fetcher take a screenshot of the page or print it to PDF after actions are performed.
+// The artifact is stored in the storage and its key is returned in Response.Artifact. It may be downloaded from GET /artifacts/{key} of Fetch service.
+//
+// Example:
+//
+// "capture":{"format":"jpeg", "quality":80, "fullPage":true}
+type Capture struct {
+	//Format of the artifact. It may be png, jpeg or pdf. Default value is png.
+	Format string `json:"format,omitempty"`
+	//Quality of jpeg screenshot from 0 to 100.
+	Quality int `json:"quality,omitempty"`
+	//FullPage captures the whole scrollable page instead of the visible viewport. PDF always contains the whole page.
+	FullPage bool `json:"fullPage,omitempty"`
+}
+
+func (c Capture) format() string {
+	if c.Format == "" {
+		return CapturePNG
+	}
+	return c.Format
+}
+
+func (c Capture) validate() error {
+	var err error
+	switch c.format() {
+	case CapturePNG, CapturePDF:
+		if c.Quality != 0 {
+			err = errors.New("Capture quality is applicable to jpeg format only")
+		}
+	case CaptureJPEG:
+		if c.Quality < 0 || c.Quality > 100 {
+			err = errors.New("Capture quality should be in range from 0 to 100")
+		}
+	default:
+		err = errors.New("Unknown capture format " + c.Format)
+	}
+	if err != nil {
+		return &errs.BadRequest{Err: err}
+	}
+	return nil
+}
+
+// artifactKey returns storage key of the artifact captured from the page. The key ends with format extension.
+func artifactKey(url string, started time.Time, format string) string {
+	hash := utils.GenerateMD5([]byte(url + "\n" + strconv.FormatInt(started.UnixNano(), 10)))
+	return hex.EncodeToString(hash) + "." + format
+}
+
+// artifactContentType returns Content-Type of the artifact by extension of its key.
+func artifactContentType(key string) string {
+	switch filepath.Ext(key) {
+	case "." + CapturePNG:
+		return "image/png"
+	case "." + CaptureJPEG:
+		return "image/jpeg"
+	case "." + CapturePDF:
+		return "application/pdf"
+	}
+	return "application/octet-stream"
+}
+
+// capture takes a screenshot of the page or prints it to PDF.
+func (c Capture) capture(ctx context.Context, client *cdp.Client) ([]byte, error) {
+	if c.format() == CapturePDF {
+		reply, err := client.Page.PrintToPDF(ctx, page.NewPrintToPDFArgs().SetPrintBackground(true))
+		if err != nil {
+			return nil, err
+		}
+		return reply.Data, nil
+	}
+	args := page.NewCaptureScreenshotArgs().SetFormat(c.format())
+	if c.format() == CaptureJPEG && c.Quality > 0 {
+		args.SetQuality(c.Quality)
+	}
+	if c.FullPage {
+		metrics, err := client.Page.GetLayoutMetrics(ctx)
+		if err != nil {
+			return nil, err
+		}
+		width := int(math.Ceil(metrics.ContentSize.Width))
+		height := int(math.Ceil(metrics.ContentSize.Height))
+		//viewport is stretched to the content size. Otherwise the area outside the viewport is not rendered.
+		err = client.Emulation.SetDeviceMetricsOverride(ctx, emulation.NewSetDeviceMetricsOverrideArgs(width, height, 1, false))
+		if err != nil {
+			return nil, err
+		}
+		//the tab is reused by next fetches
+		defer client.Emulation.ClearDeviceMetricsOverride(ctx)
+		args.SetClip(page.Viewport{Width: float64(width), Height: float64(height), Scale: 1})
+	}
+	reply, err := client.Page.CaptureScreenshot(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	return reply.Data, nil
+}
+
+// writeArtifact stores the artifact for ITEM_EXPIRE_IN seconds.
+func writeArtifact(key string, data []byte) error {
+	s := storage.NewStore(viper.GetString("STORAGE_TYPE"))
+	defer s.Close()
+	return s.Write(storage.Record{
+		Type:    storage.ARTIFACT,
+		Key:     key,
+		Value:   data,
+		ExpTime: viper.GetInt64("ITEM_EXPIRE_IN"),
+	})
+}
+
+//artifactHandler returns the artifact captured by Chrome fetcher.
+func artifactHandler(w http.ResponseWriter, r *http.Request) {
+	key := mux.Vars(r)["key"]
+	s := storage.NewStore(viper.GetString("STORAGE_TYPE"))
+	defer s.Close()
+	data, err := s.Read(storage.Record{
+		Type: storage.ARTIFACT,
+		Key:  key,
+	})
+	if err != nil || len(data) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", artifactContentType(key))
+	w.Write(data)
+}
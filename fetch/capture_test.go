@@ -0,0 +1,55 @@
+package fetch
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/slotix/dataflowkit/errs"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCapture(t *testing.T) {
+	assert.NoError(t, Capture{}.validate())
+	assert.NoError(t, Capture{Format: CaptureJPEG, Quality: 80, FullPage: true}.validate())
+	assert.NoError(t, Capture{Format: CapturePDF}.validate())
+	assert.IsType(t, &errs.BadRequest{}, Capture{Format: "gif"}.validate())
+	assert.IsType(t, &errs.BadRequest{}, Capture{Format: CaptureJPEG, Quality: 101}.validate())
+	assert.IsType(t, &errs.BadRequest{}, Capture{Quality: 80}.validate())
+
+	started := time.Date(2018, 5, 1, 10, 0, 0, 0, time.UTC)
+	key := artifactKey("http://example.com", started, CapturePNG)
+	assert.Regexp(t, `^[0-9a-f]{32}\.png$`, key)
+	assert.NotEqual(t, key, artifactKey("http://example.com", started.Add(time.Second), CapturePNG))
+	assert.Equal(t, "image/png", artifactContentType(key))
+	assert.Equal(t, "application/pdf", artifactContentType("abc.pdf"))
+}
+
+func TestArtifactHandler(t *testing.T) {
+	baseDir := viper.GetString("DISKV_BASE_DIR")
+	defer viper.Set("DISKV_BASE_DIR", baseDir)
+	viper.Set("DISKV_BASE_DIR", "./artifact_test")
+	defer os.RemoveAll("./artifact_test")
+
+	key := artifactKey("http://example.com", time.Now(), CaptureJPEG)
+	assert.NoError(t, writeArtifact(key, []byte("jpeg data")))
+
+	r := mux.NewRouter()
+	r.Methods("GET").Path(`/artifacts/{key:[0-9a-f]+\.(?:png|jpeg|pdf)}`).HandlerFunc(artifactHandler)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/artifacts/"+key, nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "image/jpeg", w.Header().Get("Content-Type"))
+	body, _ := ioutil.ReadAll(w.Body)
+	assert.Equal(t, "jpeg data", string(body))
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/artifacts/0123456789abcdef.png", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
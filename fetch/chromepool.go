@@ -0,0 +1,529 @@
+package fetch
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mafredri/cdp"
+	"github.com/mafredri/cdp/devtool"
+	"github.com/mafredri/cdp/protocol/network"
+	"github.com/mafredri/cdp/protocol/page"
+	"github.com/mafredri/cdp/protocol/runtime"
+	"github.com/mafredri/cdp/protocol/target"
+	"github.com/mafredri/cdp/rpcc"
+	"github.com/spf13/viper"
+)
+
+// errContextNotFound is returned by browser if the browser context doesn't exist anymore, e.g. Chrome is restarted.
+var errContextNotFound = errors.New("Browser context is not found")
+
+// ChromePoolConfig contains settings of the pool of Headless Chrome tabs. Zero values are replaced with defaults.
+type ChromePoolConfig struct {
+	//Size is a maximum number of tabs used at the same time. Fetches wait for a free tab if all of them are busy. Default value is 4.
+	Size int
+	//MaxNavigations is a number of pages loaded by a tab before it is closed and replaced with a new one. Default value is 100.
+	MaxNavigations int
+	//MaxMemory is a size of JavaScript heap in bytes after which tab is replaced with a new one. No limit is set by default.
+	MaxMemory int64
+	//ContextTTL is a time after which unused browser context of UserToken is disposed along with its cookies and localStorage. Default value is 30 minutes.
+	ContextTTL time.Duration
+}
+
+// ChromePool keeps pre-warmed tabs of Headless Chrome so ChromeFetcher doesn't create, connect and set up a new target for every page.
+// Tabs are health-checked before reuse and recycled after MaxNavigations pages or when their JavaScript heap exceeds MaxMemory.
+// Requests with the same UserToken share a browser context, so cookies and localStorage persist between them.
+// ChromePool is safe for concurrent use.
+type ChromePool struct {
+	cfg     ChromePoolConfig
+	browser browser
+	//sem limits the number of tabs in use
+	sem      chan struct{}
+	mx       sync.Mutex
+	idle     []*chromeTab
+	contexts map[string]*browserContext
+}
+
+// chromeTab is a target of Headless Chrome with domains enabled by ChromeFetcher.
+type chromeTab struct {
+	target *devtool.Target
+	conn   *rpcc.Conn
+	client *cdp.Client
+	//token is UserToken of browser context of the tab. It is empty for the default context.
+	token string
+	//context is ID of browser context the tab is opened in
+	context     target.BrowserContextID
+	navigations int
+}
+
+// browserContext is an isolated browser session of UserToken.
+type browserContext struct {
+	id target.BrowserContextID
+	//busy is a number of tabs of the context in use
+	busy     int
+	lastUsed time.Time
+}
+
+// browser controls targets of Headless Chrome. It is replaced in tests.
+type browser interface {
+	newContext(ctx context.Context) (target.BrowserContextID, error)
+	disposeContext(ctx context.Context, id target.BrowserContextID) error
+	//newTab opens a tab in the browser context. Empty id means the default context. errContextNotFound is returned if the context doesn't exist.
+	newTab(ctx context.Context, id target.BrowserContextID) (*chromeTab, error)
+	//checkTab checks if the tab is responding before it is reused.
+	checkTab(ctx context.Context, tab *chromeTab) error
+	//resetTab cleans up the tab after the fetch. It returns used JavaScript heap size of the loaded page.
+	resetTab(ctx context.Context, tab *chromeTab) (int64, error)
+	closeTab(tab *chromeTab)
+}
+
+var (
+	sharedChromeTabs     *ChromePool
+	sharedChromeTabsOnce sync.Once
+)
+
+// chromeTabs returns tab pool shared by all Chrome fetchers. It is configured from settings on the first use.
+func chromeTabs() *ChromePool {
+	sharedChromeTabsOnce.Do(func() {
+		sharedChromeTabs = NewChromePool(chromePoolConfig(), newDevtoolsBrowser(viper.GetString("CHROME")))
+	})
+	return sharedChromeTabs
+}
+
+// chromePoolConfig reads ChromePoolConfig from configuration settings.
+func chromePoolConfig() ChromePoolConfig {
+	return ChromePoolConfig{
+		Size:           viper.GetInt("CHROME_POOL_SIZE"),
+		MaxNavigations: viper.GetInt("CHROME_TAB_MAX_NAVIGATIONS"),
+		MaxMemory:      viper.GetInt64("CHROME_TAB_MAX_MEMORY") << 20,
+		ContextTTL:     time.Duration(viper.GetInt("CHROME_CONTEXT_TTL")) * time.Second,
+	}
+}
+
+// NewChromePool creates tab pool.
+func NewChromePool(cfg ChromePoolConfig, b browser) *ChromePool {
+	if cfg.Size == 0 {
+		cfg.Size = 4
+	}
+	if cfg.MaxNavigations == 0 {
+		cfg.MaxNavigations = 100
+	}
+	if cfg.ContextTTL == 0 {
+		cfg.ContextTTL = 30 * time.Minute
+	}
+	return &ChromePool{
+		cfg:      cfg,
+		browser:  b,
+		sem:      make(chan struct{}, cfg.Size),
+		contexts: make(map[string]*browserContext),
+	}
+}
+
+// WarmUpChrome opens idle tabs of the pool in advance so the first fetches don't wait for them.
+func WarmUpChrome(ctx context.Context) error {
+	return chromeTabs().warmUp(ctx)
+}
+
+func (p *ChromePool) warmUp(ctx context.Context) error {
+	p.mx.Lock()
+	n := p.cfg.Size - len(p.idle)
+	p.mx.Unlock()
+	for i := 0; i < n; i++ {
+		tab, err := p.browser.newTab(ctx, "")
+		if err != nil {
+			return err
+		}
+		p.mx.Lock()
+		p.idle = append(p.idle, tab)
+		p.mx.Unlock()
+	}
+	return nil
+}
+
+// acquire returns a tab for the fetch. It waits until one of the tabs is free.
+// The tab must be returned to the pool with release.
+func (p *ChromePool) acquire(ctx context.Context, token string) (*chromeTab, error) {
+	select {
+	case p.sem <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	tab, err := p.tab(ctx, token)
+	if err != nil {
+		<-p.sem
+		return nil, err
+	}
+	return tab, nil
+}
+
+// tab returns responding idle tab of the browser context or opens a new one.
+func (p *ChromePool) tab(ctx context.Context, token string) (*chromeTab, error) {
+	for tab := p.takeIdle(token); tab != nil; tab = p.takeIdle(token) {
+		err := p.browser.checkTab(ctx, tab)
+		if err == nil {
+			return tab, nil
+		}
+		logger.Warningf("Chrome tab is not responding. %s", err.Error())
+		p.done(token, tab.context)
+		p.browser.closeTab(tab)
+	}
+	tab, err := p.newTab(ctx, token)
+	if err == errContextNotFound {
+		//the context is recreated once, as Chrome may be restarted since it was created
+		logger.Warningf("Browser context of user is lost. It is created again")
+		tab, err = p.newTab(ctx, token)
+	}
+	return tab, err
+}
+
+// newTab opens a tab in browser context of UserToken. The context is forgotten if Chrome doesn't know it anymore.
+func (p *ChromePool) newTab(ctx context.Context, token string) (*chromeTab, error) {
+	var id target.BrowserContextID
+	if token != "" {
+		var err error
+		if id, err = p.context(ctx, token); err != nil {
+			return nil, err
+		}
+	}
+	tab, err := p.browser.newTab(ctx, id)
+	if err != nil {
+		p.done(token, id)
+		if err == errContextNotFound {
+			p.forgetContext(token, id)
+		}
+		return nil, err
+	}
+	tab.token, tab.context = token, id
+	return tab, nil
+}
+
+// takeIdle removes idle tab of the browser context from the pool. Nil is returned if there is no idle tab.
+func (p *ChromePool) takeIdle(token string) *chromeTab {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	for i := len(p.idle) - 1; i >= 0; i-- {
+		tab := p.idle[i]
+		if tab.token == token {
+			p.idle = append(p.idle[:i], p.idle[i+1:]...)
+			if bc := p.contexts[token]; bc != nil && bc.id == tab.context {
+				bc.busy++
+			}
+			return tab
+		}
+	}
+	return nil
+}
+
+// context returns browser context of UserToken. The context is created on the first use.
+// Chrome is called without the mutex locked, so a slow browser doesn't block the rest of the pool.
+func (p *ChromePool) context(ctx context.Context, token string) (target.BrowserContextID, error) {
+	p.mx.Lock()
+	if bc, ok := p.contexts[token]; ok {
+		bc.busy++
+		p.mx.Unlock()
+		return bc.id, nil
+	}
+	p.mx.Unlock()
+	id, err := p.browser.newContext(ctx)
+	if err != nil {
+		return "", err
+	}
+	p.mx.Lock()
+	//the context may be created by concurrent fetch of the same user meanwhile
+	bc, ok := p.contexts[token]
+	if !ok {
+		bc = &browserContext{id: id}
+		p.contexts[token] = bc
+	}
+	bc.busy++
+	p.mx.Unlock()
+	if ok {
+		p.disposeContexts([]target.BrowserContextID{id})
+	}
+	return bc.id, nil
+}
+
+// done marks that one of the tabs of browser context is not used anymore. Tabs of forgotten contexts are not counted.
+func (p *ChromePool) done(token string, id target.BrowserContextID) {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	if bc := p.contexts[token]; token != "" && bc != nil && bc.id == id {
+		bc.busy--
+		bc.lastUsed = time.Now()
+	}
+}
+
+// forgetContext removes browser context which doesn't exist in Chrome anymore, so it is created again on the next use.
+// Idle tabs of the context are closed. The context is not disposed, as there is nothing to dispose.
+func (p *ChromePool) forgetContext(token string, id target.BrowserContextID) {
+	p.mx.Lock()
+	closing := []*chromeTab{}
+	if bc := p.contexts[token]; bc != nil && bc.id == id {
+		delete(p.contexts, token)
+		closing = p.removeIdle(token)
+	}
+	p.mx.Unlock()
+	for _, tab := range closing {
+		p.browser.closeTab(tab)
+	}
+}
+
+// release returns the tab to the pool. The tab is closed if the fetch is failed or the tab should be recycled.
+// Browser contexts which are not used longer than ContextTTL are disposed.
+func (p *ChromePool) release(tab *chromeTab, fetchErr error) {
+	defer func() { <-p.sem }()
+	tab.navigations++
+	recycle := fetchErr != nil || tab.navigations >= p.cfg.MaxNavigations
+	if !recycle {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		heap, err := p.browser.resetTab(ctx, tab)
+		cancel()
+		recycle = err != nil || p.cfg.MaxMemory > 0 && heap > p.cfg.MaxMemory
+	}
+	p.done(tab.token, tab.context)
+
+	p.mx.Lock()
+	closing := []*chromeTab{}
+	//tabs of the forgotten context are not reused
+	if bc := p.contexts[tab.token]; tab.token != "" && (bc == nil || bc.id != tab.context) {
+		recycle = true
+	}
+	if recycle {
+		closing = append(closing, tab)
+	} else {
+		p.idle = append(p.idle, tab)
+	}
+	if len(p.idle) > p.cfg.Size {
+		closing = append(closing, p.idle[0])
+		p.idle = p.idle[1:]
+	}
+	expired := []target.BrowserContextID{}
+	now := time.Now()
+	for token, bc := range p.contexts {
+		if bc.busy == 0 && now.Sub(bc.lastUsed) > p.cfg.ContextTTL {
+			expired = append(expired, bc.id)
+			delete(p.contexts, token)
+			closing = append(closing, p.removeIdle(token)...)
+		}
+	}
+	p.mx.Unlock()
+
+	for _, t := range closing {
+		p.browser.closeTab(t)
+	}
+	p.disposeContexts(expired)
+}
+
+// removeIdle removes all idle tabs of the browser context. It must be called with the mutex locked.
+func (p *ChromePool) removeIdle(token string) []*chromeTab {
+	removed := []*chromeTab{}
+	idle := p.idle[:0]
+	for _, tab := range p.idle {
+		if tab.token == token {
+			removed = append(removed, tab)
+		} else {
+			idle = append(idle, tab)
+		}
+	}
+	p.idle = idle
+	return removed
+}
+
+func (p *ChromePool) disposeContexts(ids []target.BrowserContextID) {
+	for _, id := range ids {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := p.browser.disposeContext(ctx, id); err != nil {
+			logger.Warningf("Failed to dispose browser context %s. %s", id, err.Error())
+		}
+		cancel()
+	}
+}
+
+// CloseIdle closes idle tabs and disposes browser contexts which are not in use. The pool may be used after that.
+func (p *ChromePool) CloseIdle() {
+	p.mx.Lock()
+	closing := p.idle
+	p.idle = nil
+	expired := []target.BrowserContextID{}
+	for token, bc := range p.contexts {
+		if bc.busy == 0 {
+			expired = append(expired, bc.id)
+			delete(p.contexts, token)
+		}
+	}
+	p.mx.Unlock()
+	for _, tab := range closing {
+		p.browser.closeTab(tab)
+	}
+	p.disposeContexts(expired)
+}
+
+// devtoolsBrowser controls Headless Chrome through DevTools protocol.
+type devtoolsBrowser struct {
+	devt *devtool.DevTools
+	mx   sync.Mutex
+	//conn is a connection to the browser target. It is used to manage browser contexts.
+	conn   *rpcc.Conn
+	client *cdp.Client
+}
+
+func newDevtoolsBrowser(chromeURL string) *devtoolsBrowser {
+	return &devtoolsBrowser{devt: devtool.New(chromeURL)}
+}
+
+// dial connects to the target. CDP messages are logged if CHROME_TRACE is set.
+func dial(ctx context.Context, wsURL string) (*rpcc.Conn, error) {
+	if viper.GetBool("CHROME_TRACE") {
+		newLogCodec := func(conn io.ReadWriter) rpcc.Codec {
+			return &LogCodec{conn: conn}
+		}
+		return rpcc.DialContext(ctx, wsURL, rpcc.WithCodec(newLogCodec))
+	}
+	return rpcc.DialContext(ctx, wsURL)
+}
+
+// browserClient returns client of the browser target. The connection is established on the first use.
+func (b *devtoolsBrowser) browserClient(ctx context.Context) (*cdp.Client, error) {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	if b.client != nil {
+		return b.client, nil
+	}
+	v, err := b.devt.Version(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if v.WebSocketDebuggerURL == "" {
+		return nil, errors.New("Browser contexts are not supported. Chrome 62 or newer is required")
+	}
+	b.conn, err = dial(ctx, v.WebSocketDebuggerURL)
+	if err != nil {
+		return nil, err
+	}
+	b.client = cdp.NewClient(b.conn)
+	go func() {
+		//connection is reestablished if Chrome is restarted
+		<-b.conn.Context().Done()
+		b.mx.Lock()
+		b.conn, b.client = nil, nil
+		b.mx.Unlock()
+	}()
+	return b.client, nil
+}
+
+func (b *devtoolsBrowser) newContext(ctx context.Context) (target.BrowserContextID, error) {
+	c, err := b.browserClient(ctx)
+	if err != nil {
+		return "", err
+	}
+	reply, err := c.Target.CreateBrowserContext(ctx)
+	if err != nil {
+		return "", err
+	}
+	return reply.BrowserContextID, nil
+}
+
+func (b *devtoolsBrowser) disposeContext(ctx context.Context, id target.BrowserContextID) error {
+	c, err := b.browserClient(ctx)
+	if err != nil {
+		return err
+	}
+	return c.Target.DisposeBrowserContext(ctx, target.NewDisposeBrowserContextArgs(id))
+}
+
+func (b *devtoolsBrowser) newTab(ctx context.Context, id target.BrowserContextID) (*chromeTab, error) {
+	var (
+		pt  *devtool.Target
+		err error
+	)
+	if id == "" {
+		//https://github.com/mafredri/cdp/issues/60
+		pt, err = b.devt.Create(ctx)
+	} else {
+		pt, err = b.contextTarget(ctx, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	tab := &chromeTab{target: pt}
+	tab.conn, err = dial(ctx, pt.WebSocketDebuggerURL)
+	if err != nil {
+		b.closeTab(tab)
+		return nil, err
+	}
+	tab.client = cdp.NewClient(tab.conn)
+	if err = runBatch(
+		// Enable all the domain events that we're interested in.
+		func() error { return tab.client.DOM.Enable(ctx) },
+		func() error { return tab.client.Network.Enable(ctx, nil) },
+		func() error { return tab.client.Page.Enable(ctx) },
+		func() error { return tab.client.Runtime.Enable(ctx) },
+	); err != nil {
+		b.closeTab(tab)
+		return nil, err
+	}
+	return tab, nil
+}
+
+// contextTarget creates a page in the browser context.
+func (b *devtoolsBrowser) contextTarget(ctx context.Context, id target.BrowserContextID) (*devtool.Target, error) {
+	c, err := b.browserClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	reply, err := c.Target.CreateTarget(ctx, target.NewCreateTargetArgs("about:blank").SetBrowserContextID(id))
+	if err != nil {
+		//Chrome replies "Failed to find browser context with id ..."
+		if re, ok := cdp.ErrorCause(err).(*rpcc.ResponseError); ok && strings.Contains(re.Message, "browser context") {
+			return nil, errContextNotFound
+		}
+		return nil, err
+	}
+	targets, err := b.devt.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, pt := range targets {
+		if pt.ID == string(reply.TargetID) {
+			return pt, nil
+		}
+	}
+	return nil, errors.New("Created target is not found")
+}
+
+func (b *devtoolsBrowser) checkTab(ctx context.Context, tab *chromeTab) error {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	_, err := tab.client.Runtime.Evaluate(ctx, runtime.NewEvaluateArgs("1"))
+	return err
+}
+
+func (b *devtoolsBrowser) resetTab(ctx context.Context, tab *chromeTab) (int64, error) {
+	heap, err := tab.client.Runtime.GetHeapUsage(ctx)
+	if err != nil {
+		return 0, err
+	}
+	//requests are intercepted by ChromeFetcher only while the page is loaded
+	err = tab.client.Network.SetRequestInterception(ctx, network.NewSetRequestInterceptionArgs([]network.RequestPattern{}))
+	if err != nil {
+		return 0, err
+	}
+	//scripts of the loaded page are stopped and its memory is freed
+	_, err = tab.client.Page.Navigate(ctx, page.NewNavigateArgs("about:blank"))
+	return int64(heap.UsedSize), err
+}
+
+// closeTab closes the target even if fetch context is already cancelled. Otherwise Chrome keeps the tab open.
+func (b *devtoolsBrowser) closeTab(tab *chromeTab) {
+	if tab.conn != nil {
+		tab.conn.Close()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := b.devt.Close(ctx, tab.target); err != nil {
+		logger.Warningf("Failed to close Chrome tab. %s", err.Error())
+	}
+}
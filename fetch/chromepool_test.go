@@ -0,0 +1,264 @@
+package fetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mafredri/cdp/devtool"
+	"github.com/mafredri/cdp/protocol/target"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeBrowser imitates Headless Chrome for ChromePool tests.
+type fakeBrowser struct {
+	mx       sync.Mutex
+	opened   int
+	closed   int
+	contexts map[target.BrowserContextID]bool
+	//broken tabs fail health check
+	broken map[*chromeTab]bool
+	heap   int64
+	//created counts browser contexts. newContext waits for slow to be closed if it is set.
+	created int
+	slow    chan struct{}
+}
+
+func newFakeBrowser() *fakeBrowser {
+	return &fakeBrowser{
+		contexts: make(map[target.BrowserContextID]bool),
+		broken:   make(map[*chromeTab]bool),
+	}
+}
+
+func (b *fakeBrowser) newContext(ctx context.Context) (target.BrowserContextID, error) {
+	b.mx.Lock()
+	slow := b.slow
+	b.mx.Unlock()
+	if slow != nil {
+		<-slow
+	}
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	b.created++
+	id := target.BrowserContextID(fmt.Sprintf("context%d", b.created))
+	b.contexts[id] = true
+	return id, nil
+}
+
+func (b *fakeBrowser) disposeContext(ctx context.Context, id target.BrowserContextID) error {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	delete(b.contexts, id)
+	return nil
+}
+
+func (b *fakeBrowser) newTab(ctx context.Context, id target.BrowserContextID) (*chromeTab, error) {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	if id != "" && !b.contexts[id] {
+		return nil, errContextNotFound
+	}
+	b.opened++
+	return &chromeTab{target: &devtool.Target{ID: fmt.Sprintf("%d", b.opened), Description: string(id)}}, nil
+}
+
+func (b *fakeBrowser) checkTab(ctx context.Context, tab *chromeTab) error {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	if b.broken[tab] {
+		return errors.New("tab is crashed")
+	}
+	return nil
+}
+
+func (b *fakeBrowser) resetTab(ctx context.Context, tab *chromeTab) (int64, error) {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	return b.heap, nil
+}
+
+func (b *fakeBrowser) closeTab(tab *chromeTab) {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	b.closed++
+}
+
+func TestChromePool(t *testing.T) {
+	b := newFakeBrowser()
+	pool := NewChromePool(ChromePoolConfig{Size: 2, MaxNavigations: 3, MaxMemory: 100}, b)
+	ctx := context.Background()
+
+	assert.NoError(t, pool.warmUp(ctx))
+	assert.Equal(t, 2, b.opened)
+
+	//warm tab is reused
+	tab, err := pool.acquire(ctx, "")
+	assert.NoError(t, err)
+	pool.release(tab, nil)
+	tab1, err := pool.acquire(ctx, "")
+	assert.NoError(t, err)
+	assert.True(t, tab == tab1)
+	assert.Equal(t, 2, b.opened)
+
+	//fetches wait for a free tab
+	tab2, err := pool.acquire(ctx, "")
+	assert.NoError(t, err)
+	timeoutCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	_, err = pool.acquire(timeoutCtx, "")
+	cancel()
+	assert.Equal(t, context.DeadlineExceeded, err)
+	pool.release(tab2, nil)
+
+	//tab is recycled after MaxNavigations pages
+	pool.release(tab1, nil)
+	assert.Equal(t, 0, b.closed)
+	tab, _ = pool.acquire(ctx, "")
+	assert.True(t, tab == tab1)
+	pool.release(tab, nil)
+	assert.Equal(t, 1, b.closed)
+
+	//failed tab is closed
+	tab, _ = pool.acquire(ctx, "")
+	pool.release(tab, errors.New("navigation failed"))
+	assert.Equal(t, 2, b.closed)
+
+	//tab exceeding memory limit is closed
+	b.heap = 200
+	tab, _ = pool.acquire(ctx, "")
+	pool.release(tab, nil)
+	assert.Equal(t, 3, b.closed)
+	b.heap = 0
+
+	//not responding tab is replaced with a new one
+	tab, _ = pool.acquire(ctx, "")
+	pool.release(tab, nil)
+	b.broken[tab] = true
+	tab1, err = pool.acquire(ctx, "")
+	assert.NoError(t, err)
+	assert.False(t, tab == tab1)
+	pool.release(tab1, nil)
+	assert.Equal(t, 4, b.closed)
+}
+
+func TestChromePool_Contexts(t *testing.T) {
+	b := newFakeBrowser()
+	pool := NewChromePool(ChromePoolConfig{Size: 4, ContextTTL: time.Hour}, b)
+	ctx := context.Background()
+
+	//requests of the same user share browser context
+	tabA, err := pool.acquire(ctx, "userA")
+	assert.NoError(t, err)
+	tabB, err := pool.acquire(ctx, "userB")
+	assert.NoError(t, err)
+	tabA1, err := pool.acquire(ctx, "userA")
+	assert.NoError(t, err)
+	assert.Len(t, b.contexts, 2)
+	assert.Equal(t, tabA.target.Description, tabA1.target.Description)
+	assert.NotEqual(t, tabA.target.Description, tabB.target.Description)
+	pool.release(tabA, nil)
+	pool.release(tabA1, nil)
+	pool.release(tabB, nil)
+
+	//idle tab of another user is not reused
+	tab, _ := pool.acquire(ctx, "")
+	assert.Equal(t, "", tab.token)
+	assert.Equal(t, "", tab.target.Description)
+	pool.release(tab, nil)
+	tab, _ = pool.acquire(ctx, "userB")
+	assert.True(t, tab == tabB)
+	pool.release(tab, nil)
+
+	//unused contexts are disposed after TTL
+	pool.cfg.ContextTTL = 0
+	tab, _ = pool.acquire(ctx, "")
+	pool.release(tab, nil)
+	assert.Len(t, b.contexts, 0)
+	assert.Len(t, pool.contexts, 0)
+	assert.Len(t, pool.idle, 1)
+
+	pool.CloseIdle()
+	assert.Len(t, pool.idle, 0)
+	assert.Equal(t, b.opened, b.closed)
+}
+
+func TestChromePool_LostContext(t *testing.T) {
+	b := newFakeBrowser()
+	pool := NewChromePool(ChromePoolConfig{Size: 4, ContextTTL: time.Hour}, b)
+	ctx := context.Background()
+
+	tab, err := pool.acquire(ctx, "userA")
+	assert.NoError(t, err)
+	pool.release(tab, nil)
+	busy, err := pool.acquire(ctx, "userA")
+	assert.NoError(t, err)
+	tab, err = pool.acquire(ctx, "userA")
+	assert.NoError(t, err)
+	pool.release(tab, nil)
+
+	//Chrome is restarted, so its tabs are not responding and browser contexts are lost
+	b.contexts = make(map[target.BrowserContextID]bool)
+	b.broken[tab] = true
+
+	//the context is created again
+	tab, err = pool.acquire(ctx, "userA")
+	assert.NoError(t, err)
+	assert.Equal(t, "context2", tab.target.Description)
+	assert.Equal(t, 2, b.created)
+	assert.Equal(t, 1, pool.contexts["userA"].busy)
+	pool.release(tab, nil)
+
+	//tab of the lost context is not reused
+	closed := b.closed
+	pool.release(busy, nil)
+	assert.Equal(t, closed+1, b.closed)
+	assert.Equal(t, 0, pool.contexts["userA"].busy)
+
+	//CloseIdle evicts unused contexts
+	pool.CloseIdle()
+	assert.Len(t, pool.contexts, 0)
+	assert.Len(t, b.contexts, 0)
+	assert.Equal(t, b.opened, b.closed)
+}
+
+func TestChromePool_SlowContext(t *testing.T) {
+	b := newFakeBrowser()
+	b.slow = make(chan struct{})
+	pool := NewChromePool(ChromePoolConfig{Size: 4, ContextTTL: time.Hour}, b)
+	ctx := context.Background()
+
+	acquired := make(chan *chromeTab, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			tab, err := pool.acquire(ctx, "userA")
+			assert.NoError(t, err)
+			acquired <- tab
+		}()
+	}
+	//the pool is not blocked while Chrome creates browser context
+	done := make(chan struct{})
+	go func() {
+		tab, err := pool.acquire(ctx, "")
+		if assert.NoError(t, err) {
+			pool.release(tab, nil)
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("pool is blocked by browser context creation")
+	}
+	close(b.slow)
+	tabA, tabA1 := <-acquired, <-acquired
+	//concurrent fetches of the same user share one context. The redundant one is disposed.
+	assert.Equal(t, tabA.target.Description, tabA1.target.Description)
+	assert.Len(t, b.contexts, 1)
+	assert.Len(t, pool.contexts, 1)
+	assert.Equal(t, 2, pool.contexts["userA"].busy)
+	pool.release(tabA, nil)
+	pool.release(tabA1, nil)
+}
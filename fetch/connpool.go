@@ -0,0 +1,140 @@
+package fetch
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// TransportConfig contains connection settings of Base fetcher. Zero values are replaced with defaults.
+type TransportConfig struct {
+	//MaxIdleConnsPerHost is a maximum number of keep-alive connections kept open per host. Default value is 10.
+	MaxIdleConnsPerHost int
+	//IdleConnTimeout is a time after which idle keep-alive connection is closed. Default value is 90 seconds.
+	IdleConnTimeout time.Duration
+	//DialTimeout limits the time of establishing TCP connection. Default value is 30 seconds.
+	DialTimeout time.Duration
+	//TLSHandshakeTimeout limits the time of TLS handshake. Default value is 10 seconds.
+	TLSHandshakeTimeout time.Duration
+	//ResponseHeaderTimeout limits the time of waiting for response headers after the request is sent. No limit is set by default.
+	ResponseHeaderTimeout time.Duration
+	//DisableHTTP2 turns off HTTP/2 for TLS connections.
+	DisableHTTP2 bool
+	//InsecureSkipVerify turns off verification of web server certificates.
+	InsecureSkipVerify bool
+}
+
+// TransportPool keeps long-lived transports of Base fetcher. All requests sent through the same proxy share a transport,
+// so keep-alive connections and TLS sessions are reused across fetches.
+// Cookie jars are not the part of transport. They stay isolated per request.
+// TransportPool is safe for concurrent use.
+type TransportPool struct {
+	cfg        TransportConfig
+	mx         sync.Mutex
+	transports map[string]*http.Transport
+}
+
+var (
+	sharedTransports     *TransportPool
+	sharedTransportsOnce sync.Once
+)
+
+// transports returns transport pool shared by all fetchers. It is configured from settings on the first use.
+func transports() *TransportPool {
+	sharedTransportsOnce.Do(func() {
+		sharedTransports = NewTransportPool(transportConfig())
+	})
+	return sharedTransports
+}
+
+// transportConfig reads TransportConfig from configuration settings.
+func transportConfig() TransportConfig {
+	return TransportConfig{
+		MaxIdleConnsPerHost:   viper.GetInt("MAX_IDLE_CONNS_PER_HOST"),
+		IdleConnTimeout:       time.Duration(viper.GetInt("IDLE_CONN_TIMEOUT")) * time.Second,
+		DialTimeout:           time.Duration(viper.GetInt("DIAL_TIMEOUT")) * time.Second,
+		TLSHandshakeTimeout:   time.Duration(viper.GetInt("TLS_HANDSHAKE_TIMEOUT")) * time.Second,
+		ResponseHeaderTimeout: time.Duration(viper.GetInt("RESPONSE_HEADER_TIMEOUT")) * time.Second,
+		DisableHTTP2:          viper.GetBool("DISABLE_HTTP2"),
+		InsecureSkipVerify:    viper.GetBool("TLS_INSECURE_SKIP_VERIFY"),
+	}
+}
+
+// NewTransportPool creates transport pool.
+func NewTransportPool(cfg TransportConfig) *TransportPool {
+	if cfg.MaxIdleConnsPerHost == 0 {
+		cfg.MaxIdleConnsPerHost = 10
+	}
+	if cfg.IdleConnTimeout == 0 {
+		cfg.IdleConnTimeout = 90 * time.Second
+	}
+	if cfg.DialTimeout == 0 {
+		cfg.DialTimeout = 30 * time.Second
+	}
+	if cfg.TLSHandshakeTimeout == 0 {
+		cfg.TLSHandshakeTimeout = 10 * time.Second
+	}
+	return &TransportPool{
+		cfg:        cfg,
+		transports: make(map[string]*http.Transport),
+	}
+}
+
+// get returns transport which sends requests through the proxy. Nil proxyURL means the proxy is taken from environment variables.
+func (p *TransportPool) get(proxyURL *url.URL) *http.Transport {
+	key := ""
+	if proxyURL != nil {
+		key = proxyURL.String()
+	}
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	if t, ok := p.transports[key]; ok {
+		return t
+	}
+	t := p.newTransport(proxyURL)
+	p.transports[key] = t
+	return t
+}
+
+func (p *TransportPool) newTransport(proxyURL *url.URL) *http.Transport {
+	t := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   p.cfg.DialTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		MaxIdleConnsPerHost:    p.cfg.MaxIdleConnsPerHost,
+		IdleConnTimeout:        p.cfg.IdleConnTimeout,
+		TLSHandshakeTimeout:    p.cfg.TLSHandshakeTimeout,
+		ResponseHeaderTimeout:  p.cfg.ResponseHeaderTimeout,
+		ExpectContinueTimeout:  time.Second,
+		ForceAttemptHTTP2:      !p.cfg.DisableHTTP2,
+		OnProxyConnectResponse: checkProxyConnect,
+		TLSClientConfig: &tls.Config{
+			InsecureSkipVerify: p.cfg.InsecureSkipVerify,
+			ClientSessionCache: tls.NewLRUClientSessionCache(0),
+		},
+	}
+	if proxyURL != nil {
+		t.Proxy = http.ProxyURL(proxyURL)
+	}
+	if p.cfg.DisableHTTP2 {
+		//non-nil empty map turns off HTTP/2
+		t.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+	return t
+}
+
+// CloseIdleConnections closes keep-alive connections of all transports of the pool.
+func (p *TransportPool) CloseIdleConnections() {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	for _, t := range p.transports {
+		t.CloseIdleConnections()
+	}
+}
@@ -0,0 +1,78 @@
+package fetch
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransportPool(t *testing.T) {
+	pool := NewTransportPool(TransportConfig{})
+	assert.Equal(t, 10, pool.cfg.MaxIdleConnsPerHost)
+	proxyURL, _ := url.Parse("http://10.0.0.1:3128")
+	assert.True(t, pool.get(nil) == pool.get(nil))
+	assert.True(t, pool.get(proxyURL) == pool.get(proxyURL))
+	assert.False(t, pool.get(nil) == pool.get(proxyURL))
+
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	}))
+	ts.EnableHTTP2 = true
+	ts.StartTLS()
+	defer ts.Close()
+	get := func(pool *TransportPool) string {
+		resp, err := (&http.Client{Transport: pool.get(nil)}).Get(ts.URL)
+		if !assert.NoError(t, err) {
+			return ""
+		}
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		return string(body)
+	}
+	assert.Equal(t, "HTTP/2.0", get(NewTransportPool(TransportConfig{InsecureSkipVerify: true})))
+	assert.Equal(t, "HTTP/1.1", get(NewTransportPool(TransportConfig{InsecureSkipVerify: true, DisableHTTP2: true})))
+}
+
+func TestFetchService_KeepAlive(t *testing.T) {
+	viper.Set("PROXY", "")
+	var conns int32
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c, err := r.Cookie("token"); err == nil {
+			w.Write([]byte(c.Value))
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: "token", Value: r.URL.Query().Get("token")})
+		w.Write(helloContent)
+	}))
+	ts.Config.ConnState = func(c net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	ts.Start()
+	defer ts.Close()
+
+	svc := FetchService{}
+	fetch := func(url string) string {
+		resp, err := svc.Fetch(context.Background(), Request{URL: url})
+		if !assert.NoError(t, err) {
+			return ""
+		}
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		return string(body)
+	}
+	for i := 0; i < 5; i++ {
+		//cookies of previous requests are not sent as every fetch has its own cookie jar
+		assert.Equal(t, string(helloContent), fetch(ts.URL+"/?token=a"))
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&conns))
+}
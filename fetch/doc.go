@@ -17,6 +17,9 @@
 //
 // Chrome Fetcher connects to Headless Chrome which renders JavaScript pages.
 //
+// Fetchers return Response which holds the document along with status code, final URL after redirects, response headers, cookies and timings.
+// /fetch endpoint returns the document in the body. Response metadata is passed in X-Fetch-* headers. Headers of the web server are prefixed with X-Fetch-Header-.
+//
 // RobotsTxtMiddleware checks if scraping of specified resource is allowed by robots.txt
 //
 package fetch
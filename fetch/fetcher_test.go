@@ -1,10 +1,15 @@
 package fetch
 
 import (
+	"context"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"testing"
+	"time"
 
+	"github.com/slotix/dataflowkit/errs"
 	"github.com/spf13/viper"
 
 	"github.com/stretchr/testify/assert"
@@ -27,9 +32,9 @@ func TestBaseFetcher_Fetch(t *testing.T) {
 		URL:    tsURL + "/hello",
 		Method: "GET",
 	}
-	html, err := fetcher.Fetch(req)
+	resp, err := fetcher.Fetch(context.Background(), req)
 	assert.NoError(t, err, "Expected no error")
-	data, err := ioutil.ReadAll(html)
+	data, err := ioutil.ReadAll(resp.Body)
 	assert.NoError(t, err, "Expected no error")
 	assert.Equal(t, helloContent, data)
 
@@ -37,7 +42,7 @@ func TestBaseFetcher_Fetch(t *testing.T) {
 	req = Request{
 		URL: tsURL,
 	}
-	content, err := fetcher.Fetch(req)
+	content, err := fetcher.Fetch(context.Background(), req)
 	assert.NoError(t, err)
 	assert.NotNil(t, content, "Expected content not nil")
 
@@ -48,7 +53,7 @@ func TestBaseFetcher_Fetch(t *testing.T) {
 		FormData: "auth_key=880ea6a14ea49e853634fbdc5015a024&referer=http%3A%2F%2Fexample.com%2F&ips_username=user&ips_password=userpassword&rememberMe=1",
 	}
 
-	content, err = fetcher.Fetch(req)
+	content, err = fetcher.Fetch(context.Background(), req)
 	assert.NoError(t, err)
 	assert.NotNil(t, content, "Expected content not nil")
 
@@ -67,16 +72,119 @@ func TestBaseFetcher_Fetch(t *testing.T) {
 	assert.Error(t, err)
 
 	//fetch robots.txt data
-	robots, _ := fetcher.Fetch(Request{
+	robots, _ := fetcher.Fetch(context.Background(), Request{
 		URL:    tsURL + "/robots.txt",
 		Method: "GET",
 	})
-	data, err = ioutil.ReadAll(robots)
+	data, err = ioutil.ReadAll(robots.Body)
 	assert.NoError(t, err, "Expected no error")
 	assert.Equal(t, robotsContent, string(data))
 
 }
 
+func TestBaseFetcher_Cancel(t *testing.T) {
+	viper.Set("PROXY", "")
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer ts.Close()
+	fetcher := newFetcher(Base)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err := fetcher.Fetch(ctx, Request{URL: ts.URL})
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestBaseFetcher_Response(t *testing.T) {
+	viper.Set("PROXY", "")
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/old" {
+			http.Redirect(w, r, "/new", http.StatusMovedPermanently)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "12345"})
+		w.Header().Set("Content-Type", "text/html; charset=windows-1251")
+		w.Header().Set("Last-Modified", "Wed, 21 Oct 2015 07:28:00 GMT")
+		w.Write([]byte("<html></html>"))
+	}))
+	defer ts.Close()
+	fetcher := newFetcher(Base)
+	resp, err := fetcher.Fetch(context.Background(), Request{URL: ts.URL + "/old"})
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, ts.URL+"/new", resp.URL)
+	assert.Equal(t, "text/html; charset=windows-1251", resp.ContentType())
+	assert.Equal(t, "Wed, 21 Oct 2015 07:28:00 GMT", resp.Header.Get("Last-Modified"))
+	if assert.Len(t, resp.Cookies, 1) {
+		assert.Equal(t, "session", resp.Cookies[0].Name)
+	}
+	assert.False(t, resp.Started.IsZero())
+	assert.True(t, resp.Duration > 0)
+}
+
+func TestBaseFetcher_NotModified(t *testing.T) {
+	viper.Set("PROXY", "")
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotModified)
+	}))
+	defer ts.Close()
+	fetcher := newFetcher(Base)
+	//conditional headers of the client are not validators of cached page
+	_, err := fetcher.Fetch(context.Background(), Request{URL: ts.URL, Headers: map[string]string{"If-None-Match": `"v1"`}})
+	if assert.IsType(t, &errs.StatusError{}, err) {
+		assert.Equal(t, http.StatusNotModified, err.(*errs.StatusError).StatusCode)
+	}
+	req := Request{URL: ts.URL, header: http.Header{}}
+	req.header.Set("If-None-Match", `"v1"`)
+	resp, err := fetcher.Fetch(context.Background(), req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+}
+
+func TestBaseFetcher_Request(t *testing.T) {
+	viper.Set("PROXY", "")
+	var received *http.Request
+	var body []byte
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		body, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer ts.Close()
+	fetcher := newFetcher(Base)
+
+	_, err := fetcher.Fetch(context.Background(), Request{
+		URL:         ts.URL,
+		Method:      "put",
+		Body:        `{"id":1}`,
+		ContentType: "application/json",
+		Headers:     map[string]string{"Accept-Language": "de", "Referer": "http://example.com"},
+		UserAgent:   "TestBot/1.0",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "PUT", received.Method)
+	assert.Equal(t, `{"id":1}`, string(body))
+	assert.Equal(t, "application/json", received.Header.Get("Content-Type"))
+	assert.Equal(t, "de", received.Header.Get("Accept-Language"))
+	assert.Equal(t, "http://example.com", received.Header.Get("Referer"))
+	assert.Equal(t, "TestBot/1.0", received.UserAgent())
+
+	//form data is posted by default
+	_, err = fetcher.Fetch(context.Background(), Request{URL: ts.URL, FormData: "a=1&b=2"})
+	assert.NoError(t, err)
+	assert.Equal(t, "POST", received.Method)
+	assert.Equal(t, "a=1&b=2", string(body))
+	assert.Equal(t, "application/x-www-form-urlencoded", received.Header.Get("Content-Type"))
+	assert.Equal(t, DefaultUserAgent, received.UserAgent())
+
+	_, err = fetcher.Fetch(context.Background(), Request{URL: ts.URL})
+	assert.NoError(t, err)
+	assert.Equal(t, "GET", received.Method)
+}
+
 func TestChromeFetcher_Fetch(t *testing.T) {
 	viper.Set("PROXY", "")
 	fetcher := newFetcher(Chrome)
@@ -84,7 +192,7 @@ func TestChromeFetcher_Fetch(t *testing.T) {
 		Type: "chrome",
 		URL:  "http://testserver:12345",
 	}
-	resp, err := fetcher.Fetch(req)
+	resp, err := fetcher.Fetch(context.Background(), req)
 	assert.Nil(t, err, "Expected no error")
 	assert.NotNil(t, resp, "Expected resp not nil")
 
@@ -96,7 +204,7 @@ func TestChromeFetcher_Fetch(t *testing.T) {
 		FormData: "auth_key=880ea6a14ea49e853634fbdc5015a024&referer=http%3A%2F%2Fexample.com%2F&ips_username=user&ips_password=userpassword&rememberMe=1",
 	}
 
-	resp, err = fetcher.Fetch(req)
+	resp, err = fetcher.Fetch(context.Background(), req)
 	assert.NoError(t, err)
 	assert.NotNil(t, resp, "Expected content not nil")
 
@@ -122,7 +230,7 @@ func TestChromeFetcher_Fetch(t *testing.T) {
 		URL:            "http://testserver:12345/status/200",
 		InfiniteScroll: true,
 	}
-	resp, err = fetcher.Fetch(req)
+	resp, err = fetcher.Fetch(context.Background(), req)
 	assert.Nil(t, err, "Expected no error")
 	assert.NotNil(t, resp, "Expected resp not nil")
 }
@@ -137,6 +245,17 @@ func Test_parseFormData(t *testing.T) {
 		values)
 }
 
+func TestChromeFetcher_Proxy(t *testing.T) {
+	fetcher := newFetcher(Chrome)
+	for _, req := range []Request{
+		{Type: "chrome", URL: "http://example.com", Proxy: "http://proxy:3128"},
+		{Type: "chrome", URL: "http://example.com", ProxyGroup: "de"},
+	} {
+		_, err := fetcher.Fetch(context.Background(), req)
+		assert.IsType(t, &errs.BadRequest{}, err)
+	}
+}
+
 func TestInvalidFetcher(t *testing.T) {
 	var fType Type
 	fType = "unknownFetcher"
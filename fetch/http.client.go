@@ -4,15 +4,15 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
-	"errors"
-	"io"
 	"io/ioutil"
+	"mime"
 	"net/http"
 	"net/url"
 	"strings"
 
 	"github.com/go-kit/kit/endpoint"
 	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/slotix/dataflowkit/errs"
 )
 
 // NewHTTPClient returns an Fetch Service backed by an HTTP server living at the
@@ -65,13 +65,34 @@ func encodeRequest(ctx context.Context, r *http.Request, request interface{}) er
 
 func decodeFetcherContent(ctx context.Context, r *http.Response) (interface{}, error) {
 	if r.StatusCode != http.StatusOK {
-		return nil, errors.New(r.Status)
+		return nil, decodeError(r)
 	}
 	data, err := ioutil.ReadAll(r.Body)
 	if err != nil {
 		return nil, err
 	}
-	return data, nil
+	resp := readHeader(r.Header)
+	if mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err == nil && mediaType == "multipart/mixed" {
+		if err = resp.readParts(bytes.NewReader(data), params["boundary"]); err != nil {
+			return nil, err
+		}
+		return resp, nil
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(data))
+	return resp, nil
+}
+
+// decodeError returns error with status code of the fetch service response. Error message is read from response body if possible.
+// Errors without status code header are failures of fetch service itself.
+func decodeError(r *http.Response) error {
+	e := &errs.StatusError{StatusCode: r.StatusCode, Err: r.Status, Internal: r.Header.Get(StatusCodeHeader) == ""}
+	var body struct {
+		Error string `json:"error"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err == nil && body.Error != "" {
+		e.Err = body.Error
+	}
+	return e
 }
 
 func copyURL(base *url.URL, path string) *url.URL {
@@ -80,14 +101,12 @@ func copyURL(base *url.URL, path string) *url.URL {
 	return &next
 }
 
-func (e endpoints) Fetch(req Request) (io.ReadCloser, error) {
-	ctx := context.Background()
+func (e endpoints) Fetch(ctx context.Context, req Request) (*Response, error) {
 	var resp interface{}
 	var err error
 	resp, err = e.fetchEndpoint(ctx, req)
 	if err != nil {
 		return nil, err
 	}
-	readCloser := ioutil.NopCloser(bytes.NewReader(resp.([]byte)))
-	return readCloser, nil
+	return resp.(*Response), nil
 }
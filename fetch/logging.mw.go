@@ -1,7 +1,7 @@
 package fetch
 
 import (
-	"io"
+	"context"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -22,7 +22,7 @@ type loggingMiddleware struct {
 }
 
 // Fetch logs requests to Fetch endpoint
-func (mw loggingMiddleware) Fetch(req Request) (out io.ReadCloser, err error) {
+func (mw loggingMiddleware) Fetch(ctx context.Context, req Request) (out *Response, err error) {
 	defer func(begin time.Time) {
 		url := req.getURL()
 		if err == nil {
@@ -31,10 +31,12 @@ func (mw loggingMiddleware) Fetch(req Request) (out io.ReadCloser, err error) {
 					"fetcher": req.Type,
 					"func":    "Fetch",
 					"took":    time.Since(begin),
+					"status":  out.StatusCode,
+					"cached":  out.FromCache,
 				}).Info("Fetch URL: ", url)
 		}
 		//don't log errors here. They all will be reported at transport.go func encodeError()
 	}(time.Now())
-	out, err = mw.Service.Fetch(req)
+	out, err = mw.Service.Fetch(ctx, req)
 	return
 }
@@ -0,0 +1,104 @@
+package fetch
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Headers of /fetch endpoint response which carry metadata of the web server response.
+// Web server response headers are passed prefixed with HeaderPrefix. So they never clash with headers of fetch service itself.
+const (
+	StatusCodeHeader = "X-Fetch-Status-Code"
+	FinalURLHeader   = "X-Fetch-Url"
+	StartedHeader    = "X-Fetch-Started"
+	DurationHeader   = "X-Fetch-Duration"
+	CacheHeader      = "X-Fetch-Cache"
+	ArtifactHeader   = "X-Fetch-Artifact"
+	HeaderPrefix     = "X-Fetch-Header-"
+)
+
+// Response is returned by fetchers. It contains the document along with metadata of the web server response.
+type Response struct {
+	//StatusCode of the final response
+	StatusCode int
+	//URL is the final URL of the document after all redirects are followed. It should be used as a base URL for relative links.
+	URL string
+	//Header contains response headers of the web server
+	Header http.Header
+	//Cookies set by the web server
+	Cookies []*http.Cookie
+	//Started is the time when request was sent
+	Started time.Time
+	//Duration is time spent before the document is received
+	Duration time.Duration
+	//FromCache is true if the document is returned from cache
+	FromCache bool
+	//Artifact is a storage key of the screenshot or PDF captured by Chrome fetcher if it is requested with Request.Capture
+	Artifact string
+	//XHR contains responses to XHR and fetch requests of the page captured by Chrome fetcher if Request.XHR is specified
+	XHR []XHR
+	//Body is the content of the document. It should be closed by the caller.
+	Body io.ReadCloser
+}
+
+// newResponse creates Response from http.Response of BaseFetcher.
+func newResponse(resp *http.Response, started time.Time) *Response {
+	return &Response{
+		StatusCode: resp.StatusCode,
+		URL:        resp.Request.URL.String(),
+		Header:     resp.Header,
+		Cookies:    resp.Cookies(),
+		Started:    started,
+		Duration:   time.Since(started),
+		Body:       resp.Body,
+	}
+}
+
+// ContentType returns Content-Type header of the response.
+func (r *Response) ContentType() string {
+	return r.Header.Get("Content-Type")
+}
+
+// writeHeader copies response metadata to headers of /fetch endpoint response.
+func (r *Response) writeHeader(h http.Header) {
+	for k, v := range r.Header {
+		h[HeaderPrefix+k] = v
+	}
+	if ct := r.ContentType(); ct != "" {
+		h.Set("Content-Type", ct)
+	}
+	h.Set(StatusCodeHeader, strconv.Itoa(r.StatusCode))
+	h.Set(FinalURLHeader, r.URL)
+	h.Set(StartedHeader, r.Started.Format(time.RFC3339Nano))
+	h.Set(DurationHeader, r.Duration.String())
+	if r.FromCache {
+		h.Set(CacheHeader, "HIT")
+	}
+	if r.Artifact != "" {
+		h.Set(ArtifactHeader, r.Artifact)
+	}
+}
+
+// readHeader restores response metadata from headers of /fetch endpoint response.
+// Cookies are parsed from Set-Cookie headers of the web server.
+func readHeader(h http.Header) *Response {
+	r := &Response{
+		URL:    h.Get(FinalURLHeader),
+		Header: http.Header{},
+	}
+	for k, v := range h {
+		if strings.HasPrefix(k, HeaderPrefix) {
+			r.Header[strings.TrimPrefix(k, HeaderPrefix)] = v
+		}
+	}
+	r.StatusCode, _ = strconv.Atoi(h.Get(StatusCodeHeader))
+	r.Started, _ = time.Parse(time.RFC3339Nano, h.Get(StartedHeader))
+	r.Duration, _ = time.ParseDuration(h.Get(DurationHeader))
+	r.FromCache = h.Get(CacheHeader) == "HIT"
+	r.Artifact = h.Get(ArtifactHeader)
+	r.Cookies = (&http.Response{Header: r.Header}).Cookies()
+	return r
+}
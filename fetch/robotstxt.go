@@ -1,6 +1,7 @@
 package fetch
 
 import (
+	"context"
 	"net/http"
 	"net/url"
 	"strings"
@@ -15,9 +16,9 @@ func isRobotsTxt(url string) bool {
 }
 
 //fetchRobots is used for getting robots.txt files.
-func fetchRobots(req Request) (*http.Response, error) {
+func fetchRobots(ctx context.Context, req Request) (*http.Response, error) {
 	fetcher := newBaseFetcher()
-	return fetcher.response(req)
+	return fetcher.response(ctx, req)
 }
 
 //AssembleRobotstxtURL robots.txt URL from URL
@@ -40,7 +41,7 @@ func RobotstxtData(url string) (robotsData *robotstxt.RobotsData, err error) {
 	r := Request{URL: robotsURL, Method: "GET"}
 
 	//response, err := fetchRobots(r)
-	response, err := fetchRobots(r)
+	response, err := fetchRobots(context.Background(), r)
 
 	if err != nil {
 		return nil, err
@@ -61,8 +62,8 @@ func RobotstxtData(url string) (robotsData *robotstxt.RobotsData, err error) {
 	return
 }
 
-//AllowedByRobots checks if scraping of specified URL is allowed by robots.txt
-func AllowedByRobots(rawurl string, robotsData *robotstxt.RobotsData) bool {
+//AllowedByRobots checks if scraping of specified URL is allowed by robots.txt for the User-Agent. DefaultUserAgent is used if agent is empty.
+func AllowedByRobots(rawurl string, robotsData *robotstxt.RobotsData, agent string) bool {
 	if robotsData == nil {
 		return true
 	}
@@ -70,13 +71,19 @@ func AllowedByRobots(rawurl string, robotsData *robotstxt.RobotsData) bool {
 	if err != nil {
 		logger.Error("err")
 	}
-	return robotsData.TestAgent(parsedURL.Path, "DataflowKitBot")
+	if agent == "" {
+		agent = DefaultUserAgent
+	}
+	return robotsData.TestAgent(parsedURL.Path, agent)
 }
 
-//getCrawlDelay retrieves Crawl-delay directive from robots.txt. Crawl-delay is not in the standard robots.txt protocol, and according to Wikipedia, some bots have different interpretations for this value. That's why maybe many websites don't even bother defining the rate limits in robots.txt. Crawl-delay value does not have an effect on delays between consecutive requests to the same domain for the moment. FetchDelay and RandomizeFetchDelay from ScrapeOptions are used for throttling a crawler speed.
-func getCrawlDelay(r *robotstxt.RobotsData) time.Duration {
+//CrawlDelay retrieves Crawl-delay directive from robots.txt. Crawl-delay is not in the standard robots.txt protocol, and according to Wikipedia, some bots have different interpretations for this value. That's why maybe many websites don't even bother defining the rate limits in robots.txt. Crawl-delay value is used by scraper as a minimum delay between consecutive requests to the same domain. FetchDelay and RandomizeFetchDelay are applied if they give longer delay.
+func CrawlDelay(r *robotstxt.RobotsData, agent string) time.Duration {
+	if agent == "" {
+		agent = DefaultUserAgent
+	}
 	if r != nil {
-		group := r.FindGroup("DataflowKitBot")
+		group := r.FindGroup(agent)
 		return group.CrawlDelay
 	}
 	return 0
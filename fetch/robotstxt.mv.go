@@ -1,7 +1,7 @@
 package fetch
 
 import (
-	"io"
+	"context"
 
 	"github.com/slotix/dataflowkit/errs"
 )
@@ -19,16 +19,16 @@ type robotstxtMiddleware struct {
 
 //Fetch gets response from req.URL, then passes response.URL to Robots.txt validator.
 //issue #1 https://github.com/slotix/dataflowkit/issues/1
-func (mw robotstxtMiddleware) Fetch(req Request) (io.ReadCloser, error) {
+func (mw robotstxtMiddleware) Fetch(ctx context.Context, req Request) (*Response, error) {
 	url := req.getURL()
 	//to avoid recursion while retrieving robots.txt
 	if !isRobotsTxt(url) {
 		robotsData, _ := RobotstxtData(url)
 		//robots.txt may be empty but we have to continue processing the page
-		if !AllowedByRobots(url, robotsData) {
+		if !AllowedByRobots(url, robotsData, req.UserAgent) {
 			//no need a body retrieve to get information about redirects
-			r := Request{URL: url, Method: "HEAD"}
-			resp, err := fetchRobots(r)
+			r := Request{URL: url, Method: "HEAD", UserAgent: req.UserAgent}
+			resp, err := fetchRobots(ctx, r)
 			if err != nil {
 				return nil, err
 			}
@@ -42,7 +42,7 @@ func (mw robotstxtMiddleware) Fetch(req Request) (io.ReadCloser, error) {
 				if err != nil {
 					return nil, err
 				}
-				if !AllowedByRobots(finalURL, robotsData) {
+				if !AllowedByRobots(finalURL, robotsData, req.UserAgent) {
 					return nil, &errs.ForbiddenByRobots{finalURL}
 				}
 			} else {
@@ -52,5 +52,5 @@ func (mw robotstxtMiddleware) Fetch(req Request) (io.ReadCloser, error) {
 
 	}
 
-	return mw.Service.Fetch(req)
+	return mw.Service.Fetch(ctx, req)
 }
@@ -20,15 +20,22 @@ func TestRobotstxtData(t *testing.T) {
 	//test AllowedByRobots func
 	robots, err := robotstxt.FromString(robotsContent)
 	assert.NoError(t, err, "No error returned")
-	assert.Equal(t, true, AllowedByRobots("http://"+addr+"/allowed", robots), "Test allowed url")
-	assert.Equal(t, false, AllowedByRobots("http://"+addr+"/disallowed", robots), "Test disallowed url")
-	assert.Equal(t, time.Duration(0), getCrawlDelay(robots))
+	assert.Equal(t, true, AllowedByRobots("http://"+addr+"/allowed", robots, ""), "Test allowed url")
+	assert.Equal(t, false, AllowedByRobots("http://"+addr+"/disallowed", robots, ""), "Test disallowed url")
+	assert.Equal(t, time.Duration(0), CrawlDelay(robots, ""))
+	//rules are matched against the User-Agent
+	agentRobots, err := robotstxt.FromString("User-agent: BadBot\nDisallow: /\nCrawl-delay: 5\n\nUser-agent: *\nAllow: /")
+	assert.NoError(t, err)
+	assert.False(t, AllowedByRobots("http://"+addr+"/allowed", agentRobots, "BadBot/2.1"))
+	assert.True(t, AllowedByRobots("http://"+addr+"/allowed", agentRobots, ""))
+	assert.Equal(t, 5*time.Second, CrawlDelay(agentRobots, "BadBot/2.1"))
 	robots = nil
-	assert.Equal(t, true, AllowedByRobots("http://"+addr+"/allowed", robots), "Test allowed url")
+	assert.Equal(t, true, AllowedByRobots("http://"+addr+"/allowed", robots, ""), "Test allowed url")
 	serverCfg := Config{
 		Host: viper.GetString("DFK_FETCH"),
 	}
-	htmlServer := Start(serverCfg)
+	htmlServer, err := Start(serverCfg)
+	assert.NoError(t, err)
 
 	////////
 	rd, err := RobotstxtData(tsURL)
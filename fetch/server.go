@@ -22,16 +22,22 @@ type HTMLServer struct {
 	wg     sync.WaitGroup
 }
 
-// Start func launches Parsing service
-func Start(cfg Config) *HTMLServer {
+// Start func launches Fetch service.
+// Error is returned if proxies specified by PROXY_FILE can't be loaded. So requests are never sent directly instead of through the proxies.
+func Start(cfg Config) (*HTMLServer, error) {
 	// Setup Context
 	ctx := context.Background()
 	_, cancel := context.WithCancel(ctx)
 	defer cancel()
 	logger := log.NewLogger(false)
 
+	proxies, err := newProxyPool()
+	if err != nil {
+		return nil, err
+	}
 	var svc Service
-	svc = FetchService{}
+	svc = FetchService{Proxies: proxies}
+	svc = CacheMiddleware()(svc)
 
 	//svc = RobotsTxtMiddleware()(svc)
 	svc = LoggingMiddleware(logger)(svc)
@@ -40,7 +46,7 @@ func Start(cfg Config) *HTMLServer {
 		fetchEndpoint: makeFetchEndpoint(svc),
 	}
 
-	r := newHttpHandler(ctx, endpoints, logger)
+	r := newHttpHandler(ctx, endpoints, proxies, logger)
 
 	// Create the HTML Server
 	htmlServer := HTMLServer{
@@ -59,7 +65,7 @@ func Start(cfg Config) *HTMLServer {
 		htmlServer.server.ListenAndServe()
 		htmlServer.wg.Done()
 	}()
-	return &htmlServer
+	return &htmlServer, nil
 }
 
 // Stop turns off the HTML Server
@@ -82,6 +88,8 @@ func (htmlServer *HTMLServer) Stop() error {
 	}
 	// Wait for the listener to report that it is closed.
 	htmlServer.wg.Wait()
+	transports().CloseIdleConnections()
+	chromeTabs().CloseIdle()
 	fmt.Printf("\nFetch Server : Stopped\n")
 	return nil
 }
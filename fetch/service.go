@@ -1,8 +1,8 @@
 package fetch
 
 import (
+	"context"
 	"encoding/json"
-	"io"
 	"net/http"
 	"net/http/cookiejar"
 	"net/url"
@@ -14,18 +14,21 @@ import (
 
 // Service defines Fetch service interface
 type Service interface {
-	Fetch(req Request) (io.ReadCloser, error)
+	Fetch(ctx context.Context, req Request) (*Response, error)
 }
 
-// FetchService implements service with empty struct
+// FetchService implements Fetch service
 type FetchService struct {
+	//Proxies is a pool of proxies used by Base fetcher. Requests are sent directly if it is nil.
+	Proxies *ProxyPool
 }
 
 // ServiceMiddleware defines a middleware for a Fetch service
 type ServiceMiddleware func(Service) Service
 
 // Fetch method implements fetching content from web page with Base or Chrome fetcher.
-func (fs FetchService) Fetch(req Request) (io.ReadCloser, error) {
+// Fetching is stopped as soon as ctx is cancelled.
+func (fs FetchService) Fetch(ctx context.Context, req Request) (*Response, error) {
 	var fetcher Fetcher
 	switch req.Type {
 	case "chrome":
@@ -80,7 +83,21 @@ func (fs FetchService) Fetch(req Request) (io.ReadCloser, error) {
 		}
 	}
 	fetcher.setCookieJar(jar)
-	res, err := fetcher.Fetch(req)
+	//proxies are not applied to Chrome. It uses proxy specified by its --proxy-server flag.
+	var px *proxy
+	if bf, ok := fetcher.(*BaseFetcher); ok {
+		px, err = fs.Proxies.pick(req)
+		if err != nil {
+			return nil, err
+		}
+		if px != nil {
+			bf.setProxy(px.url)
+		}
+	}
+	res, err := fetcher.Fetch(ctx, req)
+	if px != nil {
+		fs.Proxies.report(px, err)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -2,6 +2,7 @@ package fetch
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -35,7 +36,8 @@ func TestFetchServiceMW(t *testing.T) {
 	serverCfg := Config{
 		Host: fetchServer,
 	}
-	htmlServer := Start(serverCfg)
+	htmlServer, err := Start(serverCfg)
+	assert.NoError(t, err)
 	defer htmlServer.Stop()
 
 	svc, err := NewHTTPClient(fetchServer)
@@ -71,7 +73,7 @@ func TestFetchServiceMW(t *testing.T) {
 		t.Log(err)
 	}
 
-	data, err := svc.Fetch(Request{
+	data, err := svc.Fetch(context.Background(), Request{
 		Type:      "base",
 		URL:       tsURL + "/hello",
 		Method:    "GET",
@@ -82,7 +84,7 @@ func TestFetchServiceMW(t *testing.T) {
 	assert.NotNil(t, data, "Expected response is not nil")
 
 	//read cookies
-	data, err = svc.Fetch(Request{
+	data, err = svc.Fetch(context.Background(), Request{
 		Type:      "base",
 		URL:       tsURL,
 		Method:    "GET",
@@ -111,13 +113,13 @@ func TestFetchServiceMW(t *testing.T) {
 			Type: "base",
 			URL:  url,
 		}
-		_, err := svc.Fetch(req)
+		_, err := svc.Fetch(context.Background(), req)
 		t.Log(err)
 		assert.Error(t, err, fmt.Sprintf("%T", err)+"error returned")
 	}
 
 	//invalid URL
-	_, err = svc.Fetch(Request{
+	_, err = svc.Fetch(context.Background(), Request{
 		Type:   "base",
 		URL:    "invalid_addr",
 		Method: "GET",
@@ -125,7 +127,7 @@ func TestFetchServiceMW(t *testing.T) {
 	assert.Error(t, err, "Expected error")
 
 	//invalid Fetcher type
-	_, err = svc.Fetch(Request{
+	_, err = svc.Fetch(context.Background(), Request{
 		Type:   "invalid",
 		URL:    "invalid_addr",
 		Method: "GET",
@@ -133,7 +135,7 @@ func TestFetchServiceMW(t *testing.T) {
 	assert.Error(t, err, "Expected error")
 
 	//disallowed by robots
-	_, err = svc.Fetch(Request{
+	_, err = svc.Fetch(context.Background(), Request{
 		Type:      "base",
 		URL:       tsURL + "/disallowed",
 		Method:    "GET",
@@ -143,7 +145,7 @@ func TestFetchServiceMW(t *testing.T) {
 	assert.Error(t, err, "Expected error")
 
 	//disallowed by robots
-	_, err = svc.Fetch(Request{
+	_, err = svc.Fetch(context.Background(), Request{
 		Type:      "base",
 		URL:       tsURL + "/redirect",
 		Method:    "GET",
@@ -154,7 +156,7 @@ func TestFetchServiceMW(t *testing.T) {
 
 	//Test Chrome Fetcher
 	//svcChrome := FetchService{}
-	_, err = svc.Fetch(Request{
+	_, err = svc.Fetch(context.Background(), Request{
 		Type:      "chrome",
 		URL:       "http://testserver:12345",
 		FormData:  "",
@@ -164,7 +166,7 @@ func TestFetchServiceMW(t *testing.T) {
 
 	svc1 := FetchService{}
 	//Pass invalid Fetcher type directly to service skipping NewHTTPClient
-	_, err = svc1.Fetch(Request{
+	_, err = svc1.Fetch(context.Background(), Request{
 		Type:   "invalid",
 		URL:    "invalid_addr",
 		Method: "GET",
@@ -174,7 +176,7 @@ func TestFetchServiceMW(t *testing.T) {
 	//Test decodeChromeFetcherContent
 	//Chrome returns empty result for erroneous pages: <html><head></head><body></body></html>
 	//And returns no error
-	data, err = svc.Fetch(Request{
+	data, err = svc.Fetch(context.Background(), Request{
 		Type: "chrome",
 		URL:  "http://testserver:12345/status/404",
 		//URL:    "http://httpbin.org/status/404",
@@ -182,7 +184,7 @@ func TestFetchServiceMW(t *testing.T) {
 	})
 	assert.NoError(t, err, "No error")
 	buf := new(bytes.Buffer)
-	buf.ReadFrom(data)
+	buf.ReadFrom(data.Body)
 	s := buf.String()
 	t.Log(s)
 
@@ -3,7 +3,9 @@ package fetch
 import (
 	"encoding/json"
 	"io"
+	"mime/multipart"
 	"net/http"
+	"strconv"
 
 	"context"
 
@@ -16,7 +18,7 @@ import (
 )
 
 // newHttpHandler mounts all of the service endpoints into an http.Handler.
-func newHttpHandler(ctx context.Context, endpoint endpoints, logger *logrus.Logger) http.Handler {
+func newHttpHandler(ctx context.Context, endpoint endpoints, proxies *ProxyPool, logger *logrus.Logger) http.Handler {
 	r := mux.NewRouter()
 	r.UseEncodedPath()
 	options := []httptransport.ServerOption{
@@ -24,6 +26,8 @@ func newHttpHandler(ctx context.Context, endpoint endpoints, logger *logrus.Logg
 		httptransport.ServerErrorEncoder(encodeError),
 	}
 	r.Methods("GET").Path("/ping").HandlerFunc(healthCheckHandler)
+	r.Methods("GET").Path("/proxies").HandlerFunc(proxyStatsHandler(proxies))
+	r.Methods("GET").Path(`/artifacts/{key:[0-9a-f]+\.(?:png|jpeg|pdf)}`).HandlerFunc(artifactHandler)
 	r.Methods("POST").Path("/fetch").Handler(httptransport.NewServer(
 		endpoint.fetchEndpoint,
 		decodeRequest,
@@ -43,16 +47,27 @@ func decodeRequest(ctx context.Context, r *http.Request) (interface{}, error) {
 	return request, nil
 }
 
-//EncodeFetcherContent encodes HTML Content returned by fetcher
+//EncodeFetcherContent encodes HTML Content returned by fetcher. Metadata of the web server response is passed in headers.
 func encodeFetcherContent(ctx context.Context, w http.ResponseWriter, response interface{}) error {
-	fetcherContent, ok := response.(io.ReadCloser)
+	resp, ok := response.(*Response)
 	if !ok {
 		e := errs.BadGateway{What: "content"}
 		encodeError(ctx, &e, w)
 		return nil
 	}
+	defer resp.Body.Close()
+	resp.writeHeader(w.Header())
 	w.Header().Set("Access-Control-Allow-Origin", "*")
-	_, err := io.Copy(w, fetcherContent)
+	//captured XHR responses are passed next to the document
+	if len(resp.XHR) > 0 {
+		mw := multipart.NewWriter(w)
+		w.Header().Set("Content-Type", "multipart/mixed; boundary="+mw.Boundary())
+		if err := resp.writeParts(mw); err != nil {
+			logger.Error(err)
+		}
+		return nil
+	}
+	_, err := io.Copy(w, resp.Body)
 	if err != nil {
 		encodeError(ctx, err, w)
 		return nil
@@ -69,18 +84,25 @@ func encodeError(_ context.Context, err error, w http.ResponseWriter) {
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 
 	var httpStatus int
-	switch err.(type) {
+	//remote is true if the status is caused by the web server, proxy or network rather than by fetch service itself
+	remote := true
+	switch e := err.(type) {
 	default:
 		httpStatus = http.StatusInternalServerError
+		remote = false
 	case *errs.BadRequest,
 		*errs.Error:
 		//return 400 Status
 		httpStatus = http.StatusBadRequest
+		remote = false
 	case *errs.Unauthorized:
 		//return 401 Status
 		httpStatus = http.StatusUnauthorized
-	case *errs.ForbiddenByRobots,
-		*errs.Forbidden:
+	case *errs.ForbiddenByRobots:
+		//return 403 Status
+		httpStatus = http.StatusForbidden
+		remote = false
+	case *errs.Forbidden:
 		//return 403 Status
 		httpStatus = http.StatusForbidden
 	case *errs.ProxyAuthenticationRequired:
@@ -89,14 +111,22 @@ func encodeError(_ context.Context, err error, w http.ResponseWriter) {
 	case *errs.NotFound:
 		//return 404 Status
 		httpStatus = http.StatusNotFound
-	case *errs.BadGateway:
+	case *errs.BadGateway,
+		*errs.NetworkError:
 		//return 502 Status
 		httpStatus = http.StatusBadGateway
 	case *errs.GatewayTimeout:
 		//return 504 Status
 		httpStatus = http.StatusGatewayTimeout
+	case *errs.StatusError:
+		//return status of the web server response
+		httpStatus = e.StatusCode
 	}
 	w.Header().Set("Access-Control-Allow-Origin", "*")
+	//clients tell failures of the fetched page from failures of fetch service by status code header
+	if remote {
+		w.Header().Set(StatusCodeHeader, strconv.Itoa(httpStatus))
+	}
 	w.WriteHeader(httpStatus)
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"error": err.Error(),
@@ -111,7 +141,15 @@ type endpoints struct {
 // MakeFetchEndpoint creates Fetch Endpoint
 func makeFetchEndpoint(svc Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
-		return svc.Fetch(request.(Request))
+		return svc.Fetch(ctx, request.(Request))
+	}
+}
+
+//proxyStatsHandler returns usage counters of proxies.
+func proxyStatsHandler(proxies *ProxyPool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(proxies.Stats())
 	}
 }
 
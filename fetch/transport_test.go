@@ -1,12 +1,17 @@
 package fetch
 
 import (
+	"context"
+	"errors"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/slotix/dataflowkit/errs"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -42,3 +47,55 @@ func TestQuery(t *testing.T) {
 		t.Errorf("query did not hit")
 	}
 }
+
+func TestEncodeError(t *testing.T) {
+	for _, tt := range []struct {
+		err      error
+		status   int
+		internal bool
+	}{
+		{&errs.StatusError{StatusCode: http.StatusServiceUnavailable}, http.StatusServiceUnavailable, false},
+		{&errs.NetworkError{URL: "http://example.com", Err: errors.New("connection refused")}, http.StatusBadGateway, false},
+		{&errs.ForbiddenByRobots{URL: "http://example.com"}, http.StatusForbidden, true},
+		{errors.New("failed to decode request"), http.StatusInternalServerError, true},
+	} {
+		w := httptest.NewRecorder()
+		encodeError(context.Background(), tt.err, w)
+		err := decodeError(w.Result())
+		assert.Equal(t, &errs.StatusError{StatusCode: tt.status, Err: tt.err.Error(), Internal: tt.internal}, err, tt.err.Error())
+	}
+}
+
+func TestEncodeFetcherContent(t *testing.T) {
+	header := http.Header{}
+	header.Set("Content-Type", "text/html; charset=utf-8")
+	header.Add("Set-Cookie", "a=1")
+	header.Add("Set-Cookie", "b=2")
+	started := time.Date(2018, 5, 1, 10, 0, 0, 0, time.UTC)
+	resp := &Response{
+		StatusCode: http.StatusOK,
+		URL:        "http://example.com/final",
+		Header:     header,
+		Started:    started,
+		Duration:   1500 * time.Millisecond,
+		Artifact:   "0123456789abcdef.png",
+		Body:       ioutil.NopCloser(strings.NewReader("<html></html>")),
+	}
+	w := httptest.NewRecorder()
+	err := encodeFetcherContent(context.Background(), w, resp)
+	assert.NoError(t, err)
+	assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+
+	decoded, err := decodeFetcherContent(context.Background(), w.Result())
+	assert.NoError(t, err)
+	r := decoded.(*Response)
+	assert.Equal(t, http.StatusOK, r.StatusCode)
+	assert.Equal(t, "http://example.com/final", r.URL)
+	assert.Equal(t, []string{"a=1", "b=2"}, r.Header["Set-Cookie"])
+	assert.Len(t, r.Cookies, 2)
+	assert.True(t, started.Equal(r.Started))
+	assert.Equal(t, 1500*time.Millisecond, r.Duration)
+	assert.Equal(t, "0123456789abcdef.png", r.Artifact)
+	body, _ := ioutil.ReadAll(r.Body)
+	assert.Equal(t, "<html></html>", string(body))
+}
@@ -0,0 +1,36 @@
+package fetch
+
+import (
+	"strings"
+	"sync/atomic"
+)
+
+// DefaultUserAgent is sent if neither Request.UserAgent nor User-Agent pool is specified.
+const DefaultUserAgent = "DataflowKitBot"
+
+// UserAgentPool rotates User-Agent strings. Every next request gets the next agent of the pool.
+type UserAgentPool struct {
+	agents []string
+	next   uint32
+}
+
+// NewUserAgentPool creates User-Agent pool. Empty values are skipped.
+func NewUserAgentPool(agents []string) *UserAgentPool {
+	pool := &UserAgentPool{}
+	for _, agent := range agents {
+		if agent = strings.TrimSpace(agent); agent != "" {
+			pool.agents = append(pool.agents, agent)
+		}
+	}
+	return pool
+}
+
+// Next returns the next User-Agent of the pool. DefaultUserAgent is returned if the pool is empty.
+// It is safe for concurrent use.
+func (p *UserAgentPool) Next() string {
+	if p == nil || len(p.agents) == 0 {
+		return DefaultUserAgent
+	}
+	i := atomic.AddUint32(&p.next, 1) - 1
+	return p.agents[int(i)%len(p.agents)]
+}
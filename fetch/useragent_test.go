@@ -0,0 +1,18 @@
+package fetch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserAgentPool(t *testing.T) {
+	pool := NewUserAgentPool([]string{"Agent1", " ", "Agent2"})
+	assert.Equal(t, "Agent1", pool.Next())
+	assert.Equal(t, "Agent2", pool.Next())
+	assert.Equal(t, "Agent1", pool.Next())
+
+	assert.Equal(t, DefaultUserAgent, NewUserAgentPool(nil).Next())
+	var nilPool *UserAgentPool
+	assert.Equal(t, DefaultUserAgent, nilPool.Next())
+}
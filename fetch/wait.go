@@ -0,0 +1,144 @@
+package fetch
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/mafredri/cdp"
+	"github.com/slotix/dataflowkit/errs"
+	"github.com/spf13/viper"
+)
+
+// Wait conditions of Chrome fetcher.
+const (
+	//WaitLoad waits for load event of the page. It is used by default.
+	WaitLoad = "load"
+	//WaitDOMContentLoaded waits for DOMContentLoaded event. Images and frames may be still loading.
+	WaitDOMContentLoaded = "domContentLoaded"
+	//WaitNetworkIdle waits until no network requests are sent for Idle milliseconds. It suits single page applications which render content after XHR.
+	WaitNetworkIdle = "networkIdle"
+	//WaitSelector waits until element matching Selector appears on the page.
+	WaitSelector = "selector"
+	//WaitExpression waits until JavaScript Expression returns true.
+	WaitExpression = "expression"
+)
+
+// WaitCondition defines when the page loaded by Chrome fetcher is ready to be captured.
+//
+// Example:
+//
+// "wait":{"type":"selector", "selector":".results"}
+type WaitCondition struct {
+	Type string `json:"type"`
+	//Selector is CSS selector of the element awaited by selector condition.
+	Selector string `json:"selector,omitempty"`
+	//Expression is JavaScript expression awaited by expression condition.
+	Expression string `json:"expression,omitempty"`
+	//Idle is a period in milliseconds without network requests for networkIdle condition. Default value is 500.
+	Idle int `json:"idle,omitempty"`
+}
+
+// waitCondition returns wait condition of the request. Load event is awaited by default.
+func (req Request) waitCondition() WaitCondition {
+	if req.Wait == nil || req.Wait.Type == "" {
+		return WaitCondition{Type: WaitLoad}
+	}
+	return *req.Wait
+}
+
+// navigationTimeout returns timeout of loading the page in Chrome. CHROME_TIMEOUT setting is used if it is not specified in the request.
+func (req Request) navigationTimeout() time.Duration {
+	if req.Timeout > 0 {
+		return time.Duration(req.Timeout) * time.Millisecond
+	}
+	if timeout := viper.GetInt("CHROME_TIMEOUT"); timeout > 0 {
+		return time.Duration(timeout) * time.Second
+	}
+	return 60 * time.Second
+}
+
+func (w WaitCondition) validate() error {
+	var err error
+	switch w.Type {
+	case "", WaitLoad, WaitDOMContentLoaded, WaitNetworkIdle:
+	case WaitSelector:
+		if w.Selector == "" {
+			err = errors.New("Wait condition selector requires selector")
+		}
+	case WaitExpression:
+		if w.Expression == "" {
+			err = errors.New("Wait condition expression requires expression")
+		}
+	default:
+		err = errors.New("Unknown wait condition " + w.Type)
+	}
+	if err != nil {
+		return &errs.BadRequest{Err: err}
+	}
+	return nil
+}
+
+// String describes the condition in timeout errors.
+func (w WaitCondition) String() string {
+	switch w.Type {
+	case WaitDOMContentLoaded:
+		return "DOMContentLoaded event"
+	case WaitNetworkIdle:
+		return "network idle"
+	case WaitSelector:
+		return "selector " + w.Selector
+	case WaitExpression:
+		return "expression " + w.Expression
+	}
+	return "load event"
+}
+
+func (w WaitCondition) idle() time.Duration {
+	if w.Idle == 0 {
+		return 500 * time.Millisecond
+	}
+	return time.Duration(w.Idle) * time.Millisecond
+}
+
+// ready subscribes to events of the page before navigation. The returned function blocks until the page is ready.
+func (w WaitCondition) ready(ctx context.Context, client *cdp.Client) (func() error, error) {
+	switch w.Type {
+	case WaitDOMContentLoaded, WaitSelector, WaitExpression:
+		domContentEventFired, err := client.Page.DOMContentEventFired(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return func() error {
+			defer domContentEventFired.Close()
+			if _, err := domContentEventFired.Recv(); err != nil {
+				return err
+			}
+			switch w.Type {
+			case WaitSelector:
+				return poll(ctx, cdpPage{client}, selectorExpression(w.Selector))
+			case WaitExpression:
+				return poll(ctx, cdpPage{client}, w.Expression)
+			}
+			return nil
+		}, nil
+	case WaitNetworkIdle:
+		watcher, err := watchNetwork(ctx, client)
+		if err != nil {
+			return nil, err
+		}
+		return func() error {
+			defer watcher.close()
+			return watcher.wait(ctx, w.idle())
+		}, nil
+	}
+	loadEventFired, err := client.Page.LoadEventFired(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return func() error {
+		defer loadEventFired.Close()
+		_, err := loadEventFired.Recv()
+		return err
+	}, nil
+}
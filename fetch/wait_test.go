@@ -0,0 +1,35 @@
+package fetch
+
+import (
+	"testing"
+	"time"
+
+	"github.com/slotix/dataflowkit/errs"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWaitCondition(t *testing.T) {
+	assert.Equal(t, WaitLoad, Request{}.waitCondition().Type)
+	assert.Equal(t, WaitLoad, Request{Wait: &WaitCondition{}}.waitCondition().Type)
+	w := Request{Wait: &WaitCondition{Type: WaitNetworkIdle}}.waitCondition()
+	assert.Equal(t, 500*time.Millisecond, w.idle())
+
+	assert.NoError(t, WaitCondition{Type: WaitDOMContentLoaded}.validate())
+	assert.NoError(t, WaitCondition{Type: WaitSelector, Selector: ".results"}.validate())
+	assert.IsType(t, &errs.BadRequest{}, WaitCondition{Type: WaitSelector}.validate())
+	assert.IsType(t, &errs.BadRequest{}, WaitCondition{Type: WaitExpression}.validate())
+	assert.IsType(t, &errs.BadRequest{}, WaitCondition{Type: "idle"}.validate())
+
+	err := &errs.GatewayTimeout{Wait: WaitCondition{Type: WaitSelector, Selector: ".results"}.String()}
+	assert.Equal(t, "504 Timeout exceeded waiting for selector .results", err.Error())
+}
+
+func TestNavigationTimeout(t *testing.T) {
+	defer viper.Set("CHROME_TIMEOUT", 0)
+	viper.Set("CHROME_TIMEOUT", 0)
+	assert.Equal(t, 60*time.Second, Request{}.navigationTimeout())
+	viper.Set("CHROME_TIMEOUT", 30)
+	assert.Equal(t, 30*time.Second, Request{}.navigationTimeout())
+	assert.Equal(t, 1500*time.Millisecond, Request{Timeout: 1500}.navigationTimeout())
+}
@@ -0,0 +1,194 @@
+package fetch
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/textproto"
+	"regexp"
+	"strconv"
+
+	"github.com/mafredri/cdp"
+	"github.com/mafredri/cdp/protocol/network"
+	"github.com/mafredri/cdp/protocol/page"
+	"github.com/slotix/dataflowkit/errs"
+)
+
+// XHR is a response to XMLHttpRequest or fetch() sent by the page rendered in Chrome.
+type XHR struct {
+	//URL of the request
+	URL string
+	//StatusCode of the response
+	StatusCode int
+	//ContentType of the response body
+	ContentType string
+	//Body of the response
+	Body []byte
+}
+
+// xhrPatterns compiles regular expressions of XHR option of the request.
+func (req Request) xhrPatterns() ([]*regexp.Regexp, error) {
+	patterns := []*regexp.Regexp{}
+	for _, p := range req.XHR {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, &errs.BadRequest{Err: fmt.Errorf("Invalid XHR pattern %s. %s", p, err.Error())}
+		}
+		patterns = append(patterns, re)
+	}
+	return patterns, nil
+}
+
+// xhrRecorder collects XHR and fetch responses of the page with URLs matching the patterns.
+type xhrRecorder struct {
+	client   *cdp.Client
+	patterns []*regexp.Regexp
+	received network.ResponseReceivedClient
+	finished network.LoadingFinishedClient
+}
+
+// recordXHR starts watching responses of the page. It should be called before navigation.
+func recordXHR(ctx context.Context, client *cdp.Client, patterns []*regexp.Regexp) (*xhrRecorder, error) {
+	var (
+		r   = &xhrRecorder{client: client, patterns: patterns}
+		err error
+	)
+	if r.received, err = client.Network.ResponseReceived(ctx); err != nil {
+		return nil, err
+	}
+	if r.finished, err = client.Network.LoadingFinished(ctx); err != nil {
+		r.received.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *xhrRecorder) close() {
+	r.received.Close()
+	r.finished.Close()
+}
+
+func (r *xhrRecorder) match(url string) bool {
+	for _, re := range r.patterns {
+		if re.MatchString(url) {
+			return true
+		}
+	}
+	return false
+}
+
+// responses returns matching responses received so far in order. Bodies are read from Chrome, so responses which are still loading are skipped.
+func (r *xhrRecorder) responses(ctx context.Context) ([]XHR, error) {
+	var (
+		ids      []network.RequestID
+		matched  = make(map[network.RequestID]network.Response)
+		finished = make(map[network.RequestID]bool)
+	)
+	//events are buffered by the streams. Only those received before are read.
+	for ready := true; ready; {
+		select {
+		case <-r.received.Ready():
+			ev, err := r.received.Recv()
+			if err != nil {
+				return nil, err
+			}
+			if (ev.Type == page.ResourceTypeXHR || ev.Type == page.ResourceTypeFetch) && r.match(ev.Response.URL) {
+				ids = append(ids, ev.RequestID)
+				matched[ev.RequestID] = ev.Response
+			}
+		case <-r.finished.Ready():
+			ev, err := r.finished.Recv()
+			if err != nil {
+				return nil, err
+			}
+			finished[ev.RequestID] = true
+		default:
+			ready = false
+		}
+	}
+	xhr := []XHR{}
+	for _, id := range ids {
+		resp := matched[id]
+		if !finished[id] {
+			logger.Warningf("XHR %s is still loading", resp.URL)
+			continue
+		}
+		reply, err := r.client.Network.GetResponseBody(ctx, network.NewGetResponseBodyArgs(id))
+		if err != nil {
+			return nil, err
+		}
+		body := []byte(reply.Body)
+		if reply.Base64Encoded {
+			if body, err = base64.StdEncoding.DecodeString(reply.Body); err != nil {
+				return nil, err
+			}
+		}
+		xhr = append(xhr, XHR{
+			URL:         resp.URL,
+			StatusCode:  resp.Status,
+			ContentType: resp.MimeType,
+			Body:        body,
+		})
+	}
+	return xhr, nil
+}
+
+// writeParts writes the document followed by XHR responses as parts of multipart/mixed body.
+func (r *Response) writeParts(mw *multipart.Writer) error {
+	h := textproto.MIMEHeader{}
+	h.Set("Content-Type", r.ContentType())
+	part, err := mw.CreatePart(h)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(part, r.Body); err != nil {
+		return err
+	}
+	for _, x := range r.XHR {
+		h := textproto.MIMEHeader{}
+		h.Set("Content-Type", x.ContentType)
+		h.Set(FinalURLHeader, x.URL)
+		h.Set(StatusCodeHeader, strconv.Itoa(x.StatusCode))
+		part, err := mw.CreatePart(h)
+		if err != nil {
+			return err
+		}
+		if _, err = part.Write(x.Body); err != nil {
+			return err
+		}
+	}
+	return mw.Close()
+}
+
+// readParts restores the document and XHR responses from multipart/mixed body.
+func (r *Response) readParts(body io.Reader, boundary string) error {
+	mr := multipart.NewReader(body, boundary)
+	for i := 0; ; i++ {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		data, err := ioutil.ReadAll(part)
+		if err != nil {
+			return err
+		}
+		if i == 0 {
+			r.Body = ioutil.NopCloser(bytes.NewReader(data))
+			continue
+		}
+		x := XHR{
+			URL:         part.Header.Get(FinalURLHeader),
+			ContentType: part.Header.Get("Content-Type"),
+			Body:        data,
+		}
+		x.StatusCode, _ = strconv.Atoi(part.Header.Get(StatusCodeHeader))
+		r.XHR = append(r.XHR, x)
+	}
+}
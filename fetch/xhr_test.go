@@ -0,0 +1,52 @@
+package fetch
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/slotix/dataflowkit/errs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestXHRPatterns(t *testing.T) {
+	patterns, err := Request{XHR: []string{`/api/products\?page=\d+`, "search"}}.xhrPatterns()
+	assert.NoError(t, err)
+	r := &xhrRecorder{patterns: patterns}
+	assert.True(t, r.match("http://example.com/api/products?page=2"))
+	assert.True(t, r.match("http://example.com/search?q=books"))
+	assert.False(t, r.match("http://example.com/api/products"))
+
+	_, err = Request{XHR: []string{"(api"}}.xhrPatterns()
+	assert.IsType(t, &errs.BadRequest{}, err)
+}
+
+func TestEncodeFetcherContent_XHR(t *testing.T) {
+	header := http.Header{}
+	header.Set("Content-Type", "text/html; charset=utf-8")
+	resp := &Response{
+		StatusCode: http.StatusOK,
+		URL:        "http://example.com/",
+		Header:     header,
+		Body:       ioutil.NopCloser(strings.NewReader("<html></html>")),
+		XHR: []XHR{
+			{URL: "http://example.com/api/products", StatusCode: 200, ContentType: "application/json", Body: []byte(`{"products":[]}`)},
+			{URL: "http://example.com/api/user", StatusCode: 401, ContentType: "application/json", Body: []byte(`{}`)},
+		},
+	}
+	w := httptest.NewRecorder()
+	err := encodeFetcherContent(context.Background(), w, resp)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(w.Header().Get("Content-Type"), "multipart/mixed; boundary="))
+
+	decoded, err := decodeFetcherContent(context.Background(), w.Result())
+	assert.NoError(t, err)
+	r := decoded.(*Response)
+	assert.Equal(t, "text/html; charset=utf-8", r.ContentType())
+	body, _ := ioutil.ReadAll(r.Body)
+	assert.Equal(t, "<html></html>", string(body))
+	assert.Equal(t, resp.XHR, r.XHR)
+}
@@ -9,6 +9,8 @@ import (
 
 	"github.com/PuerkitoBio/goquery"
 	"github.com/slotix/dataflowkit/utils"
+	"github.com/slotix/dataflowkit/xpath"
+	"golang.org/x/net/html"
 )
 
 // The Paginator interface should be implemented by things that can retrieve the
@@ -23,21 +25,28 @@ type Paginator interface {
 }
 
 type bySelectorPaginator struct {
-	sel  string
-	attr string
+	sel    string
+	attr   string
+	isHTML bool
 }
 
 // BySelector returns a Paginator that extracts the next page from a document by
 // querying a given CSS selector and extracting the given HTML attribute from the
-// resulting element.
-func BySelector(sel, attr string) Paginator {
+// resulting element. Selector may be an XPath expression with "xpath:" prefix.
+// Attribute is ignored if the expression selects an attribute or text like "xpath://a[@rel='next']/@href".
+// isHTML is false for XML documents, whose names are matched by XPath case-sensitively.
+func BySelector(sel, attr string, isHTML bool) Paginator {
 	return &bySelectorPaginator{
-		sel: sel, attr: attr,
+		sel: sel, attr: attr, isHTML: isHTML,
 	}
 }
 
 func (p *bySelectorPaginator) NextPage(uri string, doc *goquery.Selection) (string, error) {
-	val, found := doc.Find(p.sel).Attr(p.attr)
+	next := xpath.Find(doc, p.sel, p.isHTML).First()
+	val, found := next.Attr(p.attr)
+	if next.Length() > 0 && next.Nodes[0].Type == html.TextNode {
+		val, found = next.Text(), true
+	}
 	if !found {
 		return "", nil
 	}
@@ -24,25 +24,35 @@ func selFrom(s string) *goquery.Selection {
 func TestBySelector(t *testing.T) {
 	sel := selFrom(`<a href="http://www.google.com">foo</a>`)
 
-	pg, err := BySelector("a", "href").NextPage("", sel)
+	pg, err := BySelector("a", "href", true).NextPage("", sel)
 	assert.NoError(t, err)
 	assert.Equal(t, pg, "http://www.google.com")
 
-	pg, err = BySelector("div", "xxx").NextPage("", sel)
+	pg, err = BySelector("div", "xxx", true).NextPage("", sel)
 	assert.NoError(t, err)
 	assert.Equal(t, pg, "")
 
 	sel = selFrom(`<a href="/foobar">foo</a>`)
 
-	pg, err = BySelector("a", "href").NextPage("http://www.google.com", sel)
+	pg, err = BySelector("a", "href", true).NextPage("http://www.google.com", sel)
 	assert.NoError(t, err)
 	assert.Equal(t, pg, "http://www.google.com/foobar")
 
 	sel = selFrom(`<a href="asdf?q=123">foo</a>`)
 
-	pg, err = BySelector("a", "href").NextPage("http://www.google.com", sel)
+	pg, err = BySelector("a", "href", true).NextPage("http://www.google.com", sel)
 	assert.NoError(t, err)
 	assert.Equal(t, pg, "http://www.google.com/asdf?q=123")
+
+	sel = selFrom(`<a href="?page=1">1</a><a rel="next" href="?page=2">next</a>`)
+
+	pg, err = BySelector("xpath://a[text()='next']", "href", true).NextPage("http://www.google.com", sel)
+	assert.NoError(t, err)
+	assert.Equal(t, pg, "http://www.google.com?page=2")
+
+	pg, err = BySelector("xpath://a[@rel='next']/@href", "", true).NextPage("http://www.google.com", sel)
+	assert.NoError(t, err)
+	assert.Equal(t, pg, "http://www.google.com?page=2")
 }
 
 func TestByQueryParam(t *testing.T) {
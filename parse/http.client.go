@@ -44,11 +44,49 @@ func NewHTTPClient(instance string) (Service, error) {
 		).Endpoint()
 	}
 
+	//Response body is copied to the writer while it is being received.
+	//So stream endpoint is not an http/transport.Client which closes the body once endpoint returns.
+	streamEndpoint := makeStreamClient(copyURL(u, "/parse/stream"))
+
+	var submitEndpoint endpoint.Endpoint
+	{
+		submitEndpoint = httptransport.NewClient(
+			"POST",
+			copyURL(u, "/tasks"),
+			encodeParseRequest,
+			decodeTaskInfo,
+		).Endpoint()
+	}
+
+	var statusEndpoint endpoint.Endpoint
+	{
+		statusEndpoint = httptransport.NewClient(
+			"GET",
+			copyURL(u, "/tasks"),
+			encodeTaskRequest(""),
+			decodeTaskInfo,
+		).Endpoint()
+	}
+
+	var resultEndpoint endpoint.Endpoint
+	{
+		resultEndpoint = httptransport.NewClient(
+			"GET",
+			copyURL(u, "/tasks"),
+			encodeTaskRequest("/result"),
+			decodeParseResponse,
+		).Endpoint()
+	}
+
 	// Returning the endpoint.Set as a service.Service relies on the
 	// endpoint.Set implementing the Service methods. That's just a simple bit
 	// of glue code.
 	return Endpoints{
-		ParseEndpoint: parseEndpoint,
+		ParseEndpoint:  parseEndpoint,
+		StreamEndpoint: streamEndpoint,
+		SubmitEndpoint: submitEndpoint,
+		StatusEndpoint: statusEndpoint,
+		ResultEndpoint: resultEndpoint,
 	}, nil
 }
 
@@ -74,6 +112,51 @@ func decodeParseResponse(ctx context.Context, r *http.Response) (interface{}, er
 	return data, nil
 }
 
+// makeStreamClient returns endpoint which posts payload to parse service and copies streamed results to the request writer.
+func makeStreamClient(u *url.URL) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(streamRequest)
+		var buf bytes.Buffer
+		if err := json.NewEncoder(&buf).Encode(req.payload); err != nil {
+			return nil, err
+		}
+		r, err := http.NewRequest("POST", u.String(), &buf)
+		if err != nil {
+			return nil, err
+		}
+		r.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(r.WithContext(ctx))
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, errors.New(resp.Status)
+		}
+		_, err = io.Copy(req.w, resp.Body)
+		return nil, err
+	}
+}
+
+// encodeTaskRequest returns transport/http.EncodeRequestFunc which puts task ID into request path.
+func encodeTaskRequest(suffix string) httptransport.EncodeRequestFunc {
+	return func(ctx context.Context, r *http.Request, request interface{}) error {
+		r.URL.Path = "/tasks/" + url.PathEscape(request.(string)) + suffix
+		return nil
+	}
+}
+
+func decodeTaskInfo(ctx context.Context, r *http.Response) (interface{}, error) {
+	if r.StatusCode != http.StatusOK && r.StatusCode != http.StatusAccepted {
+		return nil, errors.New(r.Status)
+	}
+	info := &scrape.TaskInfo{}
+	if err := json.NewDecoder(r.Body).Decode(info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
 func copyURL(base *url.URL, path string) *url.URL {
 	next := *base
 	next.Path = path
@@ -81,8 +164,7 @@ func copyURL(base *url.URL, path string) *url.URL {
 }
 
 // Parse method is used for sending payload requests to parse service.
-func (e Endpoints) Parse(p scrape.Payload) (io.ReadCloser, error) {
-	ctx := context.Background()
+func (e Endpoints) Parse(ctx context.Context, p scrape.Payload) (io.ReadCloser, error) {
 	resp, err := e.ParseEndpoint(ctx, p)
 	if err != nil {
 		return nil, err
@@ -91,3 +173,36 @@ func (e Endpoints) Parse(p scrape.Payload) (io.ReadCloser, error) {
 	return readCloser, nil
 
 }
+
+// ParseTo sends payload to parse service and writes streamed results to w.
+func (e Endpoints) ParseTo(ctx context.Context, p scrape.Payload, w io.Writer) error {
+	_, err := e.StreamEndpoint(ctx, streamRequest{payload: p, w: w})
+	return err
+}
+
+// Submit sends payload to parse service to be processed in background.
+func (e Endpoints) Submit(p scrape.Payload) (*scrape.TaskInfo, error) {
+	resp, err := e.SubmitEndpoint(context.Background(), p)
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*scrape.TaskInfo), nil
+}
+
+// Status requests the state of the task with specified ID.
+func (e Endpoints) Status(id string) (*scrape.TaskInfo, error) {
+	resp, err := e.StatusEndpoint(context.Background(), id)
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*scrape.TaskInfo), nil
+}
+
+// Result downloads results of the finished task.
+func (e Endpoints) Result(id string) (io.ReadCloser, error) {
+	resp, err := e.ResultEndpoint(context.Background(), id)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(bytes.NewReader(resp.([]byte))), nil
+}
@@ -1,6 +1,7 @@
 package parse
 
 import (
+	"context"
 	"io"
 	"time"
 
@@ -23,9 +24,9 @@ type loggingMiddleware struct {
 }
 
 // Logging Parse Service
-func (mw loggingMiddleware) Parse(payload scrape.Payload) (output io.ReadCloser, err error) {
+func (mw loggingMiddleware) Parse(ctx context.Context, payload scrape.Payload) (output io.ReadCloser, err error) {
 	defer func(begin time.Time) {
-		output, err = mw.Service.Parse(payload)
+		output, err = mw.Service.Parse(ctx, payload)
 		url := payload.Request.URL
 		if err != nil {
 			mw.logger.WithFields(
@@ -44,3 +45,25 @@ func (mw loggingMiddleware) Parse(payload scrape.Payload) (output io.ReadCloser,
 	}(time.Now())
 	return
 }
+
+// Submit logs submitted tasks
+func (mw loggingMiddleware) Submit(payload scrape.Payload) (info *scrape.TaskInfo, err error) {
+	defer func(begin time.Time) {
+		url := payload.Request.URL
+		if err != nil {
+			mw.logger.WithFields(
+				logrus.Fields{
+					"err":  err,
+					"took": time.Since(begin),
+				}).Error("Submit URL: ", url)
+		} else {
+			mw.logger.WithFields(
+				logrus.Fields{
+					"task": info.ID,
+					"took": time.Since(begin),
+				}).Info("Submit URL: ", url)
+		}
+	}(time.Now())
+	info, err = mw.Service.Submit(payload)
+	return
+}
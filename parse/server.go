@@ -35,7 +35,11 @@ func Start(cfg Config) *HTMLServer {
 	svc = LoggingMiddleware(logger)(svc)
 
 	endpoints := Endpoints{
-		ParseEndpoint: MakeParseEndpoint(svc),
+		ParseEndpoint:  MakeParseEndpoint(svc),
+		StreamEndpoint: MakeStreamEndpoint(svc),
+		SubmitEndpoint: MakeSubmitEndpoint(svc),
+		StatusEndpoint: MakeStatusEndpoint(svc),
+		ResultEndpoint: MakeResultEndpoint(svc),
 	}
 
 	r := NewHttpHandler(ctx, endpoints, logger)
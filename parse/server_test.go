@@ -1,11 +1,17 @@
 package parse
 
 import (
+	"context"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"strings"
 	"testing"
 
+	"github.com/slotix/dataflowkit/fetch"
+	"github.com/slotix/dataflowkit/scrape"
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -64,3 +70,61 @@ func TestHealthCheckHandler(t *testing.T) {
 	body, _ := ioutil.ReadAll(resp.Body)
 	assert.Equal(t, []byte(`{"alive": true}`), body)
 }
+
+func TestTaskHandlers(t *testing.T) {
+	viper.Set("STORAGE_TYPE", "Diskv")
+	svc := ParseService{}
+	endpoints := Endpoints{
+		StatusEndpoint: MakeStatusEndpoint(svc),
+		ResultEndpoint: MakeResultEndpoint(svc),
+	}
+	handler := NewHttpHandler(context.Background(), endpoints, logger)
+
+	req := httptest.NewRequest("GET", "/tasks/unknownTask", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	req = httptest.NewRequest("GET", "/tasks/unknownTask/result", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	//results of the task which is not finished yet are not available
+	baseDir := viper.GetString("DISKV_BASE_DIR")
+	defer viper.Set("DISKV_BASE_DIR", baseDir)
+	viper.Set("DISKV_BASE_DIR", "./tasks_test")
+	defer os.RemoveAll("./tasks_test")
+	task := scrape.NewTask(scrape.Payload{Name: "test", Request: fetch.Request{URL: "http://example.com"}})
+	assert.NoError(t, task.Queue())
+	req = httptest.NewRequest("GET", "/tasks/"+task.ID+"/result", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), "queued")
+
+	req = httptest.NewRequest("GET", "/tasks/"+task.ID, nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestStreamHandler(t *testing.T) {
+	svc := ParseService{}
+	endpoints := Endpoints{
+		StreamEndpoint: MakeStreamEndpoint(svc),
+	}
+	handler := NewHttpHandler(context.Background(), endpoints, logger)
+
+	req := httptest.NewRequest("POST", "/parse/stream", strings.NewReader("invalid payload"))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	//error is returned before any result is written
+	req = httptest.NewRequest("POST", "/parse/stream", strings.NewReader(`{"name":"test","request":{"url":"http://example.com"}}`))
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "error")
+}
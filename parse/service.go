@@ -1,29 +1,107 @@
 package parse
 
 import (
+	"context"
 	"io"
+	"os"
+	"sync"
 
+	"github.com/slotix/dataflowkit/errs"
 	"github.com/slotix/dataflowkit/scrape"
+	"github.com/spf13/viper"
 )
 
 // Service defines Parse service interface
 type Service interface {
-	Parse(scrape.Payload) (io.ReadCloser, error)
+	Parse(context.Context, scrape.Payload) (io.ReadCloser, error)
+	//ParseTo processes the payload and streams encoded results to the writer
+	ParseTo(context.Context, scrape.Payload, io.Writer) error
+	//Submit starts parsing of the payload in background and returns the state of the created task
+	Submit(scrape.Payload) (*scrape.TaskInfo, error)
+	//Status returns the state of the task with specified ID
+	Status(id string) (*scrape.TaskInfo, error)
+	//Result returns encoded results of the finished task
+	Result(id string) (io.ReadCloser, error)
 }
 
 // ParseService implements service with empty struct
 type ParseService struct {
 }
 
+// taskSlots limits the number of background tasks parsed at the same time. Other tasks stay queued until a slot is released.
+var (
+	taskSlots     chan struct{}
+	taskSlotsOnce sync.Once
+)
+
+func backgroundSlots() chan struct{} {
+	taskSlotsOnce.Do(func() {
+		n := viper.GetInt("MAX_TASKS")
+		if n < 1 {
+			n = 1
+		}
+		taskSlots = make(chan struct{}, n)
+	})
+	return taskSlots
+}
+
 // ServiceMiddleware defines a middleware for a Parse service
 type ServiceMiddleware func(Service) Service
 
 //Parse service processes fetched page following the rules from Payload.
-func (ps ParseService) Parse(p scrape.Payload) (io.ReadCloser, error) {
+//Parsing is stopped when ctx is cancelled. Partial results may be returned along with errs.Canceled error.
+func (ps ParseService) Parse(ctx context.Context, p scrape.Payload) (io.ReadCloser, error) {
+	task := scrape.NewTask(p)
+	return task.Parse(ctx)
+}
+
+//ParseTo service processes fetched page the same way as Parse does. Encoded results are written to w block by block instead of results file.
+func (ps ParseService) ParseTo(ctx context.Context, p scrape.Payload, w io.Writer) error {
+	task := scrape.NewTask(p)
+	return task.ParseTo(ctx, w)
+}
+
+// Submit validates the payload and parses it in background. Task ID is returned immediately.
+func (ps ParseService) Submit(p scrape.Payload) (*scrape.TaskInfo, error) {
 	task := scrape.NewTask(p)
-	r, err := task.Parse()
+	err := task.Validate()
 	if err != nil {
 		return nil, err
 	}
-	return r, nil
+	err = task.Queue()
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		slots := backgroundSlots()
+		slots <- struct{}{}
+		defer func() { <-slots }()
+		//background task is not bound to the submit request. It is limited by Payload.Timeout only.
+		if _, err := task.Parse(context.Background()); err != nil {
+			logger.Errorf("Task %s failed. %s", task.ID, err.Error())
+		}
+	}()
+	info := task.Info()
+	return &info, nil
+}
+
+// Status returns the state of the task with specified ID from storage.
+func (ps ParseService) Status(id string) (*scrape.TaskInfo, error) {
+	return scrape.LoadTaskInfo(id)
+}
+
+// Result opens results file of the finished task. errs.Conflict is returned if the task is not finished successfully.
+func (ps ParseService) Result(id string) (io.ReadCloser, error) {
+	info, err := scrape.LoadTaskInfo(id)
+	if err != nil {
+		return nil, err
+	}
+	if info.Status != scrape.TaskFinished {
+		return nil, &errs.Conflict{Err: "task " + id + " is " + info.Status}
+	}
+	f, err := os.Open(info.Result)
+	if err != nil {
+		return nil, &errs.NotFound{URL: info.Result}
+	}
+	return f, nil
 }
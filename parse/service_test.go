@@ -1,6 +1,7 @@
 package parse
 
 import (
+	"context"
 	"testing"
 
 	"github.com/slotix/dataflowkit/fetch"
@@ -74,11 +75,12 @@ func Test_service(t *testing.T) {
 	fetchServerCfg := fetch.Config{
 		Host: fetchServerAddr,
 	}
-	fetchServer := fetch.Start(fetchServerCfg)
+	fetchServer, err := fetch.Start(fetchServerCfg)
+	assert.NoError(t, err)
 	//Stop server
 	defer fetchServer.Stop()
 	svc := ParseService{}
-	result, err := svc.Parse(payloadBase)
+	result, err := svc.Parse(context.Background(), payloadBase)
 	assert.NoError(t, err)
 	assert.NotNil(t, result)
 
@@ -95,7 +97,7 @@ func Test_service(t *testing.T) {
 	if err != nil {
 		logger.Error(err)
 	}
-	result, err = svc1.Parse(payloadChrome)
+	result, err = svc1.Parse(context.Background(), payloadChrome)
 	assert.NoError(t, err)
 	assert.NotNil(t, result)
 
@@ -103,7 +105,7 @@ func Test_service(t *testing.T) {
 	invPayload := scrape.Payload{
 		Name: "invalid payload",
 	}
-	_, err = svc1.Parse(invPayload)
+	_, err = svc1.Parse(context.Background(), invPayload)
 	assert.Error(t, err)
 
 	//Invalid Payload - no fields
@@ -114,7 +116,7 @@ func Test_service(t *testing.T) {
 		},
 	}
 
-	_, err = svc.Parse(invPayload)
+	_, err = svc.Parse(context.Background(), invPayload)
 	assert.Error(t, err)
 
 }
@@ -2,9 +2,12 @@ package parse
 
 import (
 	"encoding/json"
+	"fmt"
 	"io"
 	"io/ioutil"
+	"mime"
 	"net/http"
+	"path/filepath"
 
 	"github.com/go-kit/kit/endpoint"
 	"github.com/sirupsen/logrus"
@@ -27,6 +30,12 @@ func DecodeParseRequest(ctx context.Context, r *http.Request) (interface{}, erro
 	return p, nil
 }
 
+//partialResults wraps results returned along with errs.Canceled error
+type partialResults struct {
+	io.Reader
+	err error
+}
+
 //EncodeParseResponse encodes response returned by Parser
 func EncodeParseResponse(_ context.Context, w http.ResponseWriter, response interface{}) error {
 	ctx := context.Background()
@@ -35,6 +44,10 @@ func EncodeParseResponse(_ context.Context, w http.ResponseWriter, response inte
 		encodeError(ctx, err, w)
 		return nil
 	}
+	if p, ok := response.(partialResults); ok {
+		//Results are incomplete. Warning header is used to report the reason.
+		w.Header().Set("Warning", fmt.Sprintf("199 - %q", p.err.Error()))
+	}
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	_, err = w.Write(data)
 
@@ -45,6 +58,114 @@ func EncodeParseResponse(_ context.Context, w http.ResponseWriter, response inte
 	return nil
 }
 
+//streamRequest holds payload along with the writer which receives encoded results
+type streamRequest struct {
+	payload scrape.Payload
+	w       io.Writer
+}
+
+//contentTypes maps output formats to response Content-Type
+var contentTypes = map[string]string{
+	"json":    "application/json; charset=utf-8",
+	"csv":     "text/csv; charset=utf-8",
+	"xml":     "application/xml; charset=utf-8",
+	"jsonl":   "application/x-ndjson",
+	"ndjson":  "application/x-ndjson",
+	"parquet": "application/octet-stream",
+	"avro":    "application/avro",
+}
+
+//streamWriter sends response headers on the first write and flushes every chunk to the client
+type streamWriter struct {
+	w       http.ResponseWriter
+	format  string
+	written bool
+}
+
+func (sw *streamWriter) Write(p []byte) (int, error) {
+	if !sw.written {
+		sw.written = true
+		if cType, ok := contentTypes[sw.format]; ok {
+			sw.w.Header().Set("Content-Type", cType)
+		}
+		sw.w.Header().Set("Access-Control-Allow-Origin", "*")
+	}
+	n, err := sw.w.Write(p)
+	if f, ok := sw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return n, err
+}
+
+//streamHandler decodes payload and streams results returned by StreamEndpoint to the client.
+//Errors are reported with status code unless some results have been sent already.
+func streamHandler(e endpoint.Endpoint) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		p, err := DecodeParseRequest(ctx, r)
+		if err != nil {
+			encodeError(ctx, err, w)
+			return
+		}
+		sw := &streamWriter{w: w, format: p.(scrape.Payload).Format}
+		_, err = e(ctx, streamRequest{payload: p.(scrape.Payload), w: sw})
+		if err == nil {
+			return
+		}
+		if !sw.written {
+			encodeError(ctx, err, w)
+			return
+		}
+		//status code has been sent already. Response is truncated.
+		logger.Error(err)
+	}
+}
+
+//decodeTaskRequest retrieves task ID from request URL
+func decodeTaskRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	id, ok := mux.Vars(r)["id"]
+	if !ok || id == "" {
+		return nil, &errs.Error{Err: "no task ID specified"}
+	}
+	return id, nil
+}
+
+//encodeSubmitResponse encodes state of just submitted task. 202 Accepted status is returned.
+func encodeSubmitResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusAccepted)
+	return json.NewEncoder(w).Encode(response)
+}
+
+//EncodeTaskResponse encodes task state returned by Status endpoint
+func EncodeTaskResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	return json.NewEncoder(w).Encode(response)
+}
+
+//EncodeResultResponse streams results file of the finished task
+func EncodeResultResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	r, ok := response.(io.ReadCloser)
+	if !ok {
+		encodeError(ctx, &errs.Error{Err: "invalid task result"}, w)
+		return nil
+	}
+	defer r.Close()
+	//results file name contains extension of the output format
+	if f, ok := r.(interface{ Name() string }); ok {
+		name := filepath.Base(f.Name())
+		if cType := mime.TypeByExtension(filepath.Ext(name)); cType != "" {
+			w.Header().Set("Content-Type", cType)
+		}
+		w.Header().Set("Content-Disposition", "attachment; filename="+name)
+	}
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	_, err := io.Copy(w, r)
+	return err
+}
+
 // encodeError encodes erroneous responses and writes http status header.
 func encodeError(_ context.Context, err error, w http.ResponseWriter) {
 	if err == nil {
@@ -67,7 +188,11 @@ func encodeError(_ context.Context, err error, w http.ResponseWriter) {
 	case *errs.NotFound:
 		//return 404 Status
 		httpStatus = http.StatusNotFound
-	case *errs.GatewayTimeout:
+	case *errs.Conflict:
+		//return 409 Status
+		httpStatus = http.StatusConflict
+	case *errs.GatewayTimeout,
+		*errs.Canceled:
 		//return 504 Status
 		httpStatus = http.StatusGatewayTimeout
 	}
@@ -81,20 +206,57 @@ func encodeError(_ context.Context, err error, w http.ResponseWriter) {
 
 // Endpoints wrapper
 type Endpoints struct {
-	ParseEndpoint endpoint.Endpoint
+	ParseEndpoint  endpoint.Endpoint
+	StreamEndpoint endpoint.Endpoint
+	SubmitEndpoint endpoint.Endpoint
+	StatusEndpoint endpoint.Endpoint
+	ResultEndpoint endpoint.Endpoint
 }
 
 // MakeParseEndpoint creates Parse Endpoint
 func MakeParseEndpoint(svc Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
-		v, err := svc.Parse(request.(scrape.Payload))
+		v, err := svc.Parse(ctx, request.(scrape.Payload))
 		if err != nil {
+			//scraping is stopped before all pages are processed but some results are available
+			if _, ok := err.(*errs.Canceled); ok && v != nil {
+				return partialResults{Reader: v, err: err}, nil
+			}
 			return nil, err
 		}
 		return v, nil
 	}
 }
 
+// MakeStreamEndpoint creates Endpoint which streams parsed results
+func MakeStreamEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(streamRequest)
+		return nil, svc.ParseTo(ctx, req.payload, req.w)
+	}
+}
+
+// MakeSubmitEndpoint creates Submit Endpoint
+func MakeSubmitEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		return svc.Submit(request.(scrape.Payload))
+	}
+}
+
+// MakeStatusEndpoint creates Task Status Endpoint
+func MakeStatusEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		return svc.Status(request.(string))
+	}
+}
+
+// MakeResultEndpoint creates Task Result Endpoint
+func MakeResultEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		return svc.Result(request.(string))
+	}
+}
+
 //HealthCheckHandler is used to check if Parse service is alive
 func HealthCheckHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
@@ -115,7 +277,26 @@ func NewHttpHandler(ctx context.Context, endpoint Endpoints, logger *logrus.Logg
 		EncodeParseResponse,
 		options...,
 	))
+	r.Methods("POST").Path("/parse/stream").Handler(streamHandler(endpoint.StreamEndpoint))
 
+	r.Methods("POST").Path("/tasks").Handler(httptransport.NewServer(
+		endpoint.SubmitEndpoint,
+		DecodeParseRequest,
+		encodeSubmitResponse,
+		options...,
+	))
+	r.Methods("GET").Path("/tasks/{id}").Handler(httptransport.NewServer(
+		endpoint.StatusEndpoint,
+		decodeTaskRequest,
+		EncodeTaskResponse,
+		options...,
+	))
+	r.Methods("GET").Path("/tasks/{id}/result").Handler(httptransport.NewServer(
+		endpoint.ResultEndpoint,
+		decodeTaskRequest,
+		EncodeResultResponse,
+		options...,
+	))
 	r.Methods("GET").Path("/ping").HandlerFunc(HealthCheckHandler)
 	return r
 }
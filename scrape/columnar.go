@@ -0,0 +1,446 @@
+package scrape
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/hamba/avro/ocf"
+	"github.com/slotix/dataflowkit/errs"
+	"github.com/slotix/dataflowkit/extract"
+	"github.com/slotix/dataflowkit/storage"
+	"github.com/spf13/viper"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+// Column types of columnar output formats
+const (
+	columnString = "string"
+	columnInt    = "int"
+	columnFloat  = "float"
+	columnTime   = "timestamp"
+	columnBool   = "bool"
+	columnRecord = "record"
+)
+
+// priceColumns describe records of Price extractor.
+var priceColumns = []column{
+	{name: "amount", field: "amount", typ: columnFloat},
+	{name: "currency", field: "currency", typ: columnString},
+}
+
+// column describes a single field of columnar output. It is derived from a scraper Part.
+type column struct {
+	//name is a key of the value in a parsed block
+	name string
+	//field is a name of the column in output schema. Avro and Parquet names may contain only letters, digits and underscores.
+	field string
+	typ   string
+	//repeated columns hold lists of values
+	repeated bool
+	//columns of nested records. Details are stored as repeated records.
+	columns []column
+}
+
+var invalidFieldChars = regexp.MustCompile("[^A-Za-z0-9_]")
+
+// fieldName converts part name to a valid Avro and Parquet column name.
+func fieldName(name string) string {
+	field := invalidFieldChars.ReplaceAllString(name, "_")
+	if field == "" || (field[0] >= '0' && field[0] <= '9') {
+		field = "_" + field
+	}
+	return field
+}
+
+// columns returns output schema of the scraper parts.
+// Text, attribute, regex and typed extractors may return more than one value for a block so they are mapped to repeated columns.
+// Text, attribute and regex columns are strings. Number, date and boolean columns are doubles, timestamps and booleans. Prices are records of amount and currency.
+// Count is mapped to integer column. Details, objects and lists are mapped to repeated records.
+func (s Scraper) columns() []column {
+	cols := []column{}
+	//columns of table mode are string columns named after column mappings
+	if s.Table != nil {
+		for _, part := range tableParts(s.Table.Extractor.(*extract.Table)) {
+			cols = append(cols, column{name: part.Name, field: fieldName(part.Name), typ: columnString})
+		}
+		return cols
+	}
+	for _, part := range s.Parts {
+		col := column{
+			name:  part.Name,
+			field: fieldName(part.Name),
+			typ:   columnString,
+		}
+		switch part.Extractor.(type) {
+		case extract.Count, *extract.Count:
+			col.typ = columnInt
+		case extract.Text, *extract.Text,
+			extract.Attr, *extract.Attr,
+			extract.Regex, *extract.Regex:
+			col.repeated = true
+		case extract.Number, *extract.Number:
+			col.typ = columnFloat
+			col.repeated = true
+		case extract.Date, *extract.Date:
+			col.typ = columnTime
+			col.repeated = true
+		case extract.Boolean, *extract.Boolean:
+			col.typ = columnBool
+			col.repeated = true
+		case extract.Price, *extract.Price:
+			col.typ = columnRecord
+			col.repeated = true
+			col.columns = priceColumns
+		}
+		//records of tables with mapped columns are repeated records as well. Records of other tables are stored as JSON strings.
+		if t, ok := part.Extractor.(*extract.Table); ok {
+			part.Parts = tableParts(t)
+			col.repeated = true
+		}
+		//objects of object and list parts are mapped to repeated records like details
+		if len(part.Parts) > 0 {
+			col.typ = columnRecord
+			col.repeated = true
+			col.columns = Scraper{Parts: part.Parts}.columns()
+		}
+		cols = append(cols, col)
+		if part.Details.Capture != nil {
+			cols = append(cols, column{
+				name:  part.Name + "_capture",
+				field: fieldName(part.Name + "_capture"),
+				typ:   columnString,
+			})
+		}
+		if len(part.Details.Parts) > 0 {
+			cols = append(cols, column{
+				name:     part.Name + "_details",
+				field:    fieldName(part.Name + "_details"),
+				typ:      columnRecord,
+				repeated: true,
+				columns:  part.Details.columns(),
+			})
+		}
+	}
+	return cols
+}
+
+// checkFields returns an error if names of different parts are converted to the same column name, f.e. "a-b" and "a b".
+func checkFields(cols []column) error {
+	names := make(map[string]string, len(cols))
+	for _, col := range cols {
+		if name, ok := names[col.field]; ok {
+			return fmt.Errorf("Field names %s and %s are both converted to column %s. Only letters, digits and underscores are kept in column names", name, col.name, col.field)
+		}
+		names[col.field] = col.name
+		if err := checkFields(col.columns); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// record converts block read from storage to the map with values of the types specified by columns.
+func record(block map[string]interface{}, cols []column) map[string]interface{} {
+	rec := make(map[string]interface{}, len(cols))
+	for _, col := range cols {
+		value := block[col.name]
+		switch {
+		case col.typ == columnRecord:
+			records := []map[string]interface{}{}
+			for _, v := range listValue(value) {
+				if details, ok := v.(map[string]interface{}); ok {
+					records = append(records, record(details, col.columns))
+				}
+			}
+			rec[col.field] = records
+		case col.repeated:
+			rec[col.field] = repeatedValue(value, col.typ)
+		case value == nil:
+			rec[col.field] = nil
+		case col.typ == columnInt:
+			//numbers are unmarshalled from intermediate storage as float64
+			if v, ok := value.(float64); ok {
+				rec[col.field] = int64(v)
+			} else {
+				rec[col.field] = nil
+			}
+		case col.typ == columnFloat:
+			if v, ok := value.(float64); ok {
+				rec[col.field] = v
+			} else {
+				rec[col.field] = nil
+			}
+		default:
+			rec[col.field] = formatValue(value)
+		}
+	}
+	return rec
+}
+
+// repeatedValue converts value to the list of values of the column type. Values of other types are skipped.
+// Dates are stored in intermediate storage as RFC3339 strings.
+func repeatedValue(value interface{}, typ string) interface{} {
+	switch typ {
+	case columnFloat:
+		values := []float64{}
+		for _, v := range listValue(value) {
+			if f, ok := v.(float64); ok {
+				values = append(values, f)
+			}
+		}
+		return values
+	case columnTime:
+		values := []time.Time{}
+		for _, v := range listValue(value) {
+			if s, ok := v.(string); ok {
+				if t, err := time.Parse(time.RFC3339, s); err == nil {
+					values = append(values, t)
+				}
+			}
+		}
+		return values
+	case columnBool:
+		values := []bool{}
+		for _, v := range listValue(value) {
+			if b, ok := v.(bool); ok {
+				values = append(values, b)
+			}
+		}
+		return values
+	}
+	values := []string{}
+	for _, v := range listValue(value) {
+		values = append(values, formatValue(v))
+	}
+	return values
+}
+
+// listValue returns value as a list. Single values are wrapped into a list of one element.
+func listValue(value interface{}) []interface{} {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		return v
+	case []map[string]interface{}:
+		list := make([]interface{}, len(v))
+		for i, m := range v {
+			list[i] = m
+		}
+		return list
+	default:
+		return []interface{}{v}
+	}
+}
+
+// readRecords reads all the blocks of the specified results from storage and passes them converted to records to write func.
+func readRecords(payloadMD5 string, keys *map[int][]int, cols []column, write func(map[string]interface{}) error) error {
+	storageType := viper.GetString("STORAGE_TYPE")
+	s := storage.NewStore(storageType)
+	defer s.Close()
+	reader := newStorageReader(&s, payloadMD5, keys)
+	for {
+		block, err := reader.Read()
+		if err != nil {
+			if err.Error() == errs.EOF {
+				break
+			} else if err.Error() == errs.NextPage {
+				//next page
+			} else {
+				logger.Error(err)
+				//we have to continue 'cause we still have other records
+				continue
+			}
+		}
+		err = write(record(block, cols))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ParquetEncoder transforms parsed data to Apache Parquet format.
+type ParquetEncoder struct {
+	columns []column
+}
+
+// parquetSchema returns parquet-go JSON schema of the columns.
+func parquetSchema(name string, repetition string, cols []column) map[string]interface{} {
+	fields := []interface{}{}
+	for _, col := range cols {
+		var field map[string]interface{}
+		switch col.typ {
+		case columnRecord:
+			field = map[string]interface{}{
+				"Tag":    fmt.Sprintf("name=%s, type=LIST, repetitiontype=REQUIRED", col.field),
+				"Fields": []interface{}{parquetSchema("element", "REQUIRED", col.columns)},
+			}
+		default:
+			field = map[string]interface{}{
+				"Tag": fmt.Sprintf("name=%s, %s, repetitiontype=OPTIONAL", col.field, parquetType(col.typ)),
+			}
+			if col.repeated {
+				field["Tag"] = fmt.Sprintf("name=%s, type=LIST, repetitiontype=REQUIRED", col.field)
+				field["Fields"] = []interface{}{map[string]interface{}{
+					"Tag": fmt.Sprintf("name=element, %s, repetitiontype=REQUIRED", parquetType(col.typ)),
+				}}
+			}
+		}
+		fields = append(fields, field)
+	}
+	return map[string]interface{}{
+		"Tag":    fmt.Sprintf("name=%s, repetitiontype=%s", name, repetition),
+		"Fields": fields,
+	}
+}
+
+// parquetType returns parquet-go tag of the scalar column type.
+func parquetType(typ string) string {
+	switch typ {
+	case columnInt:
+		return "type=INT64"
+	case columnFloat:
+		return "type=DOUBLE"
+	case columnTime:
+		return "type=TIMESTAMP_MILLIS"
+	case columnBool:
+		return "type=BOOLEAN"
+	}
+	return "type=UTF8"
+}
+
+// parquetRecord replaces timestamps of the record with milliseconds since epoch, as parquet-go JSON writer expects numbers for them.
+func parquetRecord(rec map[string]interface{}, cols []column) map[string]interface{} {
+	for _, col := range cols {
+		switch v := rec[col.field].(type) {
+		case []time.Time:
+			millis := make([]int64, len(v))
+			for i, t := range v {
+				millis[i] = t.UnixNano() / int64(time.Millisecond)
+			}
+			rec[col.field] = millis
+		case []map[string]interface{}:
+			for _, child := range v {
+				parquetRecord(child, col.columns)
+			}
+		}
+	}
+	return rec
+}
+
+func (e ParquetEncoder) encode(w *bufio.Writer, payloadMD5 string, keys *map[int][]int) error {
+	schema, err := json.Marshal(parquetSchema("parquet_go_root", "REQUIRED", e.columns))
+	if err != nil {
+		return err
+	}
+	pw, err := writer.NewJSONWriterFromWriter(string(schema), w, 1)
+	if err != nil {
+		return fmt.Errorf("Failed to create parquet writer. %s", err.Error())
+	}
+	err = readRecords(payloadMD5, keys, e.columns, func(rec map[string]interface{}) error {
+		j, err := json.Marshal(parquetRecord(rec, e.columns))
+		if err != nil {
+			return err
+		}
+		return pw.Write(string(j))
+	})
+	if err != nil {
+		return err
+	}
+	//footer is written on stop. Parquet file may not be consumed before it is complete.
+	if err = pw.WriteStop(); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// AvroEncoder transforms parsed data to Apache Avro object container file.
+type AvroEncoder struct {
+	//name is a name of top level record. Collection name is used.
+	name    string
+	columns []column
+}
+
+// avroSchema returns Avro record schema of the columns.
+// Scalar columns are nullable. Repeated columns are arrays which are empty if no value is parsed.
+func avroSchema(name string, cols []column) map[string]interface{} {
+	fields := []interface{}{}
+	for _, col := range cols {
+		var typ interface{}
+		switch col.typ {
+		case columnRecord:
+			typ = map[string]interface{}{
+				"type":  "array",
+				"items": avroSchema(name+"_"+col.field, col.columns),
+			}
+		default:
+			typ = []interface{}{"null", avroType(col.typ)}
+			if col.repeated {
+				typ = map[string]interface{}{
+					"type":  "array",
+					"items": avroType(col.typ),
+				}
+			}
+		}
+		field := map[string]interface{}{
+			"name": col.field,
+			"type": typ,
+		}
+		if col.repeated {
+			field["default"] = []interface{}{}
+		} else {
+			field["default"] = nil
+		}
+		fields = append(fields, field)
+	}
+	return map[string]interface{}{
+		"type":   "record",
+		"name":   name,
+		"fields": fields,
+	}
+}
+
+// avroType returns Avro schema of the scalar column type. Timestamps are stored as milliseconds since epoch.
+func avroType(typ string) interface{} {
+	switch typ {
+	case columnInt:
+		return "long"
+	case columnFloat:
+		return "double"
+	case columnTime:
+		return map[string]interface{}{"type": "long", "logicalType": "timestamp-millis"}
+	case columnBool:
+		return "boolean"
+	}
+	return "string"
+}
+
+func (e AvroEncoder) encode(w *bufio.Writer, payloadMD5 string, keys *map[int][]int) error {
+	name := e.name
+	if strings.TrimSpace(name) == "" {
+		name = "block"
+	}
+	schema, err := json.Marshal(avroSchema(fieldName(name), e.columns))
+	if err != nil {
+		return err
+	}
+	enc, err := ocf.NewEncoder(string(schema), w)
+	if err != nil {
+		return fmt.Errorf("Failed to create avro encoder. %s", err.Error())
+	}
+	err = readRecords(payloadMD5, keys, e.columns, func(rec map[string]interface{}) error {
+		return enc.Encode(rec)
+	})
+	if err != nil {
+		return err
+	}
+	if err = enc.Close(); err != nil {
+		return err
+	}
+	return w.Flush()
+}
@@ -0,0 +1,141 @@
+package scrape
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/hamba/avro/ocf"
+	"github.com/slotix/dataflowkit/extract"
+	"github.com/stretchr/testify/assert"
+	"github.com/xitongsys/parquet-go-source/buffer"
+	"github.com/xitongsys/parquet-go/reader"
+)
+
+var columnarScraper = Scraper{
+	Parts: []Part{
+		{Name: "Name_text", Extractor: &extract.Text{}},
+		{Name: "Phones_count", Extractor: &extract.Count{}},
+		{Name: "Rating_number", Extractor: &extract.Number{}},
+		{Name: "Born_date", Extractor: &extract.Date{}},
+		{Name: "Active_boolean", Extractor: &extract.Boolean{}},
+		{Name: "Salary_price", Extractor: &extract.Price{}},
+		{Name: "Link_href", Extractor: &extract.Attr{Attr: "href"},
+			Details: Scraper{
+				Parts: []Part{
+					{Name: "Description_outerHtml", Extractor: &extract.OuterHtml{}},
+				},
+			},
+		},
+	},
+}
+
+func writeColumnarBlocks(t *testing.T) {
+	writeBlocks(t, "columnarDetails", [][]map[string]interface{}{
+		{
+			{"Description_outerHtml": "<p>Alice</p>"},
+		},
+	})
+	writeBlocks(t, "columnar", [][]map[string]interface{}{
+		{
+			{"Name_text": "Alice", "Phones_count": 2, "Rating_number": 4.5, "Born_date": "1990-05-01T10:00:00Z", "Active_boolean": true,
+				"Salary_price": map[string]interface{}{"amount": 1299.5, "currency": "USD"}, "Link_href": "/alice", "Link_href_details": "columnarDetails"},
+			{"Name_text": []string{"Bob", "Robert"}, "Rating_number": []float64{3, 4}, "Active_boolean": []bool{false, true}},
+		},
+	})
+}
+
+func TestColumns(t *testing.T) {
+	cols := columnarScraper.columns()
+	assert.Equal(t, []column{
+		{name: "Name_text", field: "Name_text", typ: columnString, repeated: true},
+		{name: "Phones_count", field: "Phones_count", typ: columnInt},
+		{name: "Rating_number", field: "Rating_number", typ: columnFloat, repeated: true},
+		{name: "Born_date", field: "Born_date", typ: columnTime, repeated: true},
+		{name: "Active_boolean", field: "Active_boolean", typ: columnBool, repeated: true},
+		{name: "Salary_price", field: "Salary_price", typ: columnRecord, repeated: true, columns: priceColumns},
+		{name: "Link_href", field: "Link_href", typ: columnString, repeated: true},
+		{name: "Link_href_details", field: "Link_href_details", typ: columnRecord, repeated: true,
+			columns: []column{
+				{name: "Description_outerHtml", field: "Description_outerHtml", typ: columnString},
+			},
+		},
+	}, cols)
+	assert.Equal(t, "Price_1st_text", fieldName("Price 1st_text"))
+	assert.Equal(t, "_1_text", fieldName("1_text"))
+
+	//names converted to the same column are rejected
+	assert.NoError(t, checkFields(cols))
+	err := checkFields(Scraper{Parts: []Part{
+		{Name: "a-b", Extractor: &extract.Text{}},
+		{Name: "a b", Extractor: &extract.Text{}},
+	}}.columns())
+	assert.Error(t, err)
+	err = checkFields(Scraper{Parts: []Part{
+		{Name: "link", Extractor: &extract.Attr{Attr: "href"}, Details: Scraper{Parts: []Part{
+			{Name: "x.y", Extractor: &extract.Text{}},
+			{Name: "x_y", Extractor: &extract.Text{}},
+		}}},
+	}}.columns())
+	assert.Error(t, err)
+}
+
+func TestParquetEncoder(t *testing.T) {
+	os.RemoveAll("./diskv")
+	defer os.RemoveAll("./diskv")
+	writeColumnarBlocks(t)
+
+	var e encoder = ParquetEncoder{columns: columnarScraper.columns()}
+	buf := &bytes.Buffer{}
+	err := EncodeToWriter(&e, buf, "columnar")
+	assert.NoError(t, err)
+
+	pf, err := buffer.NewBufferFile(buf.Bytes())
+	assert.NoError(t, err)
+	pr, err := reader.NewParquetReader(pf, nil, 1)
+	assert.NoError(t, err)
+	defer pr.ReadStop()
+	assert.Equal(t, int64(2), pr.GetNumRows())
+	records, err := pr.ReadByNumber(2)
+	assert.NoError(t, err)
+	j, err := json.Marshal(records)
+	assert.NoError(t, err)
+	//parquet-go reader capitalizes names of fields starting with a lower case letter
+	assert.JSONEq(t, `[
+		{"Name_text":["Alice"],"Phones_count":2,"Rating_number":[4.5],"Born_date":[641556000000],"Active_boolean":[true],
+			"Salary_price":[{"Amount":1299.5,"Currency":"USD"}],"Link_href":["/alice"],"Link_href_details":[{"Description_outerHtml":"<p>Alice</p>"}]},
+		{"Name_text":["Bob","Robert"],"Phones_count":null,"Rating_number":[3,4],"Born_date":[],"Active_boolean":[false,true],
+			"Salary_price":[],"Link_href":[],"Link_href_details":[]}
+	]`, string(j))
+}
+
+func TestAvroEncoder(t *testing.T) {
+	os.RemoveAll("./diskv")
+	defer os.RemoveAll("./diskv")
+	writeColumnarBlocks(t)
+
+	var e encoder = AvroEncoder{name: "test collection", columns: columnarScraper.columns()}
+	buf := &bytes.Buffer{}
+	err := EncodeToWriter(&e, buf, "columnar")
+	assert.NoError(t, err)
+
+	dec, err := ocf.NewDecoder(buf)
+	assert.NoError(t, err)
+	records := []map[string]interface{}{}
+	for dec.HasNext() {
+		rec := map[string]interface{}{}
+		assert.NoError(t, dec.Decode(&rec))
+		records = append(records, rec)
+	}
+	assert.NoError(t, dec.Error())
+	j, err := json.Marshal(records)
+	assert.NoError(t, err)
+	//nested union values are decoded along with their types
+	assert.JSONEq(t, `[
+		{"Name_text":["Alice"],"Phones_count":2,"Rating_number":[4.5],"Born_date":["1990-05-01T10:00:00Z"],"Active_boolean":[true],
+			"Salary_price":[{"amount":{"double":1299.5},"currency":{"string":"USD"}}],"Link_href":["/alice"],"Link_href_details":[{"Description_outerHtml":{"string":"<p>Alice</p>"}}]},
+		{"Name_text":["Bob","Robert"],"Phones_count":null,"Rating_number":[3,4],"Born_date":[],"Active_boolean":[false,true],
+			"Salary_price":[],"Link_href":[],"Link_href_details":[]}
+	]`, string(j))
+}
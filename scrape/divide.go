@@ -10,6 +10,8 @@ import (
 
 	"github.com/PuerkitoBio/goquery"
 	"github.com/slotix/dataflowkit/errs"
+	"github.com/slotix/dataflowkit/xpath"
+	"golang.org/x/net/html"
 )
 
 type dummyPaginator struct {
@@ -41,37 +43,65 @@ func attrOrDataValue(s *goquery.Selection) (value string) {
 }
 
 // DividePageByIntersection returns DividePageFunc function
-// which determines common ancestor of specified selectors.
-func DividePageByIntersection(selectors []string) DividePageFunc {
-	ret := func(doc *goquery.Selection) []*goquery.Selection {
+// which determines common ancestor of specified selectors. CSS path of the ancestor is returned along with the blocks.
+// isHTML is false for XML documents, whose names are matched by XPath case-sensitively.
+func DividePageByIntersection(selectors []string, isHTML bool) DividePageFunc {
+	ret := func(doc *goquery.Selection) ([]*goquery.Selection, string) {
 		sels := []*goquery.Selection{}
-		sel, err := getCommonAncestor(doc, selectors)
+		path, err := commonAncestorPath(doc, selectors, isHTML)
 		if err != nil {
 			// no common ancestor returned
-			return nil
+			return nil, ""
 		}
 
-		sel.Each(func(i int, s *goquery.Selection) {
+		xpath.Find(doc, path, isHTML).Each(func(i int, s *goquery.Selection) {
 			sels = append(sels, s)
 		})
 
-		return sels
+		return sels, path
 	}
 	return ret
 }
 
-func getCommonAncestor(doc *goquery.Selection, selectors []string) (*goquery.Selection, error) {
-	selectorAncestor := doc.Find(selectors[0]).First().Parent()
+// DividePageBySelector returns DividePageFunc function
+// which makes a block of every element matching CSS selector or XPath expression.
+func DividePageBySelector(selector string, isHTML bool) DividePageFunc {
+	return func(doc *goquery.Selection) ([]*goquery.Selection, string) {
+		sels := []*goquery.Selection{}
+		xpath.Find(doc, selector, isHTML).Each(func(i int, s *goquery.Selection) {
+			sels = append(sels, s)
+		})
+		return sels, selector
+	}
+}
+
+//isRootElement checks if selection is the root element of the document. It is html for HTML pages and may be any element for XML ones.
+func isRootElement(sel *goquery.Selection) bool {
+	return sel.Length() > 0 && sel.Nodes[0].Parent != nil && sel.Nodes[0].Parent.Type == html.DocumentNode
+}
+
+// xmlPath returns absolute XPath of the element made of names of its ancestors. CSS type selectors are lowercased, so they don't match XML names in upper case.
+func xmlPath(sel *goquery.Selection) string {
+	path := ""
+	for n := sel.Get(0); n != nil && n.Type == html.ElementNode; n = n.Parent {
+		path = "/" + n.Data + path
+	}
+	return xpath.Prefix + path
+}
+
+// commonAncestorPath returns CSS path of the closest common ancestor of the first elements matching selectors. XPath is returned for XML documents.
+func commonAncestorPath(doc *goquery.Selection, selectors []string, isHTML bool) (string, error) {
+	selectorAncestor := xpath.Find(doc, selectors[0], isHTML).First().Parent()
 	if len(selectors) > 1 {
 		bFound := false
 		selectorsSlice := selectors[1:]
 		for !bFound {
 			for _, f := range selectorsSlice {
-				sel := doc.Find(f).First()
+				sel := xpath.Find(doc, f, isHTML).First()
 				sel = sel.ParentsUntilSelection(selectorAncestor).Last()
-				//check last node.. if it equal html its mean that first selector's parent
+				//check last node.. if it is the root element (html) its mean that first selector's parent
 				//not found
-				if goquery.NodeName(sel) == "html" {
+				if isRootElement(sel) {
 					selectorAncestor = doc.FindSelection(selectorAncestor.Parent().First())
 					bFound = false
 					break
@@ -81,7 +111,10 @@ func getCommonAncestor(doc *goquery.Selection, selectors []string) (*goquery.Sel
 		}
 	}
 	if selectorAncestor.Length() == 0 {
-		return nil, &errs.BadPayload{errs.ErrNoCommonAncestor}
+		return "", &errs.BadPayload{errs.ErrNoCommonAncestor}
+	}
+	if !isHTML {
+		return xmlPath(selectorAncestor), nil
 	}
 	fullPath := goquery.NodeName(selectorAncestor)
 	parents := selectorAncestor.ParentsUntilSelection(doc.Find("body"))
@@ -90,6 +123,5 @@ func getCommonAncestor(doc *goquery.Selection, selectors []string) (*goquery.Sel
 		selector := attrOrDataValue(s)
 		fullPath = selector + " > " + fullPath
 	})
-	items := doc.Find(fullPath)
-	return items, nil
+	return fullPath, nil
 }
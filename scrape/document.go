@@ -0,0 +1,115 @@
+package scrape
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/slotix/dataflowkit/errs"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+)
+
+// Document types of scraped pages.
+const (
+	//HTMLDocument pages are parsed by goquery. Field selectors are CSS selectors. It is used by default.
+	HTMLDocument = "html"
+	//JSONDocument pages are decoded as JSON. Field selectors are paths like "$.products[*].name" or "products.#.name".
+	JSONDocument = "json"
+	//XMLDocument pages are parsed as XML. Field selectors are CSS selectors or XPath expressions matching local names of elements. Names are case-sensitive.
+	XMLDocument = "xml"
+)
+
+// documentType returns document type of the payload. HTML is used by default.
+func (p Payload) documentType() (string, error) {
+	switch t := strings.ToLower(p.DocumentType); t {
+	case "":
+		return HTMLDocument, nil
+	case HTMLDocument, JSONDocument, XMLDocument:
+		return t, nil
+	}
+	return "", &errs.BadPayload{ParserError: "Unknown document type " + p.DocumentType}
+}
+
+// isHTML checks if pages of the scraper are parsed as HTML. Names of elements and attributes of XML documents are case-sensitive.
+func (s Scraper) isHTML() bool {
+	return s.DocumentType != XMLDocument
+}
+
+// document is a page parsed according to document type of the scraper. JSON documents are kept as values decoded by encoding/json.
+type document struct {
+	sel    *goquery.Selection
+	value  interface{}
+	isJSON bool
+}
+
+// parseDocument parses fetched page.
+func (s Scraper) parseDocument(r io.Reader) (*document, error) {
+	switch s.DocumentType {
+	case JSONDocument:
+		var v interface{}
+		if err := json.NewDecoder(r).Decode(&v); err != nil {
+			return nil, fmt.Errorf("Failed to parse JSON document. %s", err.Error())
+		}
+		return &document{value: v, isJSON: true}, nil
+	case XMLDocument:
+		root, err := parseXML(r)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse XML document. %s", err.Error())
+		}
+		return &document{sel: goquery.NewDocumentFromNode(root).Selection}, nil
+	}
+	doc, err := goquery.NewDocumentFromReader(r)
+	if err != nil {
+		return nil, err
+	}
+	return &document{sel: doc.Selection}, nil
+}
+
+// nextPage returns URL of the next page. Next page of JSON document is found by paginator selector path.
+func (s Scraper) nextPage(uri string, page *document, p *paginator) (string, error) {
+	if page.isJSON {
+		return jsonNextPage(uri, page.value, p.Selector)
+	}
+	return s.Paginator.NextPage(uri, page.sel)
+}
+
+// parseXML builds a node tree of XML document, so it may be queried with CSS selectors and XPath expressions like HTML.
+// Names of elements and attributes are local names in their original case, as XML is case-sensitive. Comments and processing instructions are dropped.
+func parseXML(r io.Reader) (*html.Node, error) {
+	root := &html.Node{Type: html.DocumentNode}
+	parent := root
+	d := xml.NewDecoder(r)
+	d.CharsetReader = charset.NewReaderLabel
+	for {
+		t, err := d.Token()
+		if err == io.EOF {
+			return root, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := t.(type) {
+		case xml.StartElement:
+			n := &html.Node{
+				Type: html.ElementNode,
+				Data: t.Name.Local,
+			}
+			for _, a := range t.Attr {
+				n.Attr = append(n.Attr, html.Attribute{Key: a.Name.Local, Val: a.Value})
+			}
+			parent.AppendChild(n)
+			parent = n
+		case xml.EndElement:
+			parent = parent.Parent
+		case xml.CharData:
+			//whitespace between the prolog and the root element is skipped
+			if parent != root {
+				parent.AppendChild(&html.Node{Type: html.TextNode, Data: string(t)})
+			}
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package scrape
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/slotix/dataflowkit/xpath"
+	"github.com/stretchr/testify/assert"
+)
+
+const feedXML = `<?xml version="1.0" encoding="ISO-8859-1"?>
+<rss version="2.0" xmlns:dc="http://purl.org/dc/elements/1.1/">
+	<channel>
+		<title>News</title>
+		<Item><title>First</title><dc:creator>Ann</dc:creator><enclosure URL="/first.mp3"/></Item>
+		<Item><title><![CDATA[Second & last]]></title><dc:creator>Bob</dc:creator><enclosure URL="/second.mp3"/></Item>
+	</channel>
+</rss>`
+
+func TestParseXML(t *testing.T) {
+	s := Scraper{DocumentType: XMLDocument}
+	page, err := s.parseDocument(strings.NewReader(feedXML))
+	assert.NoError(t, err)
+	assert.False(t, page.isJSON)
+	assert.Equal(t, "News", page.sel.Find("channel > title").Text())
+	assert.Equal(t, "/first.mp3", page.sel.Find("enclosure").AttrOr("URL", ""))
+	//names are case-sensitive. CSS type selectors are lowercased, so XPath selects elements with upper case names.
+	assert.Equal(t, 0, page.sel.Find("item").Length())
+	assert.Equal(t, "Second & last", xpath.Find(page.sel, "xpath://Item[2]/title", false).Text())
+	assert.Equal(t, 0, xpath.Find(page.sel, "xpath://item", false).Length())
+	assert.Equal(t, "/second.mp3", xpath.Find(page.sel, "xpath://Item[2]/enclosure/@URL", false).Text())
+
+	blocks, path := DividePageByIntersection([]string{"xpath://Item/title", "creator"}, false)(page.sel)
+	assert.Equal(t, "xpath:/rss/channel/Item", path)
+	assert.Len(t, blocks, 2)
+	assert.Equal(t, "Bob", blocks[1].Find("creator").Text())
+
+	_, err = s.parseDocument(strings.NewReader("<rss><channel></rss>"))
+	assert.Error(t, err)
+}
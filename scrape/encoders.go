@@ -7,23 +7,24 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
-	"time"
 
 	"github.com/slotix/dataflowkit/errs"
 	"github.com/slotix/dataflowkit/storage"
 	"github.com/spf13/viper"
 )
 
-// EncodeToFile save parsed data to specified file.
-func EncodeToFile(e *encoder, ext string, payloadMD5 string, blockMap ...*map[int][]int) ([]byte, error) {
+// EncodeToFile saves parsed data to the file in RESULTS_DIR. Results are read from storage by uid which is the name of the file as well.
+// uid is the ID of the task, so results of tasks with the same payload are not mixed.
+func EncodeToFile(e *encoder, ext string, uid string, blockMap ...*map[int][]int) ([]byte, error) {
 	path := viper.GetString("RESULTS_DIR")
 	if _, err := os.Stat(path); os.IsNotExist(err) {
 		os.Mkdir(path, 0700)
 	}
-	sFileName := viper.GetString("RESULTS_DIR") + "/" + payloadMD5 + "_" + time.Now().Format("2006-01-02_15:04") + "." + ext
-	fo, err := os.OpenFile(sFileName, os.O_CREATE|os.O_WRONLY, 0660)
+	sFileName := viper.GetString("RESULTS_DIR") + "/" + uid + "." + ext
+	fo, err := os.OpenFile(sFileName, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0660)
 	if err != nil {
 		return nil, err
 	}
@@ -33,13 +34,20 @@ func EncodeToFile(e *encoder, ext string, payloadMD5 string, blockMap ...*map[in
 			panic(err)
 		}
 	}()
+	err = EncodeToWriter(e, fo, uid, blockMap...)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(sFileName), nil
+}
+
+// EncodeToWriter writes parsed data to w. Encoded data is flushed to w block by block so it can be consumed before all the results are read from storage.
+func EncodeToWriter(e *encoder, w io.Writer, payloadMD5 string, blockMap ...*map[int][]int) error {
 	var keys *map[int][]int
 	if len(blockMap) > 0 {
 		keys = blockMap[0]
 	}
-	w := bufio.NewWriter(fo)
-	(*e).encode(w, payloadMD5, keys)
-	return []byte(sFileName), nil
+	return (*e).encode(bufio.NewWriter(w), payloadMD5, keys)
 }
 
 // func EncodeToByteArray(e *encoder, payloadMD5 string, blockMap ...*map[int][]int) ([]byte, error) {
@@ -58,10 +66,23 @@ type encoder interface {
 	encode(w *bufio.Writer, payloadMD5 string, keys *map[int][]int) error
 }
 
+// Sink receives parsed blocks one by one as soon as they are written to intermediate storage.
+// Unlike encoder it does not wait for the whole task to be finished.
+type Sink interface {
+	// Open prepares sink for writing blocks parsed by the scraper.
+	Open(s Scraper) error
+	// Write stores a single block. Details of the block are passed nested.
+	Write(id string, block map[string]interface{}) error
+	// Close flushes and releases sink resources.
+	Close() error
+}
+
 // CSVEncoder transforms parsed data to CSV format.
 type CSVEncoder struct {
 	partNames []string
-	comma     string
+	//nested are object, list and table parts by name. Their objects are flattened to indexed columns.
+	nested map[string]Part
+	comma  string
 }
 
 // JSONEncoder transforms parsed data to JSON format.
@@ -73,6 +94,12 @@ type JSONEncoder struct {
 type XMLEncoder struct {
 }
 
+// JSONLEncoder transforms parsed data to JSON Lines (NDJSON) format. Each block is written as a separate JSON object on its own line.
+type JSONLEncoder struct {
+	//index adds _page and _block indexes of a block to each record
+	index bool
+}
+
 func (e JSONEncoder) encode(w *bufio.Writer, payloadMD5 string, keys *map[int][]int) error {
 	storageType := viper.GetString("STORAGE_TYPE")
 	s := storage.NewStore(storageType)
@@ -111,6 +138,11 @@ func (e JSONEncoder) encode(w *bufio.Writer, payloadMD5 string, keys *map[int][]
 			writeComma = !writeComma
 		}
 		w.Write(blockJSON)
+		err = w.Flush()
+		if err != nil {
+			s.Close()
+			return err
+		}
 	}
 	// if e.paginateResults {
 	// 	w.WriteString("]")
@@ -119,6 +151,42 @@ func (e JSONEncoder) encode(w *bufio.Writer, payloadMD5 string, keys *map[int][]
 	return w.Flush()
 }
 
+func (e JSONLEncoder) encode(w *bufio.Writer, payloadMD5 string, keys *map[int][]int) error {
+	storageType := viper.GetString("STORAGE_TYPE")
+	s := storage.NewStore(storageType)
+	defer s.Close()
+	reader := newStorageReader(&s, payloadMD5, keys)
+	for {
+		block, err := reader.Read()
+		if err != nil {
+			if err.Error() == errs.EOF {
+				break
+			} else if err.Error() == errs.NextPage {
+				//next page
+			} else {
+				logger.Error(err)
+				//we have to continue 'cause we still have other records
+				continue
+			}
+		}
+		if e.index {
+			block["_page"], block["_block"] = reader.position()
+		}
+		blockJSON, err := json.Marshal(block)
+		if err != nil {
+			logger.Error(err)
+			continue
+		}
+		w.Write(blockJSON)
+		w.WriteString("\n")
+		err = w.Flush()
+		if err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
+
 type storageResultReader struct {
 	storage    *storage.Store
 	payloadMD5 string
@@ -163,6 +231,8 @@ func (r *storageResultReader) init() {
 	for k := range r.payloadMap {
 		r.keys = append(r.keys, k)
 	}
+	//pages are read in order
+	sort.Ints(r.keys)
 }
 
 // func (r *storageResultReader) initManualKeys(blocks []int) {
@@ -173,6 +243,10 @@ func (r *storageResultReader) Read() (map[string]interface{}, error) {
 	//blockMap := make(map[string]interface{})
 	var err error
 	var nextPage bool
+	//no blocks are stored. E.g. details page is failed to fetch.
+	if len(r.keys) == 0 {
+		return nil, &errs.ErrStorageResult{Err: errs.EOF}
+	}
 	if r.block >= len(r.payloadMap[r.keys[r.page]]) {
 		if r.page+1 < len(r.keys) {
 			//achieve next page
@@ -190,10 +264,20 @@ func (r *storageResultReader) Read() (map[string]interface{}, error) {
 		r.block++
 		return nil, err
 	}
+	readDetails(r.storage, blockMap)
+	r.block++
+	if nextPage {
+		err = &errs.ErrStorageResult{Err: errs.NextPage}
+	}
+	return blockMap, err
+}
+
+// readDetails replaces keys of details stored in the block with details blocks read from storage.
+func readDetails(store *storage.Store, blockMap map[string]interface{}) {
 	for field, value := range blockMap {
 		if strings.Contains(field, "details") {
 			details := []map[string]interface{}{}
-			detailsReader := newStorageReader(r.storage, value.(string), nil)
+			detailsReader := newStorageReader(store, value.(string), nil)
 			for {
 				detailsBlock, detailsErr := detailsReader.Read()
 				if detailsErr != nil {
@@ -225,15 +309,17 @@ func (r *storageResultReader) Read() (map[string]interface{}, error) {
 			}
 		}
 	}
-	r.block++
-	if nextPage {
-		err = &errs.ErrStorageResult{Err: errs.NextPage}
-	}
-	return blockMap, err
+}
+
+// position returns page and block indexes of the last block returned by Read.
+func (r *storageResultReader) position() (int, int) {
+	page := r.keys[r.page]
+	return page, r.payloadMap[page][r.block-1]
 }
 
 func (r *storageResultReader) getValue() (map[string]interface{}, error) {
-	key := fmt.Sprintf("%s-%d-%d", r.payloadMD5, r.page, r.payloadMap[r.page][r.block])
+	page := r.keys[r.page]
+	key := fmt.Sprintf("%s-%d-%d", r.payloadMD5, page, r.payloadMap[page][r.block])
 	blockJSON, err := (*r.storage).Read(storage.Record{
 		Type: storage.INTERMEDIATE,
 		Key:  key,
@@ -254,9 +340,10 @@ func (e CSVEncoder) encode(w *bufio.Writer, payloadMD5 string, keys *map[int][]i
 	storageType := viper.GetString("STORAGE_TYPE")
 	s := storage.NewStore(storageType)
 
+	header := e.header(e.scan(&s, payloadMD5, keys))
 	//write csv headers
 	sString := ""
-	for _, headerName := range e.partNames {
+	for _, headerName := range header {
 		sString += fmt.Sprintf("%s,", headerName)
 	}
 	sString = strings.TrimSuffix(sString, ",") + "\n"
@@ -285,7 +372,8 @@ func (e CSVEncoder) encode(w *bufio.Writer, payloadMD5 string, keys *map[int][]i
 			}
 		}
 		sString = ""
-		for _, fieldName := range e.partNames {
+		block = e.flatten(block)
+		for _, fieldName := range header {
 			sString += e.formatFieldValue(&block, fieldName)
 		}
 		sString = strings.TrimSuffix(sString, ",") + "\n"
@@ -302,6 +390,116 @@ func (e CSVEncoder) encode(w *bufio.Writer, payloadMD5 string, keys *map[int][]i
 	return w.Flush()
 }
 
+// csvLayout describes columns which depend on the results.
+type csvLayout struct {
+	//counts are maximum numbers of objects of list parts
+	counts map[string]int
+	//keys are sorted keys of objects of nested parts without known sub-parts. Keys of blocks are stored under empty name.
+	keys map[string][]string
+}
+
+// scan reads results in advance as CSV header depends on the number of objects of list parts and on keys of tables without column mappings.
+func (e CSVEncoder) scan(s *storage.Store, payloadMD5 string, keys *map[int][]int) csvLayout {
+	layout := csvLayout{counts: map[string]int{}, keys: map[string][]string{}}
+	if len(e.nested) == 0 && len(e.partNames) > 0 {
+		return layout
+	}
+	found := map[string]map[string]bool{}
+	add := func(name string, object map[string]interface{}) {
+		if found[name] == nil {
+			found[name] = map[string]bool{}
+		}
+		for k := range object {
+			found[name][k] = true
+		}
+	}
+	reader := newStorageReader(s, payloadMD5, keys)
+	for {
+		block, err := reader.Read()
+		if err != nil && err.Error() == errs.EOF {
+			break
+		}
+		if len(e.partNames) == 0 {
+			add("", block)
+		}
+		for name, part := range e.nested {
+			items := listValue(block[name])
+			if part.List && len(items) > layout.counts[name] {
+				layout.counts[name] = len(items)
+			}
+			if len(part.Parts) == 0 {
+				for _, item := range items {
+					if object, ok := item.(map[string]interface{}); ok {
+						add(name, object)
+					}
+				}
+			}
+		}
+	}
+	for name, keys := range found {
+		for k := range keys {
+			layout.keys[name] = append(layout.keys[name], k)
+		}
+		sort.Strings(layout.keys[name])
+	}
+	return layout
+}
+
+// header returns CSV column names. Columns of object parts are named <part>_<sub-part>. Columns of list parts are named <part>_<index>_<sub-part>.
+func (e CSVEncoder) header(layout csvLayout) []string {
+	names := e.partNames
+	if len(names) == 0 {
+		names = layout.keys[""]
+	}
+	header := []string{}
+	for _, name := range names {
+		part, ok := e.nested[name]
+		if !ok {
+			header = append(header, name)
+			continue
+		}
+		subNames := layout.keys[name]
+		if len(part.Parts) > 0 {
+			subNames = []string{}
+			for _, sub := range part.Parts {
+				subNames = append(subNames, sub.Name)
+			}
+		}
+		if !part.List {
+			for _, sub := range subNames {
+				header = append(header, name+"_"+sub)
+			}
+			continue
+		}
+		for i := 0; i < layout.counts[name]; i++ {
+			for _, sub := range subNames {
+				header = append(header, fmt.Sprintf("%s_%d_%s", name, i, sub))
+			}
+		}
+	}
+	return header
+}
+
+// flatten adds values of objects of nested parts to the block under the names of their CSV columns.
+func (e CSVEncoder) flatten(block map[string]interface{}) map[string]interface{} {
+	for name, part := range e.nested {
+		for i, item := range listValue(block[name]) {
+			object, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			prefix := name + "_"
+			if part.List {
+				prefix = fmt.Sprintf("%s_%d_", name, i)
+			}
+			for k, v := range object {
+				block[prefix+k] = v
+			}
+		}
+	}
+	return block
+}
+
 func (e CSVEncoder) formatFieldValue(block *map[string]interface{}, fieldName string) string {
 	formatedString := ""
 	switch v := (*block)[fieldName].(type) {
@@ -319,14 +517,40 @@ func (e CSVEncoder) formatFieldValue(block *map[string]interface{}, fieldName st
 		formatedString = strconv.FormatFloat(v, 'f', -1, 64)
 	case nil:
 		formatedString = ""
+	default:
+		formatedString = formatValue(v)
+	}
+	return fmt.Sprintf("%s,", formatedString)
+}
+
+// formatValue returns text representation of decoded JSON value. Prices are formatted as amount followed by currency code. Items of lists are separated by semicolon.
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
 	case []interface{}:
 		values := make([]string, len(v))
-		for i, value := range v {
-			values[i] = fmt.Sprint(value)
+		for i, item := range v {
+			values[i] = formatValue(item)
 		}
-		formatedString = strings.Join(values, ";")
+		return strings.Join(values, ";")
+	case map[string]interface{}:
+		if amount, ok := v["amount"].(float64); ok {
+			return strings.TrimSpace(formatValue(amount) + " " + formatValue(v["currency"]))
+		}
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(data)
 	}
-	return fmt.Sprintf("%s,", formatedString)
+	return fmt.Sprint(value)
 }
 
 func (e XMLEncoder) encode(w *bufio.Writer, payloadMD5 string, keys *map[int][]int) error {
@@ -378,19 +602,17 @@ func (e XMLEncoder) writeXML(w io.Writer, block *map[string]interface{}) {
 			nodeName := fmt.Sprintf("<%s>", field)
 			w.Write([]byte(nodeName))
 			// have to escape predefined entities to obtain valid xml
-			v, ok := value.(string)
-			if ok {
+			switch v := value.(type) {
+			case string:
 				xml.Escape(w, []byte(v))
-			} else {
-				for i, val := range value.([]interface{}) {
-					v, ok = val.(string)
-					if ok {
-						xml.Escape(w, []byte(v))
-						if i < len(value.([]interface{}))-1 {
-							w.Write([]byte(";"))
-						}
-					}
-				}
+			case map[string]interface{}:
+				//prices and objects are written as nested elements
+				e.writeXML(w, &v)
+			case []interface{}:
+				e.writeXMLList(w, v)
+			default:
+				//numbers, booleans, timestamps and lists
+				xml.Escape(w, []byte(formatValue(v)))
 			}
 			nodeName = fmt.Sprintf("</%s>", field)
 			w.Write([]byte(nodeName))
@@ -398,6 +620,22 @@ func (e XMLEncoder) writeXML(w io.Writer, block *map[string]interface{}) {
 	}
 }
 
+// writeXMLList writes lists of objects as <item> elements. Other lists are written as text separated by semicolon.
+func (e XMLEncoder) writeXMLList(w io.Writer, list []interface{}) {
+	for _, item := range list {
+		if _, ok := item.(map[string]interface{}); !ok {
+			xml.Escape(w, []byte(formatValue(list)))
+			return
+		}
+	}
+	for _, item := range list {
+		object := item.(map[string]interface{})
+		w.Write([]byte("<item>"))
+		e.writeXML(w, &object)
+		w.Write([]byte("</item>"))
+	}
+}
+
 func intArrayToString(a []int, delim string) string {
 	return strings.Trim(strings.Replace(fmt.Sprint(a), " ", delim, -1), "[]")
 	//return strings.Trim(strings.Join(strings.Split(fmt.Sprint(a), " "), delim), "[]")
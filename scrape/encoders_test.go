@@ -0,0 +1,192 @@
+package scrape
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/slotix/dataflowkit/storage"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+//writeBlocks stores blocks to intermediate storage the same way as Task.saveToStorage does.
+func writeBlocks(t *testing.T, uid string, pages [][]map[string]interface{}) {
+	s := storage.NewStore(viper.GetString("STORAGE_TYPE"))
+	defer s.Close()
+	keys := make(map[int][]int)
+	for p, blocks := range pages {
+		for b, block := range blocks {
+			j, err := json.Marshal(block)
+			assert.NoError(t, err)
+			err = s.Write(storage.Record{
+				Type:  storage.INTERMEDIATE,
+				Key:   fmt.Sprintf("%s-%d-%d", uid, p, b),
+				Value: j,
+			})
+			assert.NoError(t, err)
+			keys[p] = append(keys[p], b)
+		}
+	}
+	j, err := json.Marshal(keys)
+	assert.NoError(t, err)
+	err = s.Write(storage.Record{
+		Type:  storage.INTERMEDIATE,
+		Key:   uid,
+		Value: j,
+	})
+	assert.NoError(t, err)
+}
+
+var testBlocks = [][]map[string]interface{}{
+	{
+		{"Name_text": "Alice", "Phones_text": []string{"1", "2"}},
+		{"Name_text": "Bob", "Phones_text": "3"},
+	},
+	{
+		{"Name_text": "Carol"},
+	},
+}
+
+func TestEncodeToWriter(t *testing.T) {
+	os.RemoveAll("./diskv")
+	writeBlocks(t, "encodeToWriter", testBlocks)
+
+	var e encoder = JSONEncoder{}
+	buf := &bytes.Buffer{}
+	err := EncodeToWriter(&e, buf, "encodeToWriter")
+	assert.NoError(t, err)
+	assert.Equal(t, `[{"Name_text":"Alice","Phones_text":["1","2"]},{"Name_text":"Bob","Phones_text":"3"},{"Name_text":"Carol"}]`, buf.String())
+
+	e = CSVEncoder{comma: ",", partNames: []string{"Name_text", "Phones_text"}}
+	buf.Reset()
+	err = EncodeToWriter(&e, buf, "encodeToWriter")
+	assert.NoError(t, err)
+	assert.Equal(t, "Name_text,Phones_text\nAlice,1;2\nBob,3\nCarol,\n", buf.String())
+	os.RemoveAll("./diskv")
+}
+
+func TestEncodeToFile(t *testing.T) {
+	os.RemoveAll("./diskv")
+	viper.Set("RESULTS_DIR", "results")
+	writeBlocks(t, "encodeToFile", testBlocks)
+	writeBlocks(t, "encodeToFileShort", testBlocks[1:])
+
+	var e encoder = JSONLEncoder{}
+	name, err := EncodeToFile(&e, "jsonl", "encodeToFile")
+	assert.NoError(t, err)
+	assert.Equal(t, "results/encodeToFile.jsonl", string(name))
+	//file of the task is overwritten with shorter results without leftovers
+	writeBlocks(t, "encodeToFile", testBlocks[1:])
+	_, err = EncodeToFile(&e, "jsonl", "encodeToFile")
+	assert.NoError(t, err)
+	short, err := EncodeToFile(&e, "jsonl", "encodeToFileShort")
+	assert.NoError(t, err)
+	assert.NotEqual(t, name, short)
+	for _, f := range []string{string(name), string(short)} {
+		data, err := ioutil.ReadFile(f)
+		assert.NoError(t, err)
+		assert.Equal(t, "{\"Name_text\":\"Carol\"}\n", string(data))
+		os.Remove(f)
+	}
+	os.RemoveAll("./diskv")
+}
+
+func TestJSONLEncoder(t *testing.T) {
+	os.RemoveAll("./diskv")
+	writeBlocks(t, "jsonlEncoder", testBlocks)
+
+	var e encoder = JSONLEncoder{}
+	buf := &bytes.Buffer{}
+	err := EncodeToWriter(&e, buf, "jsonlEncoder")
+	assert.NoError(t, err)
+	assert.Equal(t, `{"Name_text":"Alice","Phones_text":["1","2"]}
+{"Name_text":"Bob","Phones_text":"3"}
+{"Name_text":"Carol"}
+`, buf.String())
+
+	e = JSONLEncoder{index: true}
+	buf.Reset()
+	err = EncodeToWriter(&e, buf, "jsonlEncoder")
+	assert.NoError(t, err)
+	assert.Equal(t, `{"Name_text":"Alice","Phones_text":["1","2"],"_block":0,"_page":0}
+{"Name_text":"Bob","Phones_text":"3","_block":1,"_page":0}
+{"Name_text":"Carol","_block":0,"_page":1}
+`, buf.String())
+	os.RemoveAll("./diskv")
+}
+
+func TestCSVEncoder_Nested(t *testing.T) {
+	os.RemoveAll("./diskv")
+	writeBlocks(t, "csvNested", [][]map[string]interface{}{
+		{
+			{
+				"Name_text":    "Shirt",
+				"Brand_object": map[string]interface{}{"Name_text": "Acme"},
+				"Variants_list": []map[string]interface{}{
+					{"Size_text": "S", "Price_number": 10},
+					{"Size_text": "M", "Price_number": 12.5},
+				},
+			},
+			{"Name_text": "Hat", "Variants_list": []map[string]interface{}{{"Size_text": "L"}}},
+		},
+	})
+	sub := []Part{{Name: "Size_text"}, {Name: "Price_number"}}
+	var e encoder = CSVEncoder{
+		comma:     ",",
+		partNames: []string{"Name_text", "Brand_object", "Variants_list"},
+		nested: map[string]Part{
+			"Brand_object":  {Name: "Brand_object", Parts: []Part{{Name: "Name_text"}}},
+			"Variants_list": {Name: "Variants_list", Parts: sub, List: true},
+		},
+	}
+	buf := &bytes.Buffer{}
+	err := EncodeToWriter(&e, buf, "csvNested")
+	assert.NoError(t, err)
+	assert.Equal(t, "Name_text,Brand_object_Name_text,Variants_list_0_Size_text,Variants_list_0_Price_number,Variants_list_1_Size_text,Variants_list_1_Price_number\n"+
+		"Shirt,Acme,S,10,M,12.5\n"+
+		"Hat,,L,,,\n", buf.String())
+
+	e = XMLEncoder{}
+	buf.Reset()
+	err = EncodeToWriter(&e, buf, "csvNested")
+	assert.NoError(t, err)
+	assert.Contains(t, buf.String(), "<Variants_list><item><Size_text>L</Size_text></item></Variants_list>")
+	assert.Contains(t, buf.String(), "<Brand_object><Name_text>Acme</Name_text></Brand_object>")
+	os.RemoveAll("./diskv")
+}
+
+func TestCSVEncoder_Table(t *testing.T) {
+	os.RemoveAll("./diskv")
+	writeBlocks(t, "csvTable", [][]map[string]interface{}{
+		{
+			{"Name": "Alexa", "Phone": "808-9109"},
+			{"Name": "Hu", "Email": "at@molestie.ca"},
+		},
+	})
+	//columns of tables without mappings are found in the results
+	var e encoder = CSVEncoder{comma: ","}
+	buf := &bytes.Buffer{}
+	err := EncodeToWriter(&e, buf, "csvTable")
+	assert.NoError(t, err)
+	assert.Equal(t, "Email,Name,Phone\n,Alexa,808-9109\nat@molestie.ca,Hu,\n", buf.String())
+
+	writeBlocks(t, "csvTableField", [][]map[string]interface{}{
+		{
+			{"Persons_table": []map[string]interface{}{{"Name": "Alexa", "Phone": "808-9109"}, {"Name": "Hu"}}},
+		},
+	})
+	e = CSVEncoder{
+		comma:     ",",
+		partNames: []string{"Persons_table"},
+		nested:    map[string]Part{"Persons_table": {Name: "Persons_table", List: true}},
+	}
+	buf.Reset()
+	err = EncodeToWriter(&e, buf, "csvTableField")
+	assert.NoError(t, err)
+	assert.Equal(t, "Persons_table_0_Name,Persons_table_0_Phone,Persons_table_1_Name,Persons_table_1_Phone\nAlexa,808-9109,Hu,\n", buf.String())
+	os.RemoveAll("./diskv")
+}
@@ -0,0 +1,300 @@
+package scrape
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/slotix/dataflowkit/extract"
+	"github.com/slotix/dataflowkit/utils"
+	"golang.org/x/net/html"
+)
+
+// splitPath splits JSON path into segments. Path segments are separated by dots. Dots in keys are escaped with backslash.
+// "." and empty path refer to the value itself.
+func splitPath(path string) []string {
+	if path == "." || path == "" {
+		return nil
+	}
+	segments := []string{}
+	var seg strings.Builder
+	for i := 0; i < len(path); i++ {
+		switch {
+		case path[i] == '\\' && i+1 < len(path):
+			i++
+			seg.WriteByte(path[i])
+		case path[i] == '.':
+			segments = append(segments, seg.String())
+			seg.Reset()
+		default:
+			seg.WriteByte(path[i])
+		}
+	}
+	return append(segments, seg.String())
+}
+
+// parsePath splits field selector of JSON document into segments. Selectors starting with "$" are JSONPath expressions like "$.products[*].name" or "$['products'][0]['name']".
+// Other selectors are dot separated paths like "products.#.name".
+func parsePath(path string) ([]string, error) {
+	if !strings.HasPrefix(path, "$") {
+		return splitPath(path), nil
+	}
+	segments := []string{}
+	for i := 1; i < len(path); {
+		switch path[i] {
+		case '.':
+			if strings.HasPrefix(path[i:], "..") {
+				return nil, errors.New("Recursive descent is not supported")
+			}
+			j := i + 1
+			for j < len(path) && path[j] != '.' && path[j] != '[' {
+				j++
+			}
+			if j == i+1 {
+				return nil, errors.New("Empty key")
+			}
+			segments = append(segments, path[i+1:j])
+			i = j
+		case '[':
+			if i+1 < len(path) && (path[i+1] == '\'' || path[i+1] == '"') {
+				end := strings.IndexByte(path[i+2:], path[i+1])
+				if end < 0 || i+3+end >= len(path) || path[i+3+end] != ']' {
+					return nil, errors.New("Unterminated key")
+				}
+				segments = append(segments, path[i+2:i+2+end])
+				i += end + 4
+				continue
+			}
+			end := strings.IndexByte(path[i:], ']')
+			if end < 0 {
+				return nil, errors.New("Unterminated index")
+			}
+			index := path[i+1 : i+end]
+			if _, err := strconv.Atoi(index); err != nil && index != "*" {
+				return nil, errors.New("Unsupported index " + index)
+			}
+			segments = append(segments, index)
+			i += end + 1
+		default:
+			return nil, fmt.Errorf("Unexpected character %q", path[i])
+		}
+	}
+	if len(segments) == 0 {
+		return nil, nil
+	}
+	return segments, nil
+}
+
+// pathSegments returns segments of the selector which is validated by fields2parts.
+func pathSegments(path string) []string {
+	segments, _ := parsePath(path)
+	return segments
+}
+
+// isWildcard checks if path segment selects all the elements of array or values of object.
+func isWildcard(segment string) bool {
+	return segment == "#" || segment == "*"
+}
+
+// jsonPath returns values found by path segments in JSON value decoded with encoding/json.
+//
+// Example: "products.#.name" returns names of all the products. "products.0.name" returns the name of the first one, "products.-1.name" the name of the last one.
+func jsonPath(v interface{}, segments []string) []interface{} {
+	values := []interface{}{v}
+	for _, seg := range segments {
+		next := []interface{}{}
+		for _, value := range values {
+			switch value := value.(type) {
+			case []interface{}:
+				if isWildcard(seg) {
+					next = append(next, value...)
+				} else if i, err := strconv.Atoi(seg); err == nil {
+					//negative index counts from the end of array
+					if i < 0 {
+						i += len(value)
+					}
+					if i >= 0 && i < len(value) {
+						next = append(next, value[i])
+					}
+				}
+			case map[string]interface{}:
+				if isWildcard(seg) {
+					//object values are taken in order of keys to keep results stable
+					keys := make([]string, 0, len(value))
+					for k := range value {
+						keys = append(keys, k)
+					}
+					sort.Strings(keys)
+					for _, k := range keys {
+						next = append(next, value[k])
+					}
+				} else if field, ok := value[seg]; ok {
+					next = append(next, field)
+				}
+			}
+		}
+		values = next
+	}
+	return values
+}
+
+// divideJSON splits JSON document into blocks. Blocks are elements selected by the longest common part of field paths which ends with wildcard.
+// F.e. "products.#.name" and "products.#.price" fields divide the document into products. The number of path segments leading to the blocks is returned along with the blocks.
+// The whole document is a single block if fields have no common wildcard. Fields with "." selector refer to the block itself and don't affect division.
+func divideJSON(doc interface{}, selectors []string) ([]interface{}, int) {
+	var common []string
+	first := true
+	for _, s := range selectors {
+		segments := pathSegments(s)
+		if segments == nil {
+			continue
+		}
+		if first {
+			common, first = segments, false
+			continue
+		}
+		n := 0
+		for n < len(common) && n < len(segments) && common[n] == segments[n] {
+			n++
+		}
+		common = common[:n]
+	}
+	for n := len(common); n > 0; n-- {
+		if isWildcard(common[n-1]) {
+			return jsonPath(doc, common[:n]), n
+		}
+	}
+	return []interface{}{doc}, 0
+}
+
+// extractJSON extracts part from JSON block. Part selector is a path relative to the block after prefix segments leading to the block are trimmed.
+// Values are returned as they are decoded. Objects and arrays are returned as JSON strings.
+// Link extractors resolve relative URLs against baseURL, so the links may be followed by details.
+func extractJSON(part Part, block interface{}, prefix int, baseURL string) (interface{}, error) {
+	segments := pathSegments(part.Selector)
+	if len(segments) >= prefix {
+		segments = segments[prefix:]
+	}
+	values := jsonPath(block, segments)
+	if len(part.Parts) > 0 {
+		//sub-part paths are relative to the values
+		objects := []map[string]interface{}{}
+		for _, v := range values {
+			object := map[string]interface{}{}
+			for _, sub := range part.Parts {
+				r, err := extractJSON(sub, v, 0, baseURL)
+				if err != nil {
+					return nil, err
+				}
+				if r = extract.ApplyFilters(r, sub.Filters); r != nil {
+					object[sub.Name] = r
+				}
+			}
+			if len(object) > 0 {
+				objects = append(objects, object)
+			}
+		}
+		return nestedValue(objects, part.List), nil
+	}
+	switch e := part.Extractor.(type) {
+	case *extract.Const:
+		return e.Val, nil
+	case *extract.Count:
+		if len(values) == 0 && !e.IncludeIfEmpty {
+			return nil, nil
+		}
+		return len(values), nil
+	case *extract.Attr:
+		if e.Attr == "href" || e.Attr == "src" {
+			return jsonLinks(values, baseURL), nil
+		}
+	case *extract.Number, *extract.Price, *extract.Date, *extract.Boolean:
+		return e.Extract(jsonText(values))
+	}
+	results := []interface{}{}
+	for _, v := range values {
+		switch v.(type) {
+		case nil:
+			continue
+		case map[string]interface{}, []interface{}:
+			data, err := json.Marshal(v)
+			if err != nil {
+				return nil, err
+			}
+			v = string(data)
+		}
+		if r, ok := part.Extractor.(*extract.Regex); ok {
+			matches := r.Regex.FindAllStringSubmatch(fmt.Sprint(v), -1)
+			subexp := r.Subexpression
+			if subexp == 0 {
+				subexp = 1
+			}
+			for _, m := range matches {
+				if len(m) > subexp {
+					results = append(results, m[subexp])
+				}
+			}
+			continue
+		}
+		results = append(results, v)
+	}
+	if len(results) == 0 {
+		return nil, nil
+	}
+	if len(results) == 1 {
+		return results[0], nil
+	}
+	return results, nil
+}
+
+// jsonText returns selection of text nodes with string representation of values, so typed extractors parse them as page text.
+func jsonText(values []interface{}) *goquery.Selection {
+	nodes := []*html.Node{}
+	for _, v := range values {
+		switch v := v.(type) {
+		case nil:
+		case float64:
+			nodes = append(nodes, &html.Node{Type: html.TextNode, Data: strconv.FormatFloat(v, 'f', -1, 64)})
+		default:
+			nodes = append(nodes, &html.Node{Type: html.TextNode, Data: fmt.Sprint(v)})
+		}
+	}
+	return &goquery.Selection{Nodes: nodes}
+}
+
+// jsonLinks returns absolute URLs of string values. Single link is returned as string.
+func jsonLinks(values []interface{}, baseURL string) interface{} {
+	links := []string{}
+	for _, v := range values {
+		link, ok := v.(string)
+		if !ok {
+			continue
+		}
+		link, err := utils.RelUrl(baseURL, link)
+		if err != nil {
+			logger.Error(err)
+		}
+		links = append(links, link)
+	}
+	switch len(links) {
+	case 0:
+		return nil
+	case 1:
+		return links[0]
+	}
+	return links
+}
+
+// jsonNextPage returns absolute URL of the next page found by path in JSON document. Empty string is returned if there are no more pages.
+func jsonNextPage(uri string, doc interface{}, path string) (string, error) {
+	for _, v := range jsonPath(doc, pathSegments(path)) {
+		if next, ok := v.(string); ok && next != "" {
+			return utils.RelUrl(uri, next)
+		}
+	}
+	return "", nil
+}
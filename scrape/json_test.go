@@ -0,0 +1,175 @@
+package scrape
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/slotix/dataflowkit/errs"
+	"github.com/slotix/dataflowkit/extract"
+	"github.com/slotix/dataflowkit/fetch"
+	"github.com/stretchr/testify/assert"
+)
+
+const productsJSON = `{
+	"total": 2,
+	"products": [
+		{"name": "Book", "price": 12.5, "url": "/book", "tags": ["paper", "new"], "sizes": {"s": 1}},
+		{"name": "Pen.Blue", "price": 2, "url": "/pen", "tags": []}
+	]
+}`
+
+func TestJSONPath(t *testing.T) {
+	var doc interface{}
+	assert.NoError(t, json.Unmarshal([]byte(productsJSON), &doc))
+	assert.Equal(t, []string{"products", "#", "name"}, splitPath("products.#.name"))
+	assert.Equal(t, []string{"a.b", "c"}, splitPath(`a\.b.c`))
+	assert.Nil(t, splitPath("."))
+
+	assert.Equal(t, []interface{}{"Book", "Pen.Blue"}, jsonPath(doc, splitPath("products.#.name")))
+	assert.Equal(t, []interface{}{2.0}, jsonPath(doc, splitPath("products.1.price")))
+	assert.Equal(t, []interface{}{"paper", "new"}, jsonPath(doc, splitPath("products.0.tags.*")))
+	assert.Empty(t, jsonPath(doc, splitPath("products.5.name")))
+	assert.Empty(t, jsonPath(doc, splitPath("missing")))
+
+	blocks, prefix := divideJSON(doc, []string{"products.#.name", "products.#.price", "."})
+	assert.Len(t, blocks, 2)
+	assert.Equal(t, 2, prefix)
+	blocks, prefix = divideJSON(doc, []string{"products.#.name", "total"})
+	assert.Len(t, blocks, 1)
+	assert.Equal(t, 0, prefix)
+}
+
+func TestExtractJSON(t *testing.T) {
+	var doc interface{}
+	assert.NoError(t, json.Unmarshal([]byte(productsJSON), &doc))
+	blocks, prefix := divideJSON(doc, []string{"products.#.name", "products.#.url"})
+	book := blocks[0]
+
+	extracted := func(selector string, e extract.Extractor) interface{} {
+		v, err := extractJSON(Part{Selector: selector, Extractor: e}, book, prefix, "http://example.com/shop/")
+		assert.NoError(t, err)
+		return v
+	}
+	assert.Equal(t, "Book", extracted("products.#.name", &extract.Text{}))
+	assert.Equal(t, 12.5, extracted("products.#.price", &extract.Text{}))
+	assert.Equal(t, []interface{}{"paper", "new"}, extracted("products.#.tags.#", &extract.Text{}))
+	assert.Equal(t, `{"s":1}`, extracted("products.#.sizes", &extract.Text{}))
+	assert.Equal(t, 2, extracted("products.#.tags.#", &extract.Count{}))
+	assert.Nil(t, extracted("products.#.color", &extract.Text{}))
+	assert.Equal(t, "http://example.com/book", extracted("products.#.url", &extract.Attr{Attr: "href"}))
+	assert.Equal(t, "12", extracted("products.#.price", &extract.Regex{Regex: regexp.MustCompile(`(\d+)\.`)}))
+	assert.Equal(t, 12.5, extracted("products.#.price", &extract.Number{}))
+	assert.Equal(t, extract.PriceValue{Amount: 12.5, Currency: "EUR"}, extracted("products.#.price", &extract.Price{Currency: "EUR"}))
+	assert.Nil(t, extracted("products.#.name", &extract.Number{}))
+
+	tags := Part{Selector: "products.#.tags.#", List: true, Parts: []Part{
+		{Name: "Tag_text", Selector: ".", Extractor: &extract.Text{}, Filters: []extract.Filter{mustFilter(t, "uppercase")}},
+	}}
+	v, err := extractJSON(tags, book, prefix, "")
+	assert.NoError(t, err)
+	assert.Equal(t, []map[string]interface{}{{"Tag_text": "PAPER"}, {"Tag_text": "NEW"}}, v)
+	sizes := Part{Selector: "products.#.sizes", Parts: []Part{{Name: "S_number", Selector: "s", Extractor: &extract.Number{}}}}
+	v, err = extractJSON(sizes, book, prefix, "")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"S_number": 1.0}, v)
+}
+
+func mustFilter(t *testing.T, spec string) extract.Filter {
+	f, err := extract.ParseFilter(spec)
+	assert.NoError(t, err)
+	return f
+}
+
+func TestPayload_XHR(t *testing.T) {
+	p := Payload{
+		Name:    "products",
+		Request: fetch.Request{URL: "http://example.com"},
+		Fields: []Field{
+			{Name: "Name", Selector: "products.#.name", Extractor: Extractor{Types: []string{"text"}}},
+		},
+		XHR: "(api",
+	}
+	_, err := p.newScraper()
+	assert.IsType(t, &errs.BadPayload{}, err)
+
+	p.XHR = "/api/products"
+	task := NewTask(p)
+	assert.Equal(t, "chrome", task.Payload.Request.Type)
+	assert.Equal(t, []string{"/api/products"}, task.Payload.Request.XHR)
+	s, err := task.Payload.newScraper()
+	assert.NoError(t, err)
+	assert.True(t, s.XHR.MatchString("http://example.com/api/products?page=1"))
+}
+
+func TestParsePath(t *testing.T) {
+	var doc interface{}
+	assert.NoError(t, json.Unmarshal([]byte(productsJSON), &doc))
+	for path, segments := range map[string][]string{
+		"$":                          nil,
+		"$.products[*].name":         {"products", "*", "name"},
+		"$['products'][0][\"name\"]": {"products", "0", "name"},
+		"$.products.*.tags[-1]":      {"products", "*", "tags", "-1"},
+		"products.#.name":            {"products", "#", "name"},
+	} {
+		s, err := parsePath(path)
+		assert.NoError(t, err, path)
+		assert.Equal(t, segments, s, path)
+	}
+	for _, path := range []string{"$..name", "$.", "$[0", "$['name]", "$[?(@.price)]", "$name"} {
+		_, err := parsePath(path)
+		assert.Error(t, err, path)
+	}
+	assert.Equal(t, []interface{}{"new"}, jsonPath(doc, pathSegments("$.products[0].tags[-1]")))
+	blocks, prefix := divideJSON(doc, []string{"$.products[*].name", "$.products[*].url"})
+	assert.Len(t, blocks, 2)
+	assert.Equal(t, 2, prefix)
+}
+
+func TestJSONNextPage(t *testing.T) {
+	var doc interface{}
+	assert.NoError(t, json.Unmarshal([]byte(`{"links": {"next": "/api/products?page=2"}, "last": ""}`), &doc))
+	next, err := jsonNextPage("http://example.com/api/products", doc, "$.links.next")
+	assert.NoError(t, err)
+	assert.Equal(t, "http://example.com/api/products?page=2", next)
+	next, err = jsonNextPage("http://example.com/api/products", doc, "last")
+	assert.NoError(t, err)
+	assert.Empty(t, next)
+}
+
+func TestPayload_DocumentType(t *testing.T) {
+	p := Payload{
+		Name:    "products",
+		Request: fetch.Request{URL: "http://example.com/api/products"},
+		Fields: []Field{
+			{Name: "Name", Selector: "$.products[*].name", Extractor: Extractor{Types: []string{"text"}}},
+		},
+		Paginator:    &paginator{Selector: "$.next"},
+		DocumentType: "JSON",
+	}
+	s, err := p.newScraper()
+	assert.NoError(t, err)
+	assert.Equal(t, JSONDocument, s.DocumentType)
+
+	page, err := s.parseDocument(strings.NewReader(`{"products": [{"name": "Book"}], "next": "?page=2"}`))
+	assert.NoError(t, err)
+	assert.True(t, page.isJSON)
+	next, err := s.nextPage(p.Request.URL, page, p.Paginator)
+	assert.NoError(t, err)
+	assert.Equal(t, "http://example.com/api/products?page=2", next)
+
+	p.Paginator.Selector = "$..next"
+	_, err = p.newScraper()
+	assert.IsType(t, &errs.BadPayload{}, err)
+
+	p.Paginator = nil
+	p.DocumentType = "yaml"
+	_, err = p.newScraper()
+	assert.IsType(t, &errs.BadPayload{}, err)
+
+	p.DocumentType = XMLDocument
+	p.XHR = "/api"
+	_, err = p.newScraper()
+	assert.IsType(t, &errs.BadPayload{}, err)
+}
@@ -0,0 +1,160 @@
+package scrape
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/slotix/dataflowkit/errs"
+	"github.com/slotix/dataflowkit/storage"
+	"github.com/slotix/dataflowkit/utils"
+	"github.com/spf13/viper"
+)
+
+// DefaultRetryHTTPCodes are HTTP status codes of failed fetches which are retried if Payload.RetryHTTPCodes is not specified.
+var DefaultRetryHTTPCodes = []int{500, 502, 503, 504, 408}
+
+// retryScheduled is returned by scrape if failed fetch is re-queued. The page is fetched again when the rest of the crawl is finished.
+type retryScheduled struct {
+	err error
+}
+
+func (e *retryScheduled) Error() string {
+	return e.err.Error()
+}
+
+// retryable checks if failed fetch should be retried according to payload retry policy.
+func (task *Task) retryable(err error) bool {
+	if err == context.Canceled || err == context.DeadlineExceeded {
+		return false
+	}
+	switch e := err.(type) {
+	case *errs.StatusError:
+		//failures of fetch service like invalid Chrome actions are not fixed by retry
+		if e.Internal {
+			return false
+		}
+		codes := task.Payload.RetryHTTPCodes
+		if codes == nil {
+			codes = DefaultRetryHTTPCodes
+		}
+		for _, code := range codes {
+			if code == e.StatusCode {
+				return true
+			}
+		}
+		return false
+	case *errs.NetworkError:
+		return true
+	}
+	//fetch service is not reachable
+	_, ok := err.(net.Error)
+	return ok
+}
+
+// retryLater re-queues failed fetch of the page scraped by tw if attempts are not exhausted.
+// Every attempt is recorded to Task.Errors. Original error is returned if fetch is not retried.
+func (task *Task) retryLater(ctx context.Context, tw *taskWorker, err error) error {
+	if ctx.Err() != nil || !task.retryable(err) {
+		return err
+	}
+	url := tw.scraper.Request.URL
+	attempt := tw.attempt + 1
+	task.mx.Lock()
+	defer task.mx.Unlock()
+	if attempt > task.Payload.RetryTimes {
+		if attempt > 1 {
+			task.Errors = append(task.Errors, fmt.Errorf("Failed to fetch %s after %d attempts. %s", url, attempt, err.Error()))
+		}
+		return err
+	}
+	task.Errors = append(task.Errors, fmt.Errorf("Attempt %d of %d to fetch %s failed. %s. Retry is scheduled", attempt, task.Payload.RetryTimes+1, url, err.Error()))
+	//scraper request may be changed for the next details page. So it is copied.
+	scraper := *tw.scraper
+	retry := *tw
+	retry.scraper = &scraper
+	retry.attempt = attempt
+	task.retries = append(task.retries, &retry)
+	return &retryScheduled{err: err}
+}
+
+// retrySucceeded records the final outcome of retried fetch.
+func (task *Task) retrySucceeded(tw *taskWorker) {
+	if tw.attempt == 0 {
+		return
+	}
+	task.mx.Lock()
+	task.Errors = append(task.Errors, fmt.Errorf("Fetched %s on attempt %d", tw.scraper.Request.URL, tw.attempt+1))
+	task.mx.Unlock()
+}
+
+// retryDelay returns exponential backoff with jitter before the specified retry round.
+// Delay is doubled every round and multiplied by a random value between 0.5 and 1.5.
+func (task *Task) retryDelay(round int) time.Duration {
+	base := task.Payload.RetryDelay
+	if base == 0 {
+		base = viper.GetInt("RETRY_DELAY")
+	}
+	delay := time.Duration(base) * time.Millisecond << uint(round-1)
+	return delay * time.Duration(utils.Random(500, 1500)) / 1000
+}
+
+// retry fetches pages which failed during the crawl. Pages which fail again are re-queued for the next round until Payload.RetryTimes is exhausted.
+// Blocks waiting for retried details are written to the sink afterwards.
+func (task *Task) retry(ctx context.Context) {
+	if task.sink != nil {
+		defer task.flushSink()
+	}
+	for round := 1; ; round++ {
+		task.mx.Lock()
+		retries := task.retries
+		task.retries = nil
+		task.mx.Unlock()
+		if len(retries) == 0 {
+			return
+		}
+		select {
+		case <-time.After(task.retryDelay(round)):
+		case <-ctx.Done():
+			return
+		}
+		wg := sync.WaitGroup{}
+		for _, tw := range retries {
+			tw.wg = &wg
+			wg.Add(1)
+			go task.scrape(ctx, tw)
+		}
+		wg.Wait()
+		//key maps of details are written before details are retried. So they are updated.
+		for _, tw := range retries {
+			if tw.details {
+				task.writeKeys(tw)
+			}
+		}
+	}
+}
+
+// writeKeys saves key map of details blocks scraped by tw.
+func (task *Task) writeKeys(tw *taskWorker) {
+	task.mx.Lock()
+	for k := range tw.keys {
+		sort.Ints(tw.keys[k])
+	}
+	j, err := json.Marshal(tw.keys)
+	task.mx.Unlock()
+	if err == nil {
+		err = task.storage.Write(storage.Record{
+			Type:    storage.INTERMEDIATE,
+			Key:     tw.UID,
+			Value:   j,
+			ExpTime: 0,
+		})
+	}
+	if err != nil {
+		logger.Warning(fmt.Errorf("Failed to write %s. %s", tw.UID, err.Error()))
+	}
+}
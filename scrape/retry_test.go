@@ -0,0 +1,153 @@
+package scrape
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/slotix/dataflowkit/errs"
+	"github.com/slotix/dataflowkit/fetch"
+	"github.com/slotix/dataflowkit/storage"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryable(t *testing.T) {
+	task := NewTask(Payload{})
+	assert.True(t, task.retryable(&errs.StatusError{StatusCode: 503}))
+	assert.True(t, task.retryable(&errs.NetworkError{URL: "http://example.com", Err: errors.New("connection refused")}))
+	assert.False(t, task.retryable(&errs.StatusError{StatusCode: 404}))
+	assert.False(t, task.retryable(&errs.StatusError{StatusCode: 500, Internal: true}))
+	assert.False(t, task.retryable(&errs.NotFound{URL: "http://example.com"}))
+	assert.False(t, task.retryable(context.DeadlineExceeded))
+
+	task = NewTask(Payload{RetryHTTPCodes: []int{429}})
+	assert.True(t, task.retryable(&errs.StatusError{StatusCode: 429}))
+	assert.False(t, task.retryable(&errs.StatusError{StatusCode: 503}))
+}
+
+//fakeFetchServer emulates both fetch service and a web site. Fetch endpoint fails the specified number of times before page is returned.
+func fakeFetchServer(failures int32) (*httptest.Server, *int32) {
+	var calls int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "User-agent: *\nAllow: /")
+	})
+	mux.HandleFunc("/fetch", func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) <= failures {
+			w.Header().Set(fetch.StatusCodeHeader, "503")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			json.NewEncoder(w).Encode(map[string]string{"error": "503 Service Unavailable"})
+			return
+		}
+		io.WriteString(w, "<html><body><h1>Retried</h1></body></html>")
+	})
+	return httptest.NewServer(mux), &calls
+}
+
+func retryPayload(url string, retryTimes int) Payload {
+	return Payload{
+		Name:    "retry",
+		Request: fetch.Request{URL: url, Type: "base"},
+		Fields: []Field{
+			{Name: "Title", Selector: "h1", Extractor: Extractor{Types: []string{"text"}}},
+		},
+		Format:     "json",
+		RetryTimes: retryTimes,
+		RetryDelay: 1,
+	}
+}
+
+func TestRetry(t *testing.T) {
+	os.RemoveAll("./diskv")
+	defer os.RemoveAll("./diskv")
+	dfkFetch := viper.GetString("DFK_FETCH")
+	defer viper.Set("DFK_FETCH", dfkFetch)
+	viper.Set("IGNORE_FETCH_DELAY", true)
+	defer viper.Set("IGNORE_FETCH_DELAY", false)
+
+	srv, calls := fakeFetchServer(2)
+	defer srv.Close()
+	viper.Set("DFK_FETCH", strings.TrimPrefix(srv.URL, "http://"))
+
+	task := NewTask(retryPayload(srv.URL+"/page", 2))
+	buf := &strings.Builder{}
+	err := task.ParseTo(context.Background(), buf)
+	assert.NoError(t, err)
+	assert.Equal(t, `[{"Title_text":"Retried"}]`, buf.String())
+	assert.Equal(t, int32(3), atomic.LoadInt32(calls))
+	if assert.Len(t, task.Errors, 3) {
+		assert.Contains(t, task.Errors[0].Error(), "Attempt 1 of 3")
+		assert.Contains(t, task.Errors[1].Error(), "Attempt 2 of 3")
+		assert.Contains(t, task.Errors[2].Error(), "on attempt 3")
+	}
+
+	//attempts are exhausted
+	srv, calls = fakeFetchServer(5)
+	defer srv.Close()
+	viper.Set("DFK_FETCH", strings.TrimPrefix(srv.URL, "http://"))
+	p := retryPayload(srv.URL+"/page", 1)
+	//base fetcher falls back to chrome if nothing is parsed
+	p.Request.Type = "chrome"
+	task = NewTask(p)
+	err = task.ParseTo(context.Background(), buf)
+	assert.Error(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(calls))
+	assert.Contains(t, task.Errors[1].Error(), "after 2 attempts")
+}
+
+type fakeSink struct {
+	blocks map[string]map[string]interface{}
+}
+
+func (s *fakeSink) Open(scraper Scraper) error {
+	s.blocks = make(map[string]map[string]interface{})
+	return nil
+}
+
+func (s *fakeSink) Write(id string, block map[string]interface{}) error {
+	s.blocks[id] = block
+	return nil
+}
+
+func (s *fakeSink) Close() error {
+	return nil
+}
+
+func TestWriteToSink_Retries(t *testing.T) {
+	os.RemoveAll("./diskv")
+	defer os.RemoveAll("./diskv")
+	sink := &fakeSink{}
+	sink.Open(Scraper{})
+	task := NewTask(Payload{Name: "test"})
+	task.storage = storage.NewStore(viper.GetString("STORAGE_TYPE"))
+	defer task.storage.Close()
+	task.sink = sink
+
+	//blocks without details are written at once
+	task.retries = []*taskWorker{{}}
+	task.writeToSink("0-0", []byte(`{"Name_text":"Alice"}`))
+	assert.Contains(t, sink.blocks, task.ID+"-0-0")
+	//blocks with details wait until details are re-fetched
+	task.writeToSink("0-1", []byte(`{"Name_text":"Bob","Link_href_details":"retriedDetails"}`))
+	assert.NotContains(t, sink.blocks, task.ID+"-0-1")
+	writeBlocks(t, "retriedDetails", [][]map[string]interface{}{
+		{
+			{"Description_text": "Retried"},
+		},
+	})
+	task.retries = nil
+	task.flushSink()
+	if assert.Contains(t, sink.blocks, task.ID+"-0-1") {
+		assert.Equal(t, map[string]interface{}{"Description_text": "Retried"}, sink.blocks[task.ID+"-0-1"]["Link_href_details"])
+	}
+	assert.Empty(t, task.sinkPending)
+	assert.Empty(t, task.Errors)
+}
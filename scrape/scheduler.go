@@ -0,0 +1,101 @@
+package scrape
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/slotix/dataflowkit/fetch"
+)
+
+// scheduler dispatches fetch requests to per host queues. Every host is served by its own workers so that crawls of several domains run in parallel.
+// Requests to the same host are limited by concurrency and spaced by delay returned for the request.
+type scheduler struct {
+	ctx context.Context
+	//concurrency is a maximum number of simultaneous requests to a host
+	concurrency int
+	//delay returns minimum interval between the request and the previous request to the same host
+	delay func(req fetch.Request) time.Duration
+	fetch func(ctx context.Context, req fetch.Request) (*fetch.Response, error)
+	mx    sync.Mutex
+	hosts map[string]*hostQueue
+}
+
+// hostQueue holds pending requests to a single host.
+type hostQueue struct {
+	requests chan *fetchInfo
+	mx       sync.Mutex
+	//next is the earliest time the next request to the host may be sent
+	next time.Time
+}
+
+func newScheduler(ctx context.Context, concurrency int, delay func(req fetch.Request) time.Duration) *scheduler {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return &scheduler{
+		ctx:         ctx,
+		concurrency: concurrency,
+		delay:       delay,
+		fetch:       fetchContent,
+		hosts:       make(map[string]*hostQueue),
+	}
+}
+
+// queue returns request queue of the host. Workers of the host are started on first use.
+func (s *scheduler) queue(host string) chan<- *fetchInfo {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	q, ok := s.hosts[host]
+	if !ok {
+		q = &hostQueue{requests: make(chan *fetchInfo, 100)}
+		s.hosts[host] = q
+		for i := 0; i < s.concurrency; i++ {
+			go s.worker(q)
+		}
+	}
+	return q.requests
+}
+
+func (s *scheduler) worker(q *hostQueue) {
+	for fi := range q.requests {
+		if err := q.wait(s.ctx, s.delay(fi.request)); err != nil {
+			fi.err <- err
+			continue
+		}
+		resp, err := s.fetch(s.ctx, fi.request)
+		if err != nil {
+			fi.err <- err
+		} else {
+			fi.result <- resp
+		}
+	}
+}
+
+// wait reserves the next time slot of the host and sleeps until it comes. Slots are spaced by delay.
+func (q *hostQueue) wait(ctx context.Context, delay time.Duration) error {
+	q.mx.Lock()
+	now := time.Now()
+	start := q.next
+	if start.Before(now) {
+		start = now
+	}
+	q.next = start.Add(delay)
+	q.mx.Unlock()
+	select {
+	case <-time.After(start.Sub(now)):
+		return ctx.Err()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// close stops workers of all the hosts. No requests may be scheduled after close.
+func (s *scheduler) close() {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	for host, q := range s.hosts {
+		close(q.requests)
+		delete(s.hosts, host)
+	}
+}
@@ -0,0 +1,87 @@
+package scrape
+
+import (
+	"context"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/slotix/dataflowkit/fetch"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/temoto/robotstxt"
+)
+
+func TestScheduler(t *testing.T) {
+	delay := 50 * time.Millisecond
+	s := newScheduler(context.Background(), 1, func(req fetch.Request) time.Duration {
+		return delay
+	})
+	defer s.close()
+	mx := sync.Mutex{}
+	started := map[string][]time.Time{}
+	s.fetch = func(ctx context.Context, req fetch.Request) (*fetch.Response, error) {
+		host, _ := req.Host()
+		mx.Lock()
+		started[host] = append(started[host], time.Now())
+		mx.Unlock()
+		return &fetch.Response{Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+	}
+
+	begin := time.Now()
+	wg := sync.WaitGroup{}
+	for i := 0; i < 3; i++ {
+		for _, host := range []string{"a.com", "b.com"} {
+			wg.Add(1)
+			result := make(chan *fetch.Response, 1)
+			s.queue(host) <- &fetchInfo{
+				request: fetch.Request{URL: "http://" + host},
+				result:  result,
+				err:     make(chan error, 1),
+			}
+			go func() {
+				<-result
+				wg.Done()
+			}()
+		}
+	}
+	wg.Wait()
+	for host, times := range started {
+		assert.Len(t, times, 3, host)
+		//hosts are crawled in parallel
+		assert.True(t, times[0].Sub(begin) < delay, host)
+		//requests to the same host are spaced by delay
+		for i := 1; i < len(times); i++ {
+			assert.True(t, times[i].Sub(times[i-1]) >= delay-5*time.Millisecond, host)
+		}
+	}
+}
+
+func TestHostDelay(t *testing.T) {
+	ignore := viper.GetBool("IGNORE_FETCH_DELAY")
+	defer viper.Set("IGNORE_FETCH_DELAY", ignore)
+	viper.Set("IGNORE_FETCH_DELAY", false)
+	viper.Set("RANDOMIZE_FETCH_DELAY", false)
+	defer viper.Set("RANDOMIZE_FETCH_DELAY", true)
+	viper.Set("FETCH_DELAY", 500)
+	defer viper.Set("FETCH_DELAY", 0)
+
+	task := NewTask(Payload{})
+	assert.Equal(t, 500*time.Millisecond, task.hostDelay(fetch.Request{URL: "http://example.com"}))
+	//Crawl-delay is used as a floor
+	robots, err := robotstxt.FromString("User-agent: *\nCrawl-delay: 2")
+	assert.NoError(t, err)
+	task.Robots["example.com"] = robots
+	assert.Equal(t, 2*time.Second, task.hostDelay(fetch.Request{URL: "http://example.com"}))
+	//Crawl-delay is matched against the request User-Agent
+	robots, err = robotstxt.FromString("User-agent: SlowBot\nCrawl-delay: 3\n\nUser-agent: *\nCrawl-delay: 1")
+	assert.NoError(t, err)
+	task.Robots["example.com"] = robots
+	assert.Equal(t, 3*time.Second, task.hostDelay(fetch.Request{URL: "http://example.com", UserAgent: "SlowBot/1.0"}))
+	assert.Equal(t, time.Second, task.hostDelay(fetch.Request{URL: "http://example.com"}))
+
+	viper.Set("IGNORE_FETCH_DELAY", true)
+	assert.Equal(t, time.Duration(0), task.hostDelay(fetch.Request{URL: "http://example.com"}))
+}
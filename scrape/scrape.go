@@ -1,7 +1,7 @@
 package scrape
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -26,14 +26,13 @@ import (
 	"github.com/slotix/dataflowkit/logger"
 	"github.com/slotix/dataflowkit/paginate"
 	"github.com/slotix/dataflowkit/utils"
+	"github.com/slotix/dataflowkit/xpath"
 	"github.com/spf13/viper"
 	"github.com/temoto/robotstxt"
 )
 
 var logger *logrus.Logger
 
-var fetchCannel chan *fetchInfo
-
 func init() {
 	logger = log.NewLogger(true)
 }
@@ -61,6 +60,14 @@ func NewTask(p Payload) *Task {
 			p.Request.Type = "chrome"
 		}
 	}
+	//XHR responses of the page are captured along with the document
+	if p.XHR != "" && !utils.ArrayContains(p.Request.XHR, p.XHR) {
+		p.Request.XHR = append(p.Request.XHR, p.XHR)
+	}
+	//actions, wait conditions, captures and XHR are applied by Chrome fetcher only
+	if len(p.Request.Actions) > 0 || p.Request.Wait != nil || p.Request.Capture != nil || len(p.Request.XHR) > 0 {
+		p.Request.Type = "chrome"
+	}
 	if p.PaginateResults == nil {
 		pag := viper.GetBool("PAGINATE_RESULTS")
 		p.PaginateResults = &pag
@@ -78,26 +85,118 @@ func NewTask(p Payload) *Task {
 		BlockCounter: []int{},
 		storage:      storage.NewStore(storageType),
 		mx:           &sync.Mutex{},
+		infoMx:       &sync.Mutex{},
 	}
 
 }
 
 // Parse processes specified task which parses fetched page.
-func (task *Task) Parse() (io.ReadCloser, error) {
+// Encoded results are written to the file in RESULTS_DIR. The name of results file is returned.
+// Task state is saved to storage during parsing. It may be polled with LoadTaskInfo.
+//
+// Scraping is stopped when ctx is cancelled or Payload.Timeout is exceeded. In that case results parsed so far are returned along with errs.Canceled error.
+func (task *Task) Parse(ctx context.Context) (io.ReadCloser, error) {
+	err := task.run(ctx, func(e encoder, uid string) error {
+		r, err := EncodeToFile(&e, task.Payload.Format, uid)
+		if err != nil {
+			return err
+		}
+		task.mx.Lock()
+		task.Result = string(r)
+		task.mx.Unlock()
+		return nil
+	})
+	if task.Result == "" {
+		return nil, err
+	}
+	return ioutil.NopCloser(strings.NewReader(task.Result)), err
+}
 
+// ParseTo processes specified task the same way as Parse does. But encoded results are streamed to w instead of results file.
+// Each block is flushed to w as soon as it is read from storage.
+func (task *Task) ParseTo(ctx context.Context, w io.Writer) error {
+	return task.run(ctx, func(e encoder, uid string) error {
+		return EncodeToWriter(&e, w, uid)
+	})
+}
+
+// run scrapes pages and passes encoder for parsed results to output func. It keeps task state up to date.
+func (task *Task) run(ctx context.Context, output func(e encoder, uid string) error) error {
+	defer task.storage.Close()
+	if task.Payload.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(task.Payload.Timeout)*time.Second)
+		defer cancel()
+	}
+	err := task.setStatus(TaskRunning)
+	if err != nil {
+		logger.Error(err)
+	}
+	e, uid, err := task.parse(ctx)
+	if err == nil {
+		err = output(e, uid)
+	}
+	if err != nil {
+		task.fail(err)
+		return err
+	}
+	//partial results parsed before cancellation are available
+	if ctx.Err() != nil {
+		err = &errs.Canceled{Err: ctx.Err()}
+		task.mx.Lock()
+		task.Errors = append(task.Errors, err)
+		task.mx.Unlock()
+	}
+	if err := task.setStatus(TaskFinished); err != nil {
+		logger.Error(err)
+	}
+	return err
+}
+
+func (task *Task) fail(err error) {
+	task.mx.Lock()
+	task.Errors = append(task.Errors, err)
+	task.mx.Unlock()
+	if err := task.setStatus(TaskFailed); err != nil {
+		logger.Error(err)
+	}
+}
+
+// parse scrapes all the pages and returns encoder along with results key.
+func (task *Task) parse(ctx context.Context) (encoder, string, error) {
 	scraper, err := task.Payload.newScraper()
 	if err != nil {
-		return nil, err
+		return nil, "", err
+	}
+	if task.Payload.Sink != nil {
+		sink, err := newSink(*task.Payload.Sink, task.Payload.Name)
+		if err != nil {
+			return nil, "", &errs.BadPayload{ParserError: err.Error()}
+		}
+		err = sink.Open(*scraper)
+		if err != nil {
+			return nil, "", err
+		}
+		defer sink.Close()
+		task.sink = sink
 	}
 	//scrape request and return results.
 
-	fetchCannel = make(chan *fetchInfo, 100)
-	for i := 0; i < 50; i++ {
-		go task.fetchWorker(fetchCannel)
+	concurrency := task.Payload.HostConcurrency
+	if concurrency == 0 {
+		concurrency = viper.GetInt("HOST_CONCURRENCY")
+	}
+	task.scheduler = newScheduler(ctx, concurrency, task.hostDelay)
+	agents := task.Payload.UserAgents
+	if len(agents) == 0 {
+		agents = viper.GetStringSlice("USER_AGENTS")
 	}
+	task.userAgents = fetch.NewUserAgentPool(agents)
 	// Array of page keys
 	wg := sync.WaitGroup{}
-	uid := string(utils.GenerateCRC32([]byte(task.Payload.PayloadMD5)))
+	//blocks are keyed by task ID, so tasks with the same payload don't share them
+	uid := task.ID
+	task.uid = uid
 	mx := sync.Mutex{}
 	tw := taskWorker{
 		wg:              &wg,
@@ -109,13 +208,23 @@ func (task *Task) Parse() (io.ReadCloser, error) {
 		keys:            make(map[int][]int),
 	}
 	wg.Add(1)
-	_, err = task.scrape(&tw)
+	_, err = task.scrape(ctx, &tw)
 	wg.Wait()
+	task.retry(ctx)
+	if r, ok := err.(*retryScheduled); ok {
+		err = r.err
+	}
+	if !task.Parsed && ctx.Err() != nil {
+		task.scheduler.close()
+		return nil, "", &errs.Canceled{Err: ctx.Err()}
+	}
 	if !task.Parsed {
 		logger.Info("Failed to scrape with base fetcher. Reinitializing to scrape with Chrome fetcher.")
-		if task.Payload.Request.Type == "chrome" {
-			close(fetchCannel)
-			return nil, err
+		//Chrome renders JSON and XML documents as HTML, so they are not refetched. Chrome doesn't use pinned proxies either.
+		if task.Payload.Request.Type == "chrome" || scraper.DocumentType != HTMLDocument ||
+			task.Payload.Request.Proxy != "" || task.Payload.Request.ProxyGroup != "" {
+			task.scheduler.close()
+			return nil, "", err
 		}
 		task.Payload.Request.Type = "chrome"
 		scraper.Request.Type = "chrome"
@@ -123,14 +232,18 @@ func (task *Task) Parse() (io.ReadCloser, error) {
 		//task.Payload.Request = request
 		//scraper.Request = request
 		wg.Add(1)
-		_, err = task.scrape(&tw)
+		_, err = task.scrape(ctx, &tw)
 		wg.Wait()
+		task.retry(ctx)
+		if r, ok := err.(*retryScheduled); ok {
+			err = r.err
+		}
 		if !task.Parsed {
-			close(fetchCannel)
-			return nil, err
+			task.scheduler.close()
+			return nil, "", err
 		}
 	}
-	close(fetchCannel)
+	task.scheduler.close()
 
 	if len(task.BlockCounter) > 0 {
 		tw.keys[0] = task.BlockCounter
@@ -143,7 +256,7 @@ func (task *Task) Parse() (io.ReadCloser, error) {
 
 	j, err := json.Marshal(tw.keys)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 	err = task.storage.Write(storage.Record{
 		Type:    storage.INTERMEDIATE,
@@ -152,17 +265,16 @@ func (task *Task) Parse() (io.ReadCloser, error) {
 		ExpTime: 0,
 	})
 	if err != nil {
-		return nil, fmt.Errorf("Cannot write parse results key map. %s", err.Error())
+		return nil, "", fmt.Errorf("Cannot write parse results key map. %s", err.Error())
 	}
 
-	task.storage.Close()
-
 	var e encoder
 	switch strings.ToLower(task.Payload.Format) {
 	case "csv":
 		e = CSVEncoder{
 			comma:     ",",
 			partNames: scraper.partNames(),
+			nested:    scraper.nestedParts(),
 		}
 	case "json":
 		e = JSONEncoder{
@@ -170,69 +282,243 @@ func (task *Task) Parse() (io.ReadCloser, error) {
 		}
 	case "xml":
 		e = XMLEncoder{}
+	case "jsonl", "ndjson":
+		e = JSONLEncoder{
+			index: task.Payload.IndexResults,
+		}
+	case "parquet":
+		e = ParquetEncoder{
+			columns: scraper.columns(),
+		}
+	case "avro":
+		e = AvroEncoder{
+			name:    task.Payload.Name,
+			columns: scraper.columns(),
+		}
 	default:
-		return nil, errors.New("invalid output format specified")
-	}
-	r, err := EncodeToFile(&e, task.Payload.Format, string(uid))
-	if err != nil {
-		return nil, err
+		return nil, "", errors.New("invalid output format specified")
 	}
-	fName := ioutil.NopCloser(bytes.NewReader(r))
-	return fName, err
+	return e, uid, nil
 }
 
 // Create a new scraper with the provided configuration.
 func (p Payload) newScraper() (*Scraper, error) {
+	if p.Table != nil {
+		return p.newTableScraper()
+	}
 	parts, err := p.fields2parts()
 	if err != nil {
 		return nil, err
 	}
+	docType, err := p.documentType()
+	if err != nil {
+		return nil, err
+	}
+	isHTML := docType != XMLDocument
 	var paginator paginate.Paginator
 	if p.Paginator == nil {
 		paginator = &dummyPaginator{}
 
 	} else {
-		paginator = paginate.BySelector(p.Paginator.Selector, p.Paginator.Attribute)
+		paginator = paginate.BySelector(p.Paginator.Selector, p.Paginator.Attribute, isHTML)
 	}
 
 	selectors, err := p.selectors()
 	if err != nil {
 		return nil, err
 	}
+	//XHR responses are captured along with HTML rendered by Chrome
+	if p.XHR != "" && docType != HTMLDocument {
+		return nil, &errs.BadPayload{ParserError: "XHR requires html document type"}
+	}
 
 	var dividePageFunc DividePageFunc
 
-	dividePageFunc = DividePageByIntersection(selectors)
+	dividePageFunc = DividePageByIntersection(selectors, isHTML)
+	if p.BlockSelector != "" {
+		if docType == JSONDocument || p.XHR != "" {
+			if _, err := parsePath(p.BlockSelector); err != nil {
+				return nil, &errs.BadPayload{ParserError: fmt.Sprintf("Invalid JSON path %s. %s", p.BlockSelector, err.Error())}
+			}
+		} else if err := xpath.Validate(p.BlockSelector, isHTML); err != nil {
+			return nil, &errs.BadPayload{ParserError: err.Error()}
+		}
+		dividePageFunc = DividePageBySelector(p.BlockSelector, isHTML)
+	}
 
 	scraper := &Scraper{
-		Request:    p.Request,
-		DividePage: dividePageFunc,
-		Parts:      parts,
-		Paginator:  paginator,
-		IsPath:     p.IsPath,
+		Request:       p.Request,
+		DividePage:    dividePageFunc,
+		BlockSelector: p.BlockSelector,
+		Parts:         parts,
+		Paginator:     paginator,
+		IsPath:        p.IsPath,
+		DocumentType:  docType,
+	}
+	if p.XHR != "" {
+		scraper.XHR, err = regexp.Compile(p.XHR)
+		if err != nil {
+			return nil, &errs.BadPayload{ParserError: fmt.Sprintf("Invalid XHR pattern %s. %s", p.XHR, err.Error())}
+		}
+	}
+	if err := p.checkColumns(scraper); err != nil {
+		return nil, err
 	}
 
 	// All set!
 	return scraper, nil
 }
 
+// newTableScraper creates a scraper which turns rows of tables into records.
+func (p Payload) newTableScraper() (*Scraper, error) {
+	if len(p.Fields) > 0 {
+		return nil, &errs.BadPayload{ParserError: "Fields are not used with table"}
+	}
+	if p.BlockSelector != "" {
+		return nil, &errs.BadPayload{ParserError: "blockSelector is not used with table"}
+	}
+	if p.Table.Selector == "" {
+		return nil, &errs.BadPayload{ParserError: fmt.Sprintf(errs.ErrNoPartOrSelectorProvided, "table")}
+	}
+	docType, err := p.documentType()
+	if err != nil {
+		return nil, err
+	}
+	if p.XHR != "" || docType == JSONDocument {
+		return nil, &errs.BadPayload{ParserError: "Tables are parsed from html and xml documents only"}
+	}
+	isHTML := docType != XMLDocument
+	if err := xpath.Validate(p.Table.Selector, isHTML); err != nil {
+		return nil, &errs.BadPayload{ParserError: err.Error()}
+	}
+	table := &extract.Table{Columns: p.Table.Columns}
+	if len(table.Columns) == 0 && p.columnar() {
+		return nil, &errs.BadPayload{ParserError: "Table columns are required for Parquet and Avro formats and output sinks"}
+	}
+	var paginator paginate.Paginator = &dummyPaginator{}
+	if p.Paginator != nil {
+		if err := xpath.Validate(p.Paginator.Selector, isHTML); err != nil {
+			return nil, &errs.BadPayload{ParserError: err.Error()}
+		}
+		paginator = paginate.BySelector(p.Paginator.Selector, p.Paginator.Attribute, isHTML)
+	}
+	scraper := &Scraper{
+		Request:      p.Request,
+		Paginator:    paginator,
+		DocumentType: docType,
+		Table: &Part{
+			Name:      "table",
+			Selector:  p.Table.Selector,
+			Extractor: table,
+		},
+	}
+	if err := p.checkColumns(scraper); err != nil {
+		return nil, err
+	}
+	return scraper, nil
+}
+
+// columnar checks if results are written to Parquet, Avro or output sink, which require a schema.
+func (p Payload) columnar() bool {
+	format := strings.ToLower(p.Format)
+	return p.Sink != nil || format == "parquet" || format == "avro"
+}
+
+// checkColumns checks that column names of columnar results are unique.
+func (p Payload) checkColumns(scraper *Scraper) error {
+	if !p.columnar() {
+		return nil
+	}
+	if err := checkFields(scraper.columns()); err != nil {
+		return &errs.BadPayload{ParserError: err.Error()}
+	}
+	return nil
+}
+
 //fields2parts converts payload []field to []scrape.Part
 func (p Payload) fields2parts() ([]Part, error) {
-	parts := []Part{}
-	//Payload fields
+	fields := []Field{}
 	for _, f := range p.Fields {
 		if p.IsPath && !utils.ArrayContains(f.Extractor.Types, "path") {
 			continue
 		}
+		fields = append(fields, f)
+	}
+	parts, err := p.fieldParts(fields)
+	if err != nil {
+		return nil, err
+	}
+	// Validate payload fields
+	if len(parts) == 0 {
+		return nil, &errs.BadPayload{errs.ErrNoParts}
+	}
+
+	for _, part := range allParts(parts) {
+		if len(part.Name) == 0 || len(part.Selector) == 0 {
+			e := fmt.Sprintf(errs.ErrNoPartOrSelectorProvided, part.Name+part.Selector)
+			return nil, &errs.BadPayload{e}
+		}
+
+	}
+	selectors := []string{}
+	for _, part := range allParts(parts) {
+		selectors = append(selectors, part.Selector)
+	}
+	if p.Paginator != nil && !p.Paginator.InfiniteScroll {
+		selectors = append(selectors, p.Paginator.Selector)
+	}
+	//selectors of JSON documents are paths. Others are CSS selectors or XPath expressions. "." refers to the block itself
+	docType, _ := p.documentType()
+	for _, s := range selectors {
+		if s == "." {
+			continue
+		}
+		if docType == JSONDocument || p.XHR != "" {
+			if _, err := parsePath(s); err != nil {
+				return nil, &errs.BadPayload{ParserError: fmt.Sprintf("Invalid JSON path %s. %s", s, err.Error())}
+			}
+		} else if err := xpath.Validate(s, docType != XMLDocument); err != nil {
+			return nil, &errs.BadPayload{ParserError: err.Error()}
+		}
+	}
+	return parts, nil
+}
+
+// fieldParts converts fields to parts. Sub-fields of object and list fields are converted to sub-parts.
+func (p Payload) fieldParts(fields []Field) ([]Part, error) {
+	parts := []Part{}
+	for _, f := range fields {
 		params := make(map[string]interface{})
 		if f.Extractor.Params != nil {
 			params = f.Extractor.Params
 		}
+		filters, err := extract.ParseFilters(f.Extractor.Filters)
+		if err != nil {
+			return nil, &errs.BadPayload{ParserError: err.Error()}
+		}
 
 		for _, t := range f.Extractor.Types {
 			part := Part{
 				Name:     f.Name + "_" + t,
 				Selector: f.Selector,
+				Filters:  filters,
+			}
+			if t := strings.ToLower(t); t == "object" || t == "list" {
+				if len(f.Fields) == 0 {
+					return nil, &errs.BadPayload{ParserError: fmt.Sprintf("No fields specified for %s field %s", t, f.Name)}
+				}
+				for _, sub := range f.Fields {
+					if sub.Details != nil {
+						return nil, &errs.BadPayload{ParserError: fmt.Sprintf("Details are not supported in %s field %s", t, f.Name)}
+					}
+				}
+				part.Parts, err = p.fieldParts(f.Fields)
+				if err != nil {
+					return nil, err
+				}
+				part.List = t == "list"
+				parts = append(parts, part)
+				continue
 			}
 			e, err := p.newExtractor(t, &f, &part, &params)
 			if err != nil {
@@ -245,28 +531,24 @@ func (p Payload) fields2parts() ([]Part, error) {
 			parts = append(parts, part)
 		}
 	}
-	// Validate payload fields
-	if len(parts) == 0 {
-		return nil, &errs.BadPayload{errs.ErrNoParts}
-	}
+	return parts, nil
+}
 
+// allParts returns parts along with sub-parts of object and list parts.
+func allParts(parts []Part) []Part {
+	all := []Part{}
 	for _, part := range parts {
-		if len(part.Name) == 0 || len(part.Selector) == 0 {
-			e := fmt.Sprintf(errs.ErrNoPartOrSelectorProvided, part.Name+part.Selector)
-			return nil, &errs.BadPayload{e}
-		}
-
+		all = append(all, part)
+		all = append(all, allParts(part.Parts)...)
 	}
-	return parts, nil
+	return all
 }
 
 func (p Payload) newExtractor(t string, f *Field, part *Part, params *map[string]interface{}) (*extract.Extractor, error) {
 	var e extract.Extractor
 	switch strings.ToLower(t) {
 	case "text":
-		e = &extract.Text{
-			Filters: f.Extractor.Filters,
-		}
+		e = &extract.Text{}
 	case "href", "src", "path":
 		extrAttr := t
 		if t == "path" {
@@ -283,19 +565,24 @@ func (p Payload) newExtractor(t string, f *Field, part *Part, params *map[string
 			detailsPayload.Fields = f.Details.Fields
 			detailsPayload.Paginator = f.Details.Paginator
 			detailsPayload.IsPath = f.Details.IsPath
+			//details pages have their own blocks
+			detailsPayload.BlockSelector = f.Details.BlockSelector
+			if f.Details.DocumentType != "" {
+				detailsPayload.DocumentType = f.Details.DocumentType
+			}
+			//details pages are scraped from the DOM
+			detailsPayload.XHR = ""
 			//Request refers to  srarting URL here. Requests will be changed in Scrape function to Details pages afterwards
 			scraper, err := detailsPayload.newScraper()
 			if err != nil {
 				return nil, err
 			}
+			scraper.Capture = f.Details.Capture
 			part.Details = *scraper
 		}
 
 	case "alt":
-		e = &extract.Attr{
-			Attr:    t,
-			Filters: f.Extractor.Filters,
-		}
+		e = &extract.Attr{Attr: t}
 	case "width", "height":
 		e = &extract.Attr{Attr: t}
 	case "regex":
@@ -309,10 +596,71 @@ func (p Payload) newExtractor(t string, f *Field, part *Part, params *map[string
 		e = &extract.Const{Val: (*params)["value"]}
 	case "count":
 		e = &extract.Count{}
+	case "number":
+		locale, err := stringParam(*params, "locale")
+		if err != nil {
+			return nil, err
+		}
+		e = &extract.Number{Locale: locale}
+	case "price":
+		locale, err := stringParam(*params, "locale")
+		if err != nil {
+			return nil, err
+		}
+		currency, err := stringParam(*params, "currency")
+		if err != nil {
+			return nil, err
+		}
+		e = &extract.Price{Locale: locale, Currency: currency}
+	case "date":
+		d := &extract.Date{}
+		layouts, err := stringsParam(*params, "layouts")
+		if err != nil {
+			return nil, err
+		}
+		layout, err := stringParam(*params, "layout")
+		if err != nil {
+			return nil, err
+		}
+		if layout != "" {
+			layouts = append([]string{layout}, layouts...)
+		}
+		d.Layouts = layouts
+		tz, err := stringParam(*params, "timezone")
+		if err != nil {
+			return nil, err
+		}
+		if tz != "" {
+			if d.Location, err = extract.ParseLocation(tz); err != nil {
+				return nil, &errs.BadPayload{ParserError: err.Error()}
+			}
+		}
+		e = d
+	case "boolean":
+		b := &extract.Boolean{}
+		truthy, err := stringParam(*params, "true")
+		if err != nil {
+			return nil, err
+		}
+		if truthy != "" {
+			if b.Truthy, err = regexp.Compile(truthy); err != nil {
+				return nil, &errs.BadPayload{ParserError: fmt.Sprintf("Invalid boolean pattern %s. %s", truthy, err.Error())}
+			}
+		}
+		e = b
 	// case "html":
 	// 	e = &extract.Html{}
 	case "outerhtml":
 		e = &extract.OuterHtml{}
+	case "table":
+		if docType, _ := p.documentType(); p.XHR != "" || docType == JSONDocument {
+			return nil, &errs.BadPayload{ParserError: "Tables are parsed from html and xml documents only"}
+		}
+		columns, err := tableColumns(*params)
+		if err != nil {
+			return nil, err
+		}
+		e = &extract.Table{Columns: columns}
 
 	default:
 		logger.Error(errors.New(t + ": Unknown selector type"))
@@ -321,6 +669,57 @@ func (p Payload) newExtractor(t string, f *Field, part *Part, params *map[string
 	return &e, nil
 }
 
+// tableColumns returns column mappings of table extractor. Every column is an object with header or index of the column and the name of record key.
+func tableColumns(params map[string]interface{}) ([]extract.TableColumn, error) {
+	columns := []extract.TableColumn{}
+	v, ok := params["columns"]
+	if !ok || v == nil {
+		return columns, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, &errs.BadPayload{ParserError: err.Error()}
+	}
+	if err := json.Unmarshal(data, &columns); err != nil {
+		return nil, &errs.BadPayload{ParserError: "Extractor parameter columns must be a list of objects with header, index and name. " + err.Error()}
+	}
+	return columns, nil
+}
+
+// stringParam returns optional string parameter of the extractor.
+func stringParam(params map[string]interface{}, name string) (string, error) {
+	v, ok := params[name]
+	if !ok || v == nil {
+		return "", nil
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", &errs.BadPayload{ParserError: fmt.Sprintf("Extractor parameter %s must be a string", name)}
+	}
+	return s, nil
+}
+
+// stringsParam returns optional parameter of the extractor which is a list of strings.
+func stringsParam(params map[string]interface{}, name string) ([]string, error) {
+	v, ok := params[name]
+	if !ok || v == nil {
+		return nil, nil
+	}
+	list, ok := v.([]interface{})
+	if !ok {
+		return nil, &errs.BadPayload{ParserError: fmt.Sprintf("Extractor parameter %s must be a list of strings", name)}
+	}
+	result := []string{}
+	for _, item := range list {
+		s, ok := item.(string)
+		if !ok {
+			return nil, &errs.BadPayload{ParserError: fmt.Sprintf("Extractor parameter %s must be a list of strings", name)}
+		}
+		result = append(result, s)
+	}
+	return result, nil
+}
+
 func (task *Task) allowedByRobots(req fetch.Request) error {
 	//get Robotstxt Data
 	host, err := req.Host()
@@ -329,7 +728,10 @@ func (task *Task) allowedByRobots(req fetch.Request) error {
 		logger.Error(err)
 		//return err
 	}
-	if _, ok := task.Robots[host]; !ok {
+	task.mx.Lock()
+	_, ok := task.Robots[host]
+	task.mx.Unlock()
+	if !ok {
 		robots, err := fetch.RobotstxtData(req.URL)
 		if err != nil {
 			robotsURL, err1 := fetch.AssembleRobotstxtURL(req.URL)
@@ -344,21 +746,29 @@ func (task *Task) allowedByRobots(req fetch.Request) error {
 			//logger.Warning(err)
 			//return err
 		}
+		task.mx.Lock()
 		task.Robots[host] = robots
+		task.mx.Unlock()
 	}
 
 	//check if scraping of current url is not forbidden
-	if !fetch.AllowedByRobots(req.URL, task.Robots[host]) {
+	task.mx.Lock()
+	defer task.mx.Unlock()
+	if !fetch.AllowedByRobots(req.URL, task.Robots[host], req.UserAgent) {
 		task.Errors = append(task.Errors, &errs.ForbiddenByRobots{req.URL})
 	}
 	return nil
 }
 
 // scrape is a core function which follows the rules listed in task payload, processes all pages/ details pages. It stores parsed results to Task.Results
-func (task *Task) scrape(tw *taskWorker) (*Results, error) {
+func (task *Task) scrape(ctx context.Context, tw *taskWorker) (*Results, error) {
 
 	req := tw.scraper.Request
 	url := req.URL
+	//the same agent is used to fetch the page and to check robots.txt rules
+	if req.UserAgent == "" {
+		req.UserAgent = task.userAgents.Next()
+	}
 
 	err := task.allowedByRobots(req)
 	if err != nil {
@@ -368,24 +778,49 @@ func (task *Task) scrape(tw *taskWorker) (*Results, error) {
 
 	//call remote fetcher to download web page
 	//content, err := fetchContent(req)
-	errorChan := make(chan error)
-	resultChan := make(chan io.ReadCloser)
+	//channels are buffered so scheduler never blocks if this scrape is cancelled
+	errorChan := make(chan error, 1)
+	resultChan := make(chan *fetch.Response, 1)
+	//requests are queued per host to keep crawling polite
+	host, _ := req.Host()
 	fi := fetchInfo{
 		request: req,
 		result:  resultChan,
 		err:     errorChan,
 	}
-	fetchCannel <- &fi
-	var content io.ReadCloser
+	select {
+	case task.scheduler.queue(host) <- &fi:
+	case <-ctx.Done():
+		tw.wg.Done()
+		return nil, ctx.Err()
+	}
+	var resp *fetch.Response
 	select {
 	case err := <-errorChan:
 		tw.wg.Done()
-		return nil, err
-	case content = <-resultChan:
+		return nil, task.retryLater(ctx, tw, err)
+	case resp = <-resultChan:
+	case <-ctx.Done():
+		tw.wg.Done()
+		return nil, ctx.Err()
+	}
+	task.retrySucceeded(tw)
+	tw.artifact = resp.Artifact
+	task.mx.Lock()
+	task.Pages++
+	task.mx.Unlock()
+	if err := task.saveInfo(); err != nil {
+		logger.Error(err)
 	}
 
-	// Create a goquery document.
-	doc, err := goquery.NewDocumentFromReader(content)
+	//relative links of redirected pages are resolved against the final URL
+	baseURL := url
+	if resp.URL != "" {
+		baseURL = resp.URL
+	}
+	// Parse the page according to its document type.
+	page, err := tw.scraper.parseDocument(resp.Body)
+	resp.Body.Close()
 	if err != nil {
 		tw.wg.Done()
 		return nil, err
@@ -393,7 +828,7 @@ func (task *Task) scrape(tw *taskWorker) (*Results, error) {
 
 	if task.Payload.Paginator != nil {
 		if !task.Payload.Paginator.InfiniteScroll {
-			url, err = tw.scraper.Paginator.NextPage(url, doc.Selection)
+			url, err = tw.scraper.nextPage(baseURL, page, task.Payload.Paginator)
 			if err != nil {
 				tw.wg.Done()
 				return nil, err
@@ -422,7 +857,7 @@ func (task *Task) scrape(tw *taskWorker) (*Results, error) {
 					keys:           tw.keys,
 				}
 				tw.wg.Add(1)
-				go task.scrape(&paginatorTW)
+				go task.scrape(ctx, &paginatorTW)
 			}
 		}
 		//todo: test this case
@@ -435,26 +870,39 @@ func (task *Task) scrape(tw *taskWorker) (*Results, error) {
 	wrk := &worker{
 		wg:      &wg,
 		scraper: tw.scraper,
+		baseURL: baseURL,
 	}
 
-	blockSelections := tw.scraper.DividePage(doc.Selection)
-
 	for i := 0; i < 25; i++ {
 		wg.Add(1)
-		go task.blockWorker(blocks, wrk)
+		go task.blockWorker(ctx, blocks, wrk)
 	}
 
 	// Divide this page into blocks
-	for i, blockSel := range blockSelections {
-		ref := fmt.Sprintf("%s-%d-%d", tw.UID, tw.currentPageNum, i)
-		block := blockStruct{
-			blockSelection:  blockSel,
-			key:             ref,
-			hash:            tw.UID,
-			useBlockCounter: tw.useBlockCounter,
-			keys:            &tw.keys,
+	switch {
+	case tw.scraper.XHR != nil:
+		task.sendJSONBlocks(xhrDocuments(resp.XHR, tw.scraper.XHR), tw, blocks)
+	case page.isJSON:
+		task.sendJSONBlocks([]interface{}{page.value}, tw, blocks)
+	case tw.scraper.Table != nil:
+		task.sendTableRows(page.sel, tw, blocks)
+	default:
+		blockSels, path := []*goquery.Selection{page.sel}, ""
+		if tw.scraper.DividePage != nil {
+			blockSels, path = tw.scraper.DividePage(page.sel)
+		}
+		task.addBlockPath(path)
+		for i, blockSel := range blockSels {
+			ref := fmt.Sprintf("%s-%d-%d", tw.UID, tw.currentPageNum, i)
+			block := blockStruct{
+				blockSelection:  blockSel,
+				key:             ref,
+				hash:            tw.UID,
+				useBlockCounter: tw.useBlockCounter,
+				keys:            &tw.keys,
+			}
+			blocks <- &block
 		}
-		blocks <- &block
 	}
 	close(blocks)
 	wg.Wait()
@@ -477,24 +925,58 @@ func (p Payload) selectors() ([]string, error) {
 	return selectors, nil
 }
 
-//response sends request to fetch service and returns fetch.FetchResponser
-func fetchContent(req fetch.Request) (io.ReadCloser, error) {
+//fetchContent sends request to fetch service and returns fetched document along with response metadata
+func fetchContent(ctx context.Context, req fetch.Request) (*fetch.Response, error) {
 	svc, err := fetch.NewHTTPClient(viper.GetString("DFK_FETCH"))
 	if err != nil {
 		logger.Error(err)
 	}
-	return svc.Fetch(req)
+	return svc.Fetch(ctx, req)
 }
 
 //partNames returns Part Names which are used as a header of output CSV
 func (s Scraper) partNames() []string {
 	names := []string{}
+	//table records are keyed by column names. They are found in the results if columns are not mapped.
+	if s.Table != nil {
+		for _, part := range tableParts(s.Table.Extractor.(*extract.Table)) {
+			names = append(names, part.Name)
+		}
+		return names
+	}
 	for _, part := range s.Parts {
 		names = append(names, part.Name)
+		if part.Details.Capture != nil {
+			names = append(names, part.Name+"_capture")
+		}
 	}
 	return names
 }
 
+//nestedParts returns object, list and table parts by name. Sub-parts of tables are made of column mappings.
+func (s Scraper) nestedParts() map[string]Part {
+	nested := map[string]Part{}
+	for _, part := range s.Parts {
+		if t, ok := part.Extractor.(*extract.Table); ok {
+			part.Parts = tableParts(t)
+			part.List = true
+		}
+		if len(part.Parts) > 0 || part.List {
+			nested[part.Name] = part
+		}
+	}
+	return nested
+}
+
+// tableParts returns parts named after keys of mapped table columns.
+func tableParts(t *extract.Table) []Part {
+	parts := []Part{}
+	for _, c := range t.Columns {
+		parts = append(parts, Part{Name: c.Key()})
+	}
+	return parts
+}
+
 // First returns the first set of results - i.e. the results from the first
 // block on the first page.
 // This function can return nil if there were no blocks found on the first page
@@ -531,35 +1013,32 @@ func (task Task) startTime() (*time.Time, error) {
 	return &idTime, nil
 }
 
-func (task *Task) blockWorker(blocks chan *blockStruct, wrk *worker) {
+func (task *Task) blockWorker(ctx context.Context, blocks chan *blockStruct, wrk *worker) {
 	defer wrk.wg.Done()
-	url := wrk.scraper.Request.URL
+	url := wrk.baseURL
 	for block := range blocks {
+		if block.record != nil {
+			task.saveToStorage(&block.record, wrk, block)
+			continue
+		}
 		blockResults := map[string]interface{}{}
 
 		// Process each part of this block
 		for _, part := range wrk.scraper.Parts {
-			sel := block.blockSelection
-			if part.Selector != "." {
-				sel = sel.Find(part.Selector)
-			}
-			//update base URL to reflect attr relative URL change
-			/* switch part.Extractor.(type) {
-			case *extract.Attr: */
-			attr, ok := part.Extractor.(*extract.Attr)
-			if ok && (attr.Attr == "href" || attr.Attr == "src") {
-				task.mx.Lock()
-				attr.BaseURL = url
-				task.mx.Unlock()
+			var (
+				extractedPartResults interface{}
+				err                  error
+			)
+			if block.isJSON {
+				extractedPartResults, err = extractJSON(part, block.value, block.jsonPrefix, url)
+			} else {
+				extractedPartResults, err = task.extractPart(part, block.blockSelection, url, wrk.scraper.isHTML())
 			}
-			/* } */
-			task.mx.Lock()
-			extractedPartResults, err := part.Extractor.Extract(sel)
-			task.mx.Unlock()
 			if err != nil {
 				logger.Error(err)
 				return
 			}
+			extractedPartResults = extract.ApplyFilters(extractedPartResults, part.Filters)
 
 			// A nil response from an extractor means that we don't even include it in
 			// the results.
@@ -571,7 +1050,7 @@ func (task *Task) blockWorker(blocks chan *blockStruct, wrk *worker) {
 			}
 			//********* details
 			if len(part.Details.Parts) > 0 {
-				if !task.scrapeDetails(extractedPartResults, &part, wrk, block, &blockResults) {
+				if !task.scrapeDetails(ctx, extractedPartResults, &part, wrk, block, &blockResults) {
 					continue
 				}
 			}
@@ -583,7 +1062,130 @@ func (task *Task) blockWorker(blocks chan *blockStruct, wrk *worker) {
 	}
 }
 
-func (task *Task) scrapeDetails(extractedPartResults interface{}, part *Part, wrk *worker, block *blockStruct, blockResults *map[string]interface{}) bool {
+// extractPart extracts part from the block selection. Objects of object and list parts are made of sub-parts extracted from every element matching the part selector.
+// isHTML is false for blocks of XML documents.
+func (task *Task) extractPart(part Part, sel *goquery.Selection, url string, isHTML bool) (interface{}, error) {
+	if part.Selector != "." {
+		sel = xpath.Find(sel, part.Selector, isHTML)
+	}
+	if len(part.Parts) > 0 {
+		objects := []map[string]interface{}{}
+		for i := range sel.Nodes {
+			object := map[string]interface{}{}
+			for _, sub := range part.Parts {
+				v, err := task.extractPart(sub, sel.Eq(i), url, isHTML)
+				if err != nil {
+					return nil, err
+				}
+				if v = extract.ApplyFilters(v, sub.Filters); v != nil {
+					object[sub.Name] = v
+				}
+			}
+			if len(object) > 0 {
+				objects = append(objects, object)
+			}
+		}
+		return nestedValue(objects, part.List), nil
+	}
+	//update base URL to reflect attr relative URL change
+	attr, ok := part.Extractor.(*extract.Attr)
+	if ok && (attr.Attr == "href" || attr.Attr == "src") {
+		task.mx.Lock()
+		attr.BaseURL = url
+		task.mx.Unlock()
+	}
+	task.mx.Lock()
+	defer task.mx.Unlock()
+	return part.Extractor.Extract(sel)
+}
+
+// nestedValue returns objects of list part or the first object of object part. Nil is returned if there are no objects.
+func nestedValue(objects []map[string]interface{}, list bool) interface{} {
+	if len(objects) == 0 {
+		return nil
+	}
+	if !list {
+		return objects[0]
+	}
+	return objects
+}
+
+// sendTableRows sends rows of the tables as blocks.
+func (task *Task) sendTableRows(sel *goquery.Selection, tw *taskWorker, blocks chan<- *blockStruct) {
+	table := tw.scraper.Table
+	records, err := table.Extractor.Extract(xpath.Find(sel, table.Selector, tw.scraper.isHTML()))
+	if err != nil {
+		logger.Error(err)
+		return
+	}
+	rows, _ := records.([]map[string]interface{})
+	for i, record := range rows {
+		blocks <- &blockStruct{
+			record:          record,
+			key:             fmt.Sprintf("%s-%d-%d", tw.UID, tw.currentPageNum, i),
+			hash:            tw.UID,
+			useBlockCounter: tw.useBlockCounter,
+			keys:            &tw.keys,
+		}
+	}
+}
+
+// xhrDocuments returns decoded JSON bodies of XHR responses with URLs matching the pattern.
+func xhrDocuments(xhr []fetch.XHR, pattern *regexp.Regexp) []interface{} {
+	docs := []interface{}{}
+	for _, x := range xhr {
+		if !pattern.MatchString(x.URL) {
+			continue
+		}
+		var doc interface{}
+		if err := json.Unmarshal(x.Body, &doc); err != nil {
+			logger.Warningf("Failed to parse JSON of XHR %s. %s", x.URL, err.Error())
+			continue
+		}
+		docs = append(docs, doc)
+	}
+	return docs
+}
+
+// sendJSONBlocks splits JSON documents into blocks. Blocks of all the documents are numbered in order.
+func (task *Task) sendJSONBlocks(docs []interface{}, tw *taskWorker, blocks chan<- *blockStruct) {
+	selectors := []string{}
+	for _, part := range tw.scraper.Parts {
+		selectors = append(selectors, part.Selector)
+	}
+	i := 0
+	for _, doc := range docs {
+		var values []interface{}
+		prefix := 0
+		//fields are relative to blocks found by block selector
+		if bs := tw.scraper.BlockSelector; bs != "" {
+			values = jsonPath(doc, pathSegments(bs))
+			task.addBlockPath(bs)
+		} else {
+			values, prefix = divideJSON(doc, selectors)
+			for _, sel := range selectors {
+				if segments := pathSegments(sel); prefix > 0 && len(segments) >= prefix {
+					task.addBlockPath("$." + strings.Join(segments[:prefix], "."))
+					break
+				}
+			}
+		}
+		for _, v := range values {
+			blocks <- &blockStruct{
+				value:           v,
+				jsonPrefix:      prefix,
+				isJSON:          true,
+				key:             fmt.Sprintf("%s-%d-%d", tw.UID, tw.currentPageNum, i),
+				hash:            tw.UID,
+				useBlockCounter: tw.useBlockCounter,
+				keys:            &tw.keys,
+			}
+			i++
+		}
+	}
+}
+
+func (task *Task) scrapeDetails(ctx context.Context, extractedPartResults interface{}, part *Part, wrk *worker, block *blockStruct, blockResults *map[string]interface{}) bool {
 	var requests []fetch.Request
 
 	switch extractedPartResults.(type) {
@@ -608,7 +1210,7 @@ func (task *Task) scrapeDetails(extractedPartResults interface{}, part *Part, wr
 			uid = block.hash
 			ubc = true
 		} else {
-			uid = string(utils.GenerateCRC32([]byte(r.URL)))
+			uid = string(utils.GenerateCRC32([]byte(task.ID + r.URL)))
 		}
 		tw := taskWorker{
 			wg:              &wg,
@@ -617,17 +1219,31 @@ func (task *Task) scrapeDetails(extractedPartResults interface{}, part *Part, wr
 			UID:             uid,
 			useBlockCounter: ubc,
 			keys:            make(map[int][]int),
+			details:         true,
 		}
 		wg.Add(1)
 		tw.scraper.Request.Type = task.Payload.Request.Type
-		_, err := task.scrape(&tw)
-		if err != nil {
+		tw.scraper.Request.Headers = task.Payload.Request.Headers
+		tw.scraper.Request.UserAgent = task.Payload.Request.UserAgent
+		tw.scraper.Request.Proxy = task.Payload.Request.Proxy
+		tw.scraper.Request.ProxyGroup = task.Payload.Request.ProxyGroup
+		//screenshots are taken by Chrome fetcher only
+		if part.Details.Capture != nil {
+			tw.scraper.Request.Type = "chrome"
+			tw.scraper.Request.Capture = part.Details.Capture
+		}
+		_, err := task.scrape(ctx, &tw)
+		//details page which is fetched again later is linked to the block as usual
+		if _, ok := err.(*retryScheduled); err != nil && !ok {
 			logger.Error(err)
 			return false
 		}
 		if wrk.scraper.IsPath {
 			return false
 		}
+		if tw.artifact != "" {
+			(*blockResults)[part.Name+"_capture"] = tw.artifact
+		}
 		(*blockResults)[part.Name+"_details"] = uid //generate uid resDetails.AllBlocks()
 		// Sort keys to keep an order before write them into storage.
 		for k := range tw.keys {
@@ -691,28 +1307,98 @@ func (task *Task) saveToStorage(blockResults *map[string]interface{}, wrk *worke
 		})
 		if err != nil {
 			logger.Error(fmt.Errorf("Failed to write %s. %s", key, err.Error()))
+		} else {
+			task.Blocks++
 		}
 		task.mx.Unlock()
+		//details blocks are passed to sink nested into their top level blocks
+		if err == nil && task.sink != nil && block.hash == task.uid {
+			task.writeToSink(key, output)
+		}
 	}
 }
 
-func (task *Task) fetchWorker(fc chan *fetchInfo) {
-	for fetch := range fc {
-		if !viper.GetBool("IGNORE_FETCH_DELAY") {
-			if *task.Payload.RandomizeFetchDelay {
-				//Sleep for time equal to FetchDelay * random value between 500 and 1500 msec
-				rand := utils.Random(500, 1500)
-				delay := *task.Payload.FetchDelay * time.Duration(rand) / 1000
-				time.Sleep(delay)
-			} else {
-				time.Sleep(*task.Payload.FetchDelay)
-			}
+// sinkBlock is a block which is written to the sink later.
+type sinkBlock struct {
+	key   string
+	block map[string]interface{}
+}
+
+// writeToSink passes block with its details read from storage to the output sink.
+// Block is decoded from JSON so that sink receives the same values as encoders do.
+// Blocks with details wait while some fetches are re-queued, as their details may be fetched by retries. They are written by flushSink.
+func (task *Task) writeToSink(key string, output []byte) {
+	block := make(map[string]interface{})
+	err := json.Unmarshal(output, &block)
+	if err == nil {
+		task.mx.Lock()
+		wait := len(task.retries) > 0 && hasDetails(block)
+		if wait {
+			task.sinkPending = append(task.sinkPending, sinkBlock{key: key, block: block})
 		}
-		content, err := fetchContent(fetch.request)
-		if err != nil {
-			fetch.err <- err
-		} else {
-			fetch.result <- content
+		task.mx.Unlock()
+		if wait {
+			return
+		}
+		err = task.writeSinkBlock(key, block)
+	}
+	task.sinkFailed(err)
+}
+
+// flushSink writes blocks which waited for retries to the sink.
+func (task *Task) flushSink() {
+	task.mx.Lock()
+	pending := task.sinkPending
+	task.sinkPending = nil
+	task.mx.Unlock()
+	for _, b := range pending {
+		task.sinkFailed(task.writeSinkBlock(b.key, b.block))
+	}
+}
+
+func (task *Task) writeSinkBlock(key string, block map[string]interface{}) error {
+	readDetails(&task.storage, block)
+	return task.sink.Write(task.ID+"-"+key, block)
+}
+
+// hasDetails checks if block refers to details blocks.
+func hasDetails(block map[string]interface{}) bool {
+	for field := range block {
+		if strings.Contains(field, "details") {
+			return true
 		}
 	}
+	return false
+}
+
+// sinkFailed records sink error to task errors.
+func (task *Task) sinkFailed(err error) {
+	if err != nil {
+		logger.Error(err)
+		task.mx.Lock()
+		task.Errors = append(task.Errors, err)
+		task.mx.Unlock()
+	}
+}
+
+// hostDelay returns minimum interval between the request and the previous request to the same host.
+// FetchDelay is randomized if RandomizeFetchDelay is set. Crawl-delay from robots.txt of the host for the request User-Agent is used as a floor.
+func (task *Task) hostDelay(req fetch.Request) time.Duration {
+	if viper.GetBool("IGNORE_FETCH_DELAY") {
+		return 0
+	}
+	delay := *task.Payload.FetchDelay
+	if *task.Payload.RandomizeFetchDelay {
+		//Delay is equal to FetchDelay * random value between 500 and 1500 msec
+		rand := utils.Random(500, 1500)
+		delay = *task.Payload.FetchDelay * time.Duration(rand) / 1000
+	}
+	host, _ := req.Host()
+	task.mx.Lock()
+	robots := task.Robots[host]
+	task.mx.Unlock()
+	if crawlDelay := fetch.CrawlDelay(robots, req.UserAgent); delay < crawlDelay {
+		delay = crawlDelay
+	}
+	return delay
 }
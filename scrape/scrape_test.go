@@ -2,14 +2,23 @@ package scrape
 
 import (
 	"bytes"
+	"context"
 	"flag"
+	"io"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/PuerkitoBio/goquery"
+	"github.com/slotix/dataflowkit/errs"
+	"github.com/slotix/dataflowkit/extract"
 	"github.com/slotix/dataflowkit/fetch"
+	"github.com/slotix/dataflowkit/xpath"
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 )
@@ -244,12 +253,13 @@ func TestParseDetails(t *testing.T) {
 	fetchServerCfg := fetch.Config{
 		Host: fetchServerAddr,
 	}
-	fetchServer := fetch.Start(fetchServerCfg)
+	fetchServer, err := fetch.Start(fetchServerCfg)
+	assert.NoError(t, err)
 	defer fetchServer.Stop()
 
 	//JSON details output
 	task := NewTask(detailsPayload)
-	r, err := task.Parse()
+	r, err := task.Parse(context.Background())
 	assert.NoError(t, err)
 	buf := new(bytes.Buffer)
 	buf.ReadFrom(r)
@@ -268,7 +278,7 @@ func TestParseDetails(t *testing.T) {
 	//XML details output
 	// detailsPayload.Format = "xml"
 	// task = NewTask(detailsPayload)
-	// r, err = task.Parse()
+	// r, err = task.Parse(context.Background())
 	// assert.NoError(t, err)
 	// buf = new(bytes.Buffer)
 	// buf.ReadFrom(r)
@@ -287,7 +297,7 @@ func TestParseDetails(t *testing.T) {
 	//CSV details output
 	// detailsPayload.Format = "csv"
 	// task = NewTask(detailsPayload)
-	// r, err = task.Parse()
+	// r, err = task.Parse(context.Background())
 	// assert.NoError(t, err)
 	// buf = new(bytes.Buffer)
 	// buf.ReadFrom(r)
@@ -314,12 +324,13 @@ func TestParse(t *testing.T) {
 	fetchServerCfg := fetch.Config{
 		Host: fetchServerAddr,
 	}
-	fetchServer := fetch.Start(fetchServerCfg)
+	fetchServer, err := fetch.Start(fetchServerCfg)
+	assert.NoError(t, err)
 	defer fetchServer.Stop()
 
 	//JSON output
 	task := NewTask(personsPayload)
-	r, err := task.Parse()
+	r, err := task.Parse(context.Background())
 	assert.NoError(t, err)
 	buf := new(bytes.Buffer)
 	buf.ReadFrom(r)
@@ -338,7 +349,7 @@ func TestParse(t *testing.T) {
 	//CSV
 	// personsPayload.Format = "csv"
 	// task = NewTask(personsPayload)
-	// r, err = task.Parse()
+	// r, err = task.Parse(context.Background())
 	// assert.NoError(t, err)
 	// buf = new(bytes.Buffer)
 	// buf.ReadFrom(r)
@@ -356,7 +367,7 @@ func TestParse(t *testing.T) {
 	//xml
 	personsPayload.Format = "xml"
 	task = NewTask(personsPayload)
-	r, err = task.Parse()
+	r, err = task.Parse(context.Background())
 	assert.NoError(t, err)
 	buf = new(bytes.Buffer)
 	buf.ReadFrom(r)
@@ -382,7 +393,8 @@ func TestParseErrs(t *testing.T) {
 	fetchServerCfg := fetch.Config{
 		Host: fetchServerAddr,
 	}
-	fetchServer := fetch.Start(fetchServerCfg)
+	fetchServer, err := fetch.Start(fetchServerCfg)
+	assert.NoError(t, err)
 	defer fetchServer.Stop()
 
 	///// No selectors
@@ -395,7 +407,7 @@ func TestParseErrs(t *testing.T) {
 	}
 
 	task := NewTask(badP)
-	_, err := task.Parse()
+	_, err = task.Parse(context.Background())
 	assert.Error(t, err, "400: no parts found")
 
 	///// ErrNoPartOrSelectorProvided
@@ -424,7 +436,7 @@ func TestParseErrs(t *testing.T) {
 	}
 
 	task = NewTask(badP)
-	_, err = task.Parse()
+	_, err = task.Parse(context.Background())
 	assert.Error(t, err, "errs.ErrNoPartOrSelectorProvided")
 
 	//Bad output format
@@ -448,13 +460,26 @@ func TestParseErrs(t *testing.T) {
 	}
 	task = NewTask(badOF)
 
-	_, err = task.Parse()
+	_, err = task.Parse(context.Background())
 	assert.Error(t, err, "invalid output format specified")
 
 	os.RemoveAll("./diskv")
 	os.RemoveAll("./results")
 }
 
+func TestParseCanceled(t *testing.T) {
+	os.RemoveAll("./diskv")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	task := NewTask(CSVPayload)
+	_, err := task.Parse(ctx)
+	assert.IsType(t, &errs.Canceled{}, err)
+	info, err := LoadTaskInfo(task.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, TaskFailed, info.Status)
+	os.RemoveAll("./diskv")
+}
+
 func TestCSVEncode(t *testing.T) {
 	os.RemoveAll("./diskv")
 	os.RemoveAll("./results")
@@ -462,11 +487,12 @@ func TestCSVEncode(t *testing.T) {
 	fetchServerCfg := fetch.Config{
 		Host: fetchServerAddr,
 	}
-	fetchServer := fetch.Start(fetchServerCfg)
+	fetchServer, err := fetch.Start(fetchServerCfg)
+	assert.NoError(t, err)
 	defer fetchServer.Stop()
 
 	task := NewTask(CSVPayload)
-	r, err := task.Parse()
+	r, err := task.Parse(context.Background())
 	assert.NoError(t, err)
 	buf := new(bytes.Buffer)
 	buf.ReadFrom(r)
@@ -492,11 +518,12 @@ func TestXMLEncode(t *testing.T) {
 	fetchServerCfg := fetch.Config{
 		Host: fetchServerAddr,
 	}
-	fetchServer := fetch.Start(fetchServerCfg)
+	fetchServer, err := fetch.Start(fetchServerCfg)
+	assert.NoError(t, err)
 	defer fetchServer.Stop()
 
 	task := NewTask(XMLPayload)
-	r, err := task.Parse()
+	r, err := task.Parse(context.Background())
 	assert.NoError(t, err)
 	buf := new(bytes.Buffer)
 	buf.ReadFrom(r)
@@ -525,7 +552,8 @@ func TestParseSwitchFetchers(t *testing.T) {
 		Host: fetchServerAddr,
 	}
 	// viper.Set("SKIP_STORAGE_MW", true)
-	fetchServer := fetch.Start(fetchServerCfg)
+	fetchServer, err := fetch.Start(fetchServerCfg)
+	assert.NoError(t, err)
 	defer fetchServer.Stop()
 	p := Payload{
 		Name: "persons Table",
@@ -545,7 +573,7 @@ func TestParseSwitchFetchers(t *testing.T) {
 		Format: "json",
 	}
 	task := NewTask(p)
-	r, err := task.Parse()
+	r, err := task.Parse(context.Background())
 	assert.NoError(t, err)
 	assert.NotNil(t, r)
 	os.RemoveAll("./diskv")
@@ -563,6 +591,10 @@ func TestScraper_partNames(t *testing.T) {
 	parts := s.partNames()
 	assert.Equal(t, []string{"1", "2", "3", "4"}, parts)
 
+	//reference to the screenshot of details page follows the link
+	s.Parts[1].Details.Capture = &fetch.Capture{}
+	assert.Equal(t, []string{"1", "2", "2_capture", "3", "4"}, s.partNames())
+
 }
 
 func TestPayload_selectors(t *testing.T) {
@@ -592,6 +624,337 @@ func TestPayload_selectors(t *testing.T) {
 
 }
 
+func TestPayload_XPath(t *testing.T) {
+	p := Payload{
+		Name:    "items",
+		Request: fetch.Request{URL: "http://example.com"},
+		Fields: []Field{
+			{Name: "Name", Selector: "xpath://div[@class='item']/h2", Extractor: Extractor{Types: []string{"text"}}},
+			{Name: "Price", Selector: "xpath://span[text()='Price:']/following-sibling::span[1]", Extractor: Extractor{Types: []string{"text"}}},
+		},
+		Paginator: &paginator{Selector: "xpath://a[@rel='next']", Attribute: "href"},
+	}
+	s, err := p.newScraper()
+	assert.NoError(t, err)
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(`<html><body>
+		<div class="item"><h2>Book</h2><span>Price:</span><span>12</span></div>
+		<div class="item"><h2>Pen</h2><span>Price:</span><span>2</span></div>
+		<a rel="next" href="?page=2">Next</a></body></html>`))
+	assert.NoError(t, err)
+	blocks, _ := s.DividePage(doc.Selection)
+	assert.Len(t, blocks, 2)
+	assert.Equal(t, "2", xpath.Find(blocks[1], p.Fields[1].Selector, true).Text())
+	next, err := s.Paginator.NextPage(p.Request.URL, doc.Selection)
+	assert.NoError(t, err)
+	assert.Equal(t, "http://example.com?page=2", next)
+
+	p.Paginator.Selector = "xpath://a[@rel='next'"
+	_, err = p.newScraper()
+	assert.IsType(t, &errs.BadPayload{}, err)
+	p.Paginator = nil
+	p.Fields[0].Selector = "xpath://div[@class='item']/h2["
+	_, err = p.newScraper()
+	assert.IsType(t, &errs.BadPayload{}, err)
+	p.Fields[0].Selector = "div.item >> h2"
+	_, err = p.newScraper()
+	assert.IsType(t, &errs.BadPayload{}, err)
+}
+
+func TestPayload_BlockSelector(t *testing.T) {
+	p := Payload{
+		Name:    "products",
+		Request: fetch.Request{URL: "http://example.com"},
+		Fields: []Field{
+			{Name: "Name", Selector: "h2", Extractor: Extractor{Types: []string{"text"}}},
+			{Name: "Price", Selector: ".price", Extractor: Extractor{Types: []string{"text"}}},
+		},
+	}
+	//the first product has no price, so the inferred path matches the list of products as well
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(`<html><body><div class="list">
+		<div class="product"><h2>Book</h2></div>
+		<div class="product"><h2>Pen</h2><span class="price">2</span></div>
+	</div></body></html>`))
+	assert.NoError(t, err)
+	s, err := p.newScraper()
+	assert.NoError(t, err)
+	blocks, path := s.DividePage(doc.Selection)
+	assert.Equal(t, "div", path)
+	assert.Len(t, blocks, 3)
+
+	for _, selector := range []string{".product", "xpath://div[@class='product']"} {
+		p.BlockSelector = selector
+		s, err = p.newScraper()
+		assert.NoError(t, err)
+		blocks, path = s.DividePage(doc.Selection)
+		assert.Equal(t, selector, path)
+		assert.Len(t, blocks, 2)
+		assert.Equal(t, "Pen", blocks[1].Find(p.Fields[0].Selector).Text())
+		assert.Equal(t, 0, blocks[0].Find(p.Fields[1].Selector).Length())
+	}
+
+	p.BlockSelector = "xpath://div["
+	_, err = p.newScraper()
+	assert.IsType(t, &errs.BadPayload{}, err)
+	p.BlockSelector = "$..products"
+	p.DocumentType = JSONDocument
+	_, err = p.newScraper()
+	assert.IsType(t, &errs.BadPayload{}, err)
+	p.BlockSelector = "$.products[*]"
+	_, err = p.newScraper()
+	assert.NoError(t, err)
+	p.DocumentType = ""
+	p.Fields = nil
+	p.Table = &table{Selector: "table"}
+	_, err = p.newScraper()
+	assert.IsType(t, &errs.BadPayload{}, err)
+}
+
+func TestPayload_ColumnNames(t *testing.T) {
+	p := Payload{
+		Name:    "products",
+		Request: fetch.Request{URL: "http://example.com"},
+		Fields: []Field{
+			{Name: "a-b", Selector: "h2", Extractor: Extractor{Types: []string{"text"}}},
+			{Name: "a b", Selector: "h3", Extractor: Extractor{Types: []string{"text"}}},
+		},
+	}
+	//column names matter for columnar formats and sinks only
+	_, err := p.newScraper()
+	assert.NoError(t, err)
+	for _, format := range []string{"parquet", "avro"} {
+		p.Format = format
+		_, err = p.newScraper()
+		assert.IsType(t, &errs.BadPayload{}, err)
+	}
+	p.Fields[1].Name = "a_c"
+	_, err = p.newScraper()
+	assert.NoError(t, err)
+}
+
+func TestPayload_TypedExtractors(t *testing.T) {
+	p := Payload{
+		Name:    "items",
+		Request: fetch.Request{URL: "http://example.com"},
+		Fields: []Field{
+			{Name: "Price", Selector: ".price", Extractor: Extractor{
+				Types:  []string{"number", "price"},
+				Params: map[string]interface{}{"locale": "de", "currency": "EUR"},
+			}},
+			{Name: "Date", Selector: "time", Extractor: Extractor{
+				Types:  []string{"date"},
+				Params: map[string]interface{}{"layouts": []interface{}{"02.01.2006"}, "timezone": "Europe/Berlin"},
+			}},
+			{Name: "Stock", Selector: ".stock", Extractor: Extractor{
+				Types:  []string{"boolean"},
+				Params: map[string]interface{}{"true": "(?i)auf lager"},
+			}},
+		},
+	}
+	s, err := p.newScraper()
+	assert.NoError(t, err)
+	sel, err := goquery.NewDocumentFromReader(strings.NewReader(
+		`<div><span class="price">1.299,50 €</span><time>05.03.2018</time><span class="stock">Auf Lager</span></div>`))
+	assert.NoError(t, err)
+	results := map[string]interface{}{}
+	for _, part := range s.Parts {
+		results[part.Name], err = part.Extractor.Extract(sel.Find(part.Selector))
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, map[string]interface{}{
+		"Price_number":  1299.5,
+		"Price_price":   extract.PriceValue{Amount: 1299.5, Currency: "EUR"},
+		"Date_date":     "2018-03-05T00:00:00+01:00",
+		"Stock_boolean": true,
+	}, results)
+
+	for _, params := range []map[string]interface{}{
+		{"timezone": "Mars/Olympus"},
+		{"layouts": "02.01.2006"},
+		{"layout": 1},
+	} {
+		p.Fields[1].Extractor.Params = params
+		_, err = p.newScraper()
+		assert.IsType(t, &errs.BadPayload{}, err)
+	}
+	p.Fields[1].Extractor.Params = nil
+	p.Fields[2].Extractor.Params = map[string]interface{}{"true": "(yes"}
+	_, err = p.newScraper()
+	assert.IsType(t, &errs.BadPayload{}, err)
+}
+
+func TestPayload_Filters(t *testing.T) {
+	p := Payload{
+		Name:    "items",
+		Request: fetch.Request{URL: "http://example.com"},
+		Fields: []Field{
+			{Name: "Tags", Selector: ".tags", Extractor: Extractor{
+				Types:   []string{"text", "count"},
+				Filters: []string{"split(',')", "trim", "uppercase"},
+			}},
+		},
+	}
+	s, err := p.newScraper()
+	assert.NoError(t, err)
+	assert.Len(t, s.Parts, 2)
+	assert.Len(t, s.Parts[1].Filters, 3)
+	sel, err := goquery.NewDocumentFromReader(strings.NewReader(`<p class="tags">red, green</p>`))
+	assert.NoError(t, err)
+	for part, expected := range map[int]interface{}{0: []string{"RED", "GREEN"}, 1: 1} {
+		v, err := s.Parts[part].Extractor.Extract(sel.Find(s.Parts[part].Selector))
+		assert.NoError(t, err)
+		assert.Equal(t, expected, extract.ApplyFilters(v, s.Parts[part].Filters))
+	}
+
+	for _, filter := range []string{"reverse", "replace('(')", "truncate(x)"} {
+		p.Fields[0].Extractor.Filters = []string{filter}
+		_, err = p.newScraper()
+		assert.IsType(t, &errs.BadPayload{}, err, filter)
+	}
+}
+
+func TestPayload_Nested(t *testing.T) {
+	p := Payload{
+		Name:    "products",
+		Request: fetch.Request{URL: "http://example.com"},
+		Fields: []Field{
+			{Name: "Name", Selector: "h2", Extractor: Extractor{Types: []string{"text"}}},
+			{Name: "Variants", Selector: "tr.variant", Extractor: Extractor{Types: []string{"list"}}, Fields: []Field{
+				{Name: "Size", Selector: "td.size", Extractor: Extractor{Types: []string{"text"}, Filters: []string{"uppercase"}}},
+				{Name: "Price", Selector: "xpath:td[2]", Extractor: Extractor{Types: []string{"number"}}},
+				{Name: "Link", Selector: "a", Extractor: Extractor{Types: []string{"href"}}},
+			}},
+			{Name: "Rating", Selector: ".rating", Extractor: Extractor{Types: []string{"object"}}, Fields: []Field{
+				{Name: "Value", Selector: ".", Extractor: Extractor{Types: []string{"number"}}},
+			}},
+		},
+	}
+	s, err := p.newScraper()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Name_text", "Variants_list", "Rating_object"}, s.partNames())
+	assert.Len(t, s.Parts[1].Parts, 3)
+	assert.True(t, s.Parts[1].List)
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(`<div><h2>Shirt</h2><table>
+		<tr class="variant"><td class="size">s</td><td>10</td><td><a href="/s">buy</a></td></tr>
+		<tr class="variant"><td class="size">m</td><td>12.50</td></tr>
+		<tr class="variant"><td></td></tr>
+	</table><span class="rating">4.5</span><span class="rating">3</span></div>`))
+	assert.NoError(t, err)
+	task := NewTask(p)
+	v, err := task.extractPart(s.Parts[1], doc.Selection, "http://example.com/shop/", true)
+	assert.NoError(t, err)
+	assert.Equal(t, []map[string]interface{}{
+		{"Size_text": "S", "Price_number": 10.0, "Link_href": "http://example.com/s"},
+		{"Size_text": "M", "Price_number": 12.5},
+	}, v)
+	v, err = task.extractPart(s.Parts[2], doc.Selection, "http://example.com/shop/", true)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"Value_number": 4.5}, v)
+	v, err = task.extractPart(s.Parts[2], doc.Find("h2"), "http://example.com/shop/", true)
+	assert.NoError(t, err)
+	assert.Nil(t, v)
+
+	cols := s.columns()
+	assert.Equal(t, columnRecord, cols[1].typ)
+	assert.Len(t, cols[1].columns, 3)
+
+	p.Fields[1].Fields[1].Selector = "xpath:td["
+	_, err = p.newScraper()
+	assert.IsType(t, &errs.BadPayload{}, err)
+	p.Fields[1].Fields[1].Details = &details{}
+	_, err = p.newScraper()
+	assert.IsType(t, &errs.BadPayload{}, err)
+	p.Fields[1].Fields = nil
+	_, err = p.newScraper()
+	assert.IsType(t, &errs.BadPayload{}, err)
+}
+
+func TestPayload_Table(t *testing.T) {
+	p := Payload{
+		Name:    "persons",
+		Request: fetch.Request{URL: "http://example.com/persons-table"},
+		Table: &table{
+			Selector: "table.persons",
+			Columns:  []extract.TableColumn{{Header: "Name", Name: "name"}, {Index: 1, Name: "phone"}},
+		},
+	}
+	s, err := p.newScraper()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"name", "phone"}, s.partNames())
+	assert.Len(t, s.columns(), 2)
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(`<table class="persons">
+		<thead><th>Name</th><th>Phone</th><th>Email</th></thead>
+		<tbody><tr><td>Alexa</td><td>808-9109</td><td>sed@example.net</td></tr><tr><td>Hu</td><td>503-5744</td></tr></tbody>
+	</table>`))
+	assert.NoError(t, err)
+	tw := &taskWorker{UID: "table", scraper: s, keys: map[int][]int{}}
+	blocks := make(chan *blockStruct)
+	go func() {
+		NewTask(p).sendTableRows(doc.Selection, tw, blocks)
+		close(blocks)
+	}()
+	records := []map[string]interface{}{}
+	for b := range blocks {
+		records = append(records, b.record)
+	}
+	assert.Equal(t, []map[string]interface{}{{"name": "Alexa", "phone": "808-9109"}, {"name": "Hu", "phone": "503-5744"}}, records)
+
+	//table field
+	p.Table = nil
+	p.Fields = []Field{{Name: "Persons", Selector: "table", Extractor: Extractor{
+		Types:  []string{"table"},
+		Params: map[string]interface{}{"columns": []interface{}{map[string]interface{}{"header": "Email"}}},
+	}}}
+	s, err = p.newScraper()
+	assert.NoError(t, err)
+	v, err := s.Parts[0].Extractor.Extract(doc.Find(s.Parts[0].Selector))
+	assert.NoError(t, err)
+	assert.Equal(t, []map[string]interface{}{{"Email": "sed@example.net"}}, v)
+	assert.Equal(t, []Part{{Name: "Email"}}, s.nestedParts()["Persons_table"].Parts)
+
+	p.Fields[0].Extractor.Params = map[string]interface{}{"columns": "Email"}
+	_, err = p.newScraper()
+	assert.IsType(t, &errs.BadPayload{}, err)
+	p.Fields[0].Extractor.Params = nil
+	p.DocumentType = "json"
+	_, err = p.newScraper()
+	assert.IsType(t, &errs.BadPayload{}, err)
+
+	p.DocumentType = ""
+	p.Table = &table{Selector: "table"}
+	_, err = p.newScraper()
+	assert.IsType(t, &errs.BadPayload{}, err, "fields are not used with table")
+	p.Fields = nil
+	p.Format = "parquet"
+	_, err = p.newScraper()
+	assert.IsType(t, &errs.BadPayload{}, err, "columns are required for parquet")
+	p.Format = "csv"
+	p.Table.Selector = "xpath://table["
+	_, err = p.newScraper()
+	assert.IsType(t, &errs.BadPayload{}, err)
+}
+
+func TestFormatValue(t *testing.T) {
+	block := map[string]interface{}{
+		"Price":  map[string]interface{}{"amount": 1299.5, "currency": "EUR"},
+		"Prices": []interface{}{map[string]interface{}{"amount": 2.0, "currency": "USD"}, map[string]interface{}{"amount": 3.0}},
+		"Stock":  []interface{}{true, false},
+		"Count":  1e6,
+	}
+	csv := CSVEncoder{}
+	assert.Equal(t, "1299.5 EUR,", csv.formatFieldValue(&block, "Price"))
+	assert.Equal(t, "2 USD;3,", csv.formatFieldValue(&block, "Prices"))
+	assert.Equal(t, "true;false,", csv.formatFieldValue(&block, "Stock"))
+	assert.Equal(t, "1000000,", csv.formatFieldValue(&block, "Count"))
+
+	var buf bytes.Buffer
+	XMLEncoder{}.writeXML(&buf, &map[string]interface{}{"Price": block["Price"]})
+	assert.Contains(t, buf.String(), "<amount>1299.5</amount>")
+	assert.Contains(t, buf.String(), "<currency>EUR</currency>")
+}
+
 func TestIntArrayToString(t *testing.T) {
 	str := intArrayToString([]int{1, 2, 3, 4, 5}, ";")
 	assert.Equal(t, "1;2;3;4;5", str)
@@ -601,3 +964,38 @@ func TestFloatArrayToString(t *testing.T) {
 	str := floatArrayToString([]float64{1.1, 2.2, 3.3, 4.4, 5.5}, ";")
 	assert.Equal(t, "1.1;2.2;3.3;4.4;5.5", str)
 }
+
+func TestFinalURL(t *testing.T) {
+	os.RemoveAll("./diskv")
+	defer os.RemoveAll("./diskv")
+	dfkFetch := viper.GetString("DFK_FETCH")
+	defer viper.Set("DFK_FETCH", dfkFetch)
+	viper.Set("IGNORE_FETCH_DELAY", true)
+	defer viper.Set("IGNORE_FETCH_DELAY", false)
+
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	mux.HandleFunc("/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "User-agent: *\nAllow: /")
+	})
+	//fetch service reports the page is redirected to another directory
+	mux.HandleFunc("/fetch", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(fetch.FinalURLHeader, srv.URL+"/moved/page")
+		io.WriteString(w, `<html><body><a href="item">Item</a></body></html>`)
+	})
+	viper.Set("DFK_FETCH", strings.TrimPrefix(srv.URL, "http://"))
+
+	task := NewTask(Payload{
+		Name:    "redirect",
+		Request: fetch.Request{URL: srv.URL + "/page", Type: "chrome"},
+		Fields: []Field{
+			{Name: "Link", Selector: "a", Extractor: Extractor{Types: []string{"href"}}},
+		},
+		Format: "json",
+	})
+	buf := &strings.Builder{}
+	err := task.ParseTo(context.Background(), buf)
+	assert.NoError(t, err)
+	assert.Equal(t, `[{"Link_href":"`+srv.URL+`/moved/item"}]`, buf.String())
+}
@@ -0,0 +1,250 @@
+package scrape
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	//database drivers used by sqlSink. Pure Go SQLite driver keeps parse.d buildable with CGO_ENABLED=0
+	_ "github.com/lib/pq"
+	"github.com/spf13/viper"
+	_ "modernc.org/sqlite"
+)
+
+// sinkDSN returns type and data source name of the sink configured in SINKS of parse.d.
+// Every sink is specified as "name=type:dsn", f.e. "results=sqlite:/data/results.db". Payloads refer to sinks by name only.
+func sinkDSN(name string) (string, string, error) {
+	for _, s := range viper.GetStringSlice("SINKS") {
+		i := strings.Index(s, "=")
+		if i < 0 || strings.TrimSpace(s[:i]) != name {
+			continue
+		}
+		def := strings.TrimSpace(s[i+1:])
+		j := strings.Index(def, ":")
+		if j < 0 {
+			return "", "", fmt.Errorf("Invalid output sink %s. type:dsn expected", name)
+		}
+		return def[:j], def[j+1:], nil
+	}
+	return "", "", fmt.Errorf("Unknown output sink %s", name)
+}
+
+// newSink creates output sink specified in payload.
+func newSink(cfg SinkConfig, collection string) (Sink, error) {
+	typ, dsn, err := sinkDSN(cfg.Name)
+	if err != nil {
+		return nil, err
+	}
+	table := cfg.Table
+	if table == "" {
+		table = collection
+	}
+	if table == "" {
+		table = "blocks"
+	}
+	switch strings.ToLower(typ) {
+	case "sqlite", "sqlite3":
+		return &sqlSink{driver: "sqlite", dsn: dsn, table: fieldName(table)}, nil
+	case "postgres", "postgresql":
+		return &sqlSink{driver: "postgres", dsn: dsn, table: fieldName(table)}, nil
+	default:
+		return nil, fmt.Errorf("invalid output sink type %s", typ)
+	}
+}
+
+// sqlSink writes every block as a row of the table named after collection. Columns of the table are made of part names.
+// Details are written to child tables which refer to the parent row by "_parent" foreign key.
+type sqlSink struct {
+	driver  string
+	dsn     string
+	table   string
+	columns []column
+	db      *sql.DB
+}
+
+// Open connects to database and creates tables if they don't exist.
+func (s *sqlSink) Open(scraper Scraper) error {
+	db, err := sql.Open(s.driver, s.dsn)
+	if err != nil {
+		return err
+	}
+	if s.driver == "sqlite" {
+		//SQLite allows only one writer at a time. Foreign keys are checked per connection.
+		db.SetMaxOpenConns(1)
+		if _, err := db.Exec("PRAGMA foreign_keys = ON"); err != nil {
+			db.Close()
+			return err
+		}
+	}
+	s.db = db
+	s.columns = scraper.columns()
+	err = s.createTable(s.table, "", s.columns)
+	if err != nil {
+		db.Close()
+		return fmt.Errorf("Failed to create table %s. %s", s.table, err.Error())
+	}
+	return nil
+}
+
+// sqlColumn is a column of the sink table.
+type sqlColumn struct {
+	name string
+	typ  string
+	//constraints are applied to columns of the created table only
+	constraints string
+}
+
+// createTable creates the table if it doesn't exist. Columns missing in the existing table are added.
+// Schema mismatch error is returned if the type of the existing column is different, so the task fails before anything is written.
+func (s *sqlSink) createTable(table string, parent string, cols []column) error {
+	defs := []sqlColumn{{name: "_id", typ: "TEXT", constraints: "PRIMARY KEY"}}
+	if parent != "" {
+		defs = append(defs, sqlColumn{name: "_parent", typ: "TEXT", constraints: fmt.Sprintf(`NOT NULL REFERENCES "%s" ("_id")`, parent)})
+	}
+	for _, col := range cols {
+		switch {
+		case col.typ == columnRecord:
+			continue
+		case col.repeated:
+			//repeated values are stored as JSON arrays
+			defs = append(defs, sqlColumn{name: col.field, typ: "TEXT"})
+		case col.typ == columnInt:
+			defs = append(defs, sqlColumn{name: col.field, typ: "BIGINT"})
+		case col.typ == columnFloat:
+			defs = append(defs, sqlColumn{name: col.field, typ: "DOUBLE PRECISION"})
+		default:
+			defs = append(defs, sqlColumn{name: col.field, typ: "TEXT"})
+		}
+	}
+	columns := []string{}
+	for _, def := range defs {
+		columns = append(columns, strings.TrimSpace(fmt.Sprintf(`"%s" %s %s`, def.name, def.typ, def.constraints)))
+	}
+	_, err := s.db.Exec(fmt.Sprintf(`CREATE TABLE IF NOT EXISTS "%s" (%s)`, table, strings.Join(columns, ", ")))
+	if err != nil {
+		return err
+	}
+	existing, err := s.tableColumns(table)
+	if err != nil {
+		return err
+	}
+	for _, def := range defs {
+		typ, ok := existing[def.name]
+		if !ok && def.constraints == "" {
+			_, err = s.db.Exec(fmt.Sprintf(`ALTER TABLE "%s" ADD COLUMN "%s" %s`, table, def.name, def.typ))
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if !ok || !strings.EqualFold(typ, def.typ) {
+			return fmt.Errorf("Schema mismatch. Column %s of existing table %s is %q, but %s is expected", def.name, table, typ, def.typ)
+		}
+	}
+	for _, col := range cols {
+		if col.typ == columnRecord {
+			err = s.createTable(table+"_"+col.field, table, col.columns)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// tableColumns returns types of columns of the existing table.
+func (s *sqlSink) tableColumns(table string) (map[string]string, error) {
+	query := `SELECT name, type FROM pragma_table_info(?)`
+	if s.driver == "postgres" {
+		query = `SELECT column_name, data_type FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = $1`
+	}
+	rows, err := s.db.Query(query, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	columns := make(map[string]string)
+	for rows.Next() {
+		var name, typ string
+		if err := rows.Scan(&name, &typ); err != nil {
+			return nil, err
+		}
+		columns[name] = typ
+	}
+	return columns, rows.Err()
+}
+
+// Write inserts block along with its details in a single transaction.
+func (s *sqlSink) Write(id string, block map[string]interface{}) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	err = s.insert(tx, s.table, "", id, s.columns, record(block, s.columns))
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("Failed to write %s to %s. %s", id, s.table, err.Error())
+	}
+	return tx.Commit()
+}
+
+func (s *sqlSink) insert(tx *sql.Tx, table string, parentID string, id string, cols []column, rec map[string]interface{}) error {
+	names := []string{`"_id"`}
+	values := []interface{}{id}
+	if parentID != "" {
+		names = append(names, `"_parent"`)
+		values = append(values, parentID)
+	}
+	for _, col := range cols {
+		if col.typ == columnRecord {
+			continue
+		}
+		value := rec[col.field]
+		if col.repeated {
+			j, err := json.Marshal(value)
+			if err != nil {
+				return err
+			}
+			value = string(j)
+		}
+		names = append(names, fmt.Sprintf(`"%s"`, col.field))
+		values = append(values, value)
+	}
+	placeholders := make([]string, len(values))
+	for i := range values {
+		placeholders[i] = s.placeholder(i + 1)
+	}
+	_, err := tx.Exec(fmt.Sprintf(`INSERT INTO "%s" (%s) VALUES (%s)`, table, strings.Join(names, ", "), strings.Join(placeholders, ", ")), values...)
+	if err != nil {
+		return err
+	}
+	for _, col := range cols {
+		if col.typ != columnRecord {
+			continue
+		}
+		for i, child := range rec[col.field].([]map[string]interface{}) {
+			err = s.insert(tx, table+"_"+col.field, id, fmt.Sprintf("%s-%d", id, i), col.columns, child)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// placeholder returns query parameter placeholder for the driver.
+func (s *sqlSink) placeholder(n int) string {
+	if s.driver == "postgres" {
+		return fmt.Sprintf("$%d", n)
+	}
+	return "?"
+}
+
+// Close closes database connection.
+func (s *sqlSink) Close() error {
+	if s.db == nil {
+		return nil
+	}
+	return s.db.Close()
+}
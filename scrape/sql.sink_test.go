@@ -0,0 +1,104 @@
+package scrape
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/slotix/dataflowkit/errs"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSQLSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sink")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	dsn := filepath.Join(dir, "results.db")
+
+	viper.Set("SINKS", []string{"results=sqlite:" + dsn, "other=mysql:user@/db", "invalid"})
+	defer viper.Set("SINKS", nil)
+	_, err = newSink(SinkConfig{Name: "other"}, "test")
+	assert.Error(t, err)
+	_, err = newSink(SinkConfig{Name: "unknown"}, "test")
+	assert.Error(t, err)
+	err = NewTask(Payload{Name: "test", Sink: &SinkConfig{Name: "invalid"}}).Validate()
+	assert.IsType(t, &errs.BadPayload{}, err)
+
+	sink, err := newSink(SinkConfig{Name: "results"}, "test collection")
+	assert.NoError(t, err)
+	err = sink.Open(columnarScraper)
+	assert.NoError(t, err)
+	err = sink.Write("task-1-0-0", map[string]interface{}{
+		"Name_text":         "Alice",
+		"Phones_count":      float64(2),
+		"Link_href":         "/alice",
+		"Link_href_details": map[string]interface{}{"Description_outerHtml": "<p>Alice</p>"},
+	})
+	assert.NoError(t, err)
+	err = sink.Write("task-1-0-1", map[string]interface{}{
+		"Name_text": []interface{}{"Bob", "Robert"},
+	})
+	assert.NoError(t, err)
+	//the same block may not be written twice
+	err = sink.Write("task-1-0-1", map[string]interface{}{})
+	assert.Error(t, err)
+	assert.NoError(t, sink.Close())
+
+	db, err := sql.Open("sqlite", dsn)
+	assert.NoError(t, err)
+	defer db.Close()
+	var name string
+	var phones sql.NullInt64
+	err = db.QueryRow(`SELECT "Name_text", "Phones_count" FROM "test_collection" WHERE "_id" = ?`, "task-1-0-0").Scan(&name, &phones)
+	assert.NoError(t, err)
+	assert.Equal(t, `["Alice"]`, name)
+	assert.Equal(t, int64(2), phones.Int64)
+	err = db.QueryRow(`SELECT "Name_text", "Phones_count" FROM "test_collection" WHERE "_id" = ?`, "task-1-0-1").Scan(&name, &phones)
+	assert.NoError(t, err)
+	assert.Equal(t, `["Bob","Robert"]`, name)
+	assert.False(t, phones.Valid)
+
+	var parent, description string
+	err = db.QueryRow(`SELECT "_parent", "Description_outerHtml" FROM "test_collection_Link_href_details"`).Scan(&parent, &description)
+	assert.NoError(t, err)
+	assert.Equal(t, "task-1-0-0", parent)
+	assert.Equal(t, "<p>Alice</p>", description)
+}
+
+func TestSQLSink_ExistingTable(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sink")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	dsn := filepath.Join(dir, "results.db")
+	db, err := sql.Open("sqlite", dsn)
+	assert.NoError(t, err)
+	defer db.Close()
+	_, err = db.Exec(`CREATE TABLE "test" ("_id" TEXT PRIMARY KEY, "Name_text" TEXT)`)
+	assert.NoError(t, err)
+
+	viper.Set("SINKS", []string{"results=sqlite:" + dsn})
+	defer viper.Set("SINKS", nil)
+	//missing columns are added to the existing table
+	sink, err := newSink(SinkConfig{Name: "results"}, "test")
+	assert.NoError(t, err)
+	assert.NoError(t, sink.Open(columnarScraper))
+	assert.NoError(t, sink.Write("task-1-0-0", map[string]interface{}{"Name_text": "Alice", "Phones_count": float64(2)}))
+	assert.NoError(t, sink.Close())
+	var phones int64
+	err = db.QueryRow(`SELECT "Phones_count" FROM "test" WHERE "_id" = ?`, "task-1-0-0").Scan(&phones)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), phones)
+
+	//columns of other types are not altered
+	_, err = db.Exec(`CREATE TABLE "other" ("_id" TEXT PRIMARY KEY, "Phones_count" TEXT)`)
+	assert.NoError(t, err)
+	sink, err = newSink(SinkConfig{Name: "results"}, "other")
+	assert.NoError(t, err)
+	err = sink.Open(columnarScraper)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "Schema mismatch")
+	}
+}
@@ -1,7 +1,7 @@
 package scrape
 
 import (
-	"io"
+	"regexp"
 	"sync"
 	"time"
 
@@ -19,6 +19,20 @@ type details struct {
 	Fields    []Field    `json:"fields"`
 	Paginator *paginator `json:"paginator"`
 	IsPath    bool       `json:"path"`
+	//Capture takes a screenshot or PDF of every details page with Chrome fetcher. Storage key of the artifact is returned in <field>_capture field of the block.
+	Capture *fetch.Capture `json:"capture"`
+	//DocumentType of details pages. Document type of the payload is used if it is empty.
+	DocumentType string `json:"documentType"`
+	//BlockSelector of details pages. Blocks are inferred from field selectors if it is empty.
+	BlockSelector string `json:"blockSelector"`
+}
+
+// table turns rows of HTML tables into records in table mode of the payload.
+type table struct {
+	//Selector of tables. The first table inside matching elements is used if they are not tables.
+	Selector string `json:"selector"`
+	//Columns select and rename columns of the table. Records are keyed by headers of the table if it is empty.
+	Columns []extract.TableColumn `json:"columns"`
 }
 
 // paginator is used to scrape multiple pages.
@@ -39,7 +53,7 @@ type paginator struct {
 
 // Extractor type represents Extractor types available for scraping.
 // Here is the list of Extractor types are currently supported:
-// text, html, outerHtml, attr, link, image, regex, const, count
+// text, html, outerHtml, attr, link, image, regex, const, count, number, price, date, boolean, object, list, table
 // Find more actual information in docs/extractors.md
 type Extractor struct {
 	Types []string `json:"types"`
@@ -58,6 +72,8 @@ type Field struct {
 	Extractor Extractor `json:"extractor"`
 	//Details is an optional field strictly for Link extractor type. It guides scraper to parse additional pages following the links according to the set of fields specified inside "details"
 	Details *details `json:"details"`
+	//Fields are sub-fields of "object" and "list" extractor types. Their selectors are relative to elements matching the field selector.
+	Fields []Field `json:"fields"`
 }
 
 // Payload structure contain information and rules to be passed to a scraper
@@ -76,7 +92,7 @@ type Payload struct {
 	//Set up it to either `base` or `chrome` values
 	//If FetcherType is omitted the value of FETCHER_TYPE of parse.d service is used by default.
 	//FetcherType string `json:"fetcherType"`
-	//Format represents output format (CSV, JSON, XML)
+	//Format represents output format (CSV, JSON, JSONL, XML, Parquet, Avro)
 	Format string `json:"format"`
 	//Paginator is used to scrape multiple pages.
 	//If Paginator is nil, then no pagination is performed and it is assumed that the initial URL is the only page.
@@ -89,24 +105,68 @@ type Payload struct {
 	//
 	// Combined list of results is always returned for CSV format.
 	PaginateResults *bool `json:"paginateResults"`
+	//IndexResults adds "_page" and "_block" indexes to every record.
+	//It is applicable for JSONL output format.
+	IndexResults bool `json:"indexResults"`
 	//FetchDelay should be used for a scraper to throttle the crawling speed to avoid hitting the web servers too frequently.
 	//FetchDelay specifies sleep time for multiple requests for the same domain. It is equal to FetchDelay * random value between 500 and 1500 msec
+	//Crawl-delay directive of robots.txt is used if it is longer than FetchDelay.
 	FetchDelay *time.Duration
 	//Some web sites track  statistically significant similarities in the time between requests to them. RandomizeCrawlDelay setting decreases the chance of a crawler being blocked by such sites. This way a random delay ranging from 0.5  CrawlDelay to 1.5  CrawlDelay seconds is used between consecutive requests to the same domain. If CrawlDelay is zero (default) this option has no effect.
 	RandomizeFetchDelay *bool
+	//HostConcurrency is a maximum number of simultaneous requests to the same host.
+	//If HostConcurrency is zero the value of HOST_CONCURRENCY of parse.d service is used.
+	HostConcurrency int `json:"hostConcurrency"`
+	//UserAgents is a pool of User-Agent strings which are rotated per request. Request.UserAgent takes precedence over the pool.
+	//If UserAgents is empty the value of USER_AGENTS of parse.d service is used. DataflowKitBot agent is sent if no agents are specified.
+	UserAgents []string `json:"userAgents"`
 	//Maximum number of times to retry, in addition to the first download.
-	//RETRY_HTTP_CODES
-	//Default: [500, 502, 503, 504, 408]
-	//Failed pages should be rescheduled for download at the end. once the spider has finished crawling all other (non failed) pages.
+	//Failed pages are rescheduled for download at the end once the spider has finished crawling all other (non failed) pages.
+	//Zero value means failed pages are not retried.
 	RetryTimes int `json:"retryTimes"`
+	//RetryHTTPCodes are HTTP status codes of failed fetches which are retried. Network errors are always retried.
+	//Default: [500, 502, 503, 504, 408]
+	RetryHTTPCodes []int `json:"retryHTTPCodes"`
+	//RetryDelay is a delay in milliseconds before the first retry. It is doubled for every next retry and randomized between 0.5 * delay and 1.5 * delay.
+	//If RetryDelay is zero the value of RETRY_DELAY of parse.d service is used.
+	RetryDelay int `json:"retryDelay"`
+	//Timeout is a maximum time in seconds for the whole task to be completed.
+	//Pages which are not fetched before deadline are skipped. Results parsed so far are returned along with errs.Canceled error.
+	//Zero value means no deadline.
+	Timeout int `json:"timeout"`
+	//Sink is an optional output sink. Every parsed block is written to the sink as soon as it is saved to intermediate storage.
+	//Results are encoded to Format as usual.
+	Sink *SinkConfig `json:"sink"`
+	//XHR is a regular expression of URL of XHR or fetch responses captured by Chrome fetcher. Fields are extracted from JSON bodies of matching responses instead of the DOM.
+	//Field selectors are paths like "products.#.name". Blocks are elements of the array which all the field paths go through.
+	XHR string `json:"xhr"`
+	//DocumentType of fetched pages. It may be html, json or xml. Default value is html.
+	//Field selectors of JSON documents are paths like "$.products[*].name". Blocks are elements of the array which all the field paths go through. Paginator selector is a path to the URL of the next page.
+	DocumentType string `json:"documentType"`
+	//BlockSelector makes a block of every matching element. Field selectors are relative to the blocks.
+	//If BlockSelector is empty, blocks are the closest common ancestors of elements matching field selectors.
+	//Blocks of JSON documents are values of the path, f.e. "$.products[*]".
+	BlockSelector string `json:"blockSelector"`
+	//Table turns every row of matching tables into a record. Fields are not used in table mode.
+	Table *table `json:"table"`
 	// ContainPath means that one of the field just a path and we have to ignore all other fields (if present)
 	// that are not a path
 	IsPath bool `json:"path"`
 }
 
+// SinkConfig describes output sink which receives parsed blocks during a scrape.
+type SinkConfig struct {
+	//Name of the sink configured in SINKS of parse.d. Type and data source name of the sink are never taken from payload.
+	Name string `json:"name"`
+	//Table is a name of the table for blocks. Details are written to the tables named Table_<field>_<type>_details.
+	//Collection name is used by default.
+	Table string `json:"table"`
+}
+
 // The DividePageFunc type is used to extract a page's blocks during a scrape.
+// Selector or inferred path which the page was divided by is returned along with the blocks. It is reported in BlockPaths of the task unless it is empty.
 // For more information, please see the documentation on the ScrapeConfig type.
-type DividePageFunc func(*goquery.Selection) []*goquery.Selection
+type DividePageFunc func(*goquery.Selection) ([]*goquery.Selection, string)
 
 // A Part represents a given chunk of data that is to be extracted from every
 // block in each page of a scrape.
@@ -121,6 +181,12 @@ type Part struct {
 	// Extractor contains the logic on how to extract some results from the
 	// selector that is provided to this Piece.
 	Extractor extract.Extractor
+	//Filters are applied in order to the extracted results.
+	Filters []extract.Filter
+	//Parts of object and list fields are extracted from every element matching Selector. Extractor is nil for such parts.
+	Parts []Part
+	//List is true if objects of all matching elements are extracted. Only the first object is extracted otherwise.
+	List bool
 	//Details is an optional field strictly for Link extractor type. It guides scraper to parse additional pages following the links according to the set of fields specified inside "details"
 	Details Scraper
 }
@@ -128,6 +194,12 @@ type Part struct {
 //Scraper struct consolidates settings for scraping task.
 type Scraper struct {
 	Request fetch.Request
+	//Capture is requested for every details page fetched by the scraper.
+	Capture *fetch.Capture
+	//XHR matches URLs of captured XHR responses which are scraped instead of the DOM.
+	XHR *regexp.Regexp
+	//DocumentType of pages fetched by the scraper.
+	DocumentType string
 	// Paginator is the Paginator to use for this current scrape.
 	//
 	// If Paginator is nil, then no pagination is performed and it is assumed that
@@ -142,6 +214,8 @@ type Scraper struct {
 	// page is assumed to contain a single block containing the entire <body>
 	// tag.
 	DividePage DividePageFunc
+	//BlockSelector is a path of blocks of JSON documents. Other pages are divided by DividePage.
+	BlockSelector string
 
 	// Parts contains the list of data that is extracted for each block.  For
 	// every block that is the result of the DividePage function (above), all of
@@ -153,6 +227,8 @@ type Scraper struct {
 	// being aborted - this can be useful if you need to ensure that a given Part
 	// is required, for example.
 	Parts []Part
+	//Table extracts records from tables in table mode. Every record is saved as a block.
+	Table *Part
 	//Opts contains options that are used during the progress of a
 	// scrape.
 	//Opts ScrapeOptions
@@ -186,14 +262,43 @@ type Task struct {
 	Parsed bool
 	// Block counter
 	BlockCounter []int
+	// Status is one of TaskQueued, TaskRunning, TaskFinished, TaskFailed
+	Status string
+	// Pages is a number of fetched pages
+	Pages int
+	// Blocks is a number of blocks written to storage
+	Blocks int
+	// BlockPaths are block selectors and inferred paths which pages were divided by
+	BlockPaths []string
+	// Result is a name of encoded results file
+	Result string
 	// storage using to write result into corresponding storage type
 	storage storage.Store
-	mx      *sync.Mutex
+	// sink receives parsed blocks if output sink is specified in payload
+	sink Sink
+	// uid is a key of top level blocks in storage
+	uid string
+	// scheduler dispatches fetch requests to per host queues
+	scheduler *scheduler
+	// userAgents rotates User-Agent of requests
+	userAgents *fetch.UserAgentPool
+	// retries are failed fetches which are re-queued when the rest of the crawl is finished
+	retries []*taskWorker
+	// sinkPending are blocks with details which wait for retries before they are written to the sink
+	sinkPending []sinkBlock
+	mx          *sync.Mutex
+	finished    time.Time
+	// infoMx serializes writes of the task state to storage
+	infoMx *sync.Mutex
+	// alive is true if the state of the task is refreshed by heartbeat
+	alive bool
 }
 
 type worker struct {
 	wg      *sync.WaitGroup
 	scraper *Scraper
+	//baseURL is the final URL of the fetched page. Relative links are resolved against it.
+	baseURL string
 }
 
 type taskWorker struct {
@@ -204,18 +309,30 @@ type taskWorker struct {
 	mx              *sync.Mutex
 	useBlockCounter bool
 	keys            map[int][]int
+	//attempt is a number of failed fetches of the page
+	attempt int
+	//details is true if details page is scraped
+	details bool
+	//artifact is a storage key of the screenshot or PDF captured from the page
+	artifact string
 }
 
 type blockStruct struct {
-	blockSelection  *goquery.Selection
+	blockSelection *goquery.Selection
+	//value is a block of JSON document. jsonPrefix is a number of path segments leading to the block.
+	value           interface{}
+	jsonPrefix      int
+	isJSON          bool
 	key             string
 	hash            string
 	useBlockCounter bool
 	keys            *map[int][]int
+	//record is a row of a table which is saved as is
+	record map[string]interface{}
 }
 
 type fetchInfo struct {
-	result  chan<- io.ReadCloser
+	result  chan<- *fetch.Response
 	request fetch.Request
 	err     chan<- error
 }
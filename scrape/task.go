@@ -0,0 +1,199 @@
+package scrape
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/slotix/dataflowkit/errs"
+	"github.com/slotix/dataflowkit/storage"
+	"github.com/spf13/viper"
+)
+
+// Task statuses reported by TaskInfo
+const (
+	//TaskQueued means task has been accepted but parsing is not started yet
+	TaskQueued = "queued"
+	//TaskRunning means pages are being fetched and parsed
+	TaskRunning = "running"
+	//TaskFinished means results are encoded and ready for download
+	TaskFinished = "finished"
+	//TaskFailed means task stopped with an error. See TaskInfo.Errors for details
+	TaskFailed = "failed"
+)
+
+// taskLease is the time queued and running tasks are considered alive after their state was saved.
+// The process running the task refreshes the state every third of the lease. Tasks which state is older than the lease were interrupted.
+var taskLease = time.Minute
+
+// TaskInfo represents task state which is kept in storage. It is used for polling task status and getting results of asynchronous tasks.
+type TaskInfo struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+	//Format is an output format of results file
+	Format string `json:"format"`
+	//Pages is a number of fetched pages including details pages
+	Pages int `json:"pages"`
+	//Blocks is a number of blocks parsed and stored so far
+	Blocks int `json:"blocks"`
+	//BlockPaths are block selectors and paths of blocks inferred from field selectors which pages were divided by
+	BlockPaths []string `json:"blockPaths,omitempty"`
+	//Errors contains all the errors collected during a scrape
+	Errors []string `json:"errors,omitempty"`
+	//Result is a name of encoded results file. It is set when task is finished.
+	Result   string     `json:"result,omitempty"`
+	Started  time.Time  `json:"started"`
+	Finished *time.Time `json:"finished,omitempty"`
+	//Updated is the time the state was saved last time. It is refreshed periodically while task is queued or running.
+	Updated time.Time `json:"updated"`
+}
+
+// Validate checks if task payload is correct without fetching any page.
+func (task *Task) Validate() error {
+	if task.Payload.Sink != nil {
+		if _, _, err := sinkDSN(task.Payload.Sink.Name); err != nil {
+			return &errs.BadPayload{ParserError: err.Error()}
+		}
+	}
+	_, err := task.Payload.newScraper()
+	return err
+}
+
+// Queue sets task status to TaskQueued and saves it to storage so that task may be polled before parsing is started.
+func (task *Task) Queue() error {
+	return task.setStatus(TaskQueued)
+}
+
+// Info returns the current state of the task.
+func (task *Task) Info() TaskInfo {
+	task.mx.Lock()
+	defer task.mx.Unlock()
+	info := TaskInfo{
+		ID:         task.ID,
+		Status:     task.Status,
+		Format:     task.Payload.Format,
+		Pages:      task.Pages,
+		Blocks:     task.Blocks,
+		BlockPaths: task.BlockPaths,
+		Result:     task.Result,
+		Updated:    time.Now(),
+	}
+	for _, err := range task.Errors {
+		info.Errors = append(info.Errors, err.Error())
+	}
+	if start, err := task.startTime(); err == nil {
+		info.Started = *start
+	}
+	if !task.finished.IsZero() {
+		finished := task.finished
+		info.Finished = &finished
+	}
+	return info
+}
+
+// addBlockPath reports block selector or inferred path of blocks which the page was divided by. Every path is reported once.
+func (task *Task) addBlockPath(path string) {
+	if path == "" {
+		return
+	}
+	task.mx.Lock()
+	defer task.mx.Unlock()
+	for _, p := range task.BlockPaths {
+		if p == path {
+			return
+		}
+	}
+	task.BlockPaths = append(task.BlockPaths, path)
+}
+
+func (task *Task) setStatus(status string) error {
+	task.mx.Lock()
+	task.Status = status
+	if status == TaskFinished || status == TaskFailed {
+		task.finished = time.Now()
+	}
+	heartbeat := !task.alive && (status == TaskQueued || status == TaskRunning)
+	task.alive = task.alive || heartbeat
+	task.mx.Unlock()
+	if heartbeat {
+		go task.heartbeat(taskLease / 3)
+	}
+	return task.saveInfo()
+}
+
+// heartbeat refreshes the state of the queued or running task in storage, so its lease doesn't expire. It returns when the task is finished or failed.
+func (task *Task) heartbeat(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if !task.refresh() {
+			return
+		}
+	}
+}
+
+// refresh saves the state of the task unless it is finished or failed. It returns false if the task is done.
+func (task *Task) refresh() bool {
+	task.infoMx.Lock()
+	defer task.infoMx.Unlock()
+	info := task.Info()
+	if info.Status != TaskQueued && info.Status != TaskRunning {
+		return false
+	}
+	if err := task.writeInfo(info); err != nil {
+		logger.Error(err)
+	}
+	return true
+}
+
+// saveInfo writes task state to storage.
+func (task *Task) saveInfo() error {
+	//states are written in order, so the state saved by heartbeat doesn't overwrite the newer one
+	task.infoMx.Lock()
+	defer task.infoMx.Unlock()
+	return task.writeInfo(task.Info())
+}
+
+func (task *Task) writeInfo(info TaskInfo) error {
+	j, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	err = task.storage.Write(storage.Record{
+		Type:    storage.TASK,
+		Key:     task.ID,
+		Value:   j,
+		ExpTime: 0,
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to write task %s state. %s", task.ID, err.Error())
+	}
+	return nil
+}
+
+// LoadTaskInfo reads state of the task with specified ID from storage.
+func LoadTaskInfo(id string) (*TaskInfo, error) {
+	s := storage.NewStore(viper.GetString("STORAGE_TYPE"))
+	defer s.Close()
+	j, err := s.Read(storage.Record{
+		Type: storage.TASK,
+		Key:  id,
+	})
+	if err != nil || len(j) == 0 {
+		return nil, &errs.NotFound{URL: "task " + id}
+	}
+	info := &TaskInfo{}
+	err = json.Unmarshal(j, info)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read task %s state. %s", id, err.Error())
+	}
+	//state of the task is not refreshed if parse.d running it was stopped. Such task will never be finished.
+	//The state is not written back, as the task may be owned by another parse.d instance
+	if (info.Status == TaskQueued || info.Status == TaskRunning) && time.Since(info.Updated) > taskLease {
+		finished := info.Updated.Add(taskLease)
+		info.Status = TaskFailed
+		info.Finished = &finished
+		info.Errors = append(info.Errors, "Task was interrupted. Its state has not been updated since "+info.Updated.Format(time.RFC3339))
+	}
+	return info, nil
+}
@@ -0,0 +1,87 @@
+package scrape
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/slotix/dataflowkit/errs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTaskInfo(t *testing.T) {
+	os.RemoveAll("./diskv")
+	task := NewTask(Payload{
+		Name:   "task info",
+		Format: "json",
+	})
+	assert.Equal(t, "", task.Status)
+
+	err := task.Queue()
+	assert.NoError(t, err)
+	info, err := LoadTaskInfo(task.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, task.ID, info.ID)
+	assert.Equal(t, TaskQueued, info.Status)
+	assert.Equal(t, "json", info.Format)
+	assert.Nil(t, info.Finished)
+
+	task.Pages = 2
+	task.Blocks = 10
+	task.addBlockPath(".product")
+	task.addBlockPath(".product")
+	task.addBlockPath("")
+	task.Errors = append(task.Errors, errors.New("some error"))
+	err = task.setStatus(TaskFailed)
+	assert.NoError(t, err)
+	info, err = LoadTaskInfo(task.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, TaskFailed, info.Status)
+	assert.Equal(t, 2, info.Pages)
+	assert.Equal(t, 10, info.Blocks)
+	assert.Equal(t, []string{".product"}, info.BlockPaths)
+	assert.Equal(t, []string{"some error"}, info.Errors)
+	assert.NotNil(t, info.Finished)
+
+	_, err = LoadTaskInfo("unknownTask")
+	assert.IsType(t, &errs.NotFound{}, err)
+
+	//Invalid payload - no fields
+	err = task.Validate()
+	assert.Error(t, err)
+
+	//state of running task is refreshed by heartbeat
+	lease := taskLease
+	taskLease = 60 * time.Millisecond
+	defer func() { taskLease = lease }()
+	task = NewTask(Payload{Name: "task lease"})
+	err = task.setStatus(TaskRunning)
+	assert.NoError(t, err)
+	time.Sleep(150 * time.Millisecond)
+	info, err = LoadTaskInfo(task.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, TaskRunning, info.Status)
+	err = task.setStatus(TaskFinished)
+	assert.NoError(t, err)
+	info, err = LoadTaskInfo(task.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, TaskFinished, info.Status)
+
+	//running task of the stopped parse.d is failed when its lease expires, but its state is not changed in storage
+	task = NewTask(Payload{Name: "task lease"})
+	task.Status = TaskRunning
+	err = task.saveInfo()
+	assert.NoError(t, err)
+	time.Sleep(100 * time.Millisecond)
+	info, err = LoadTaskInfo(task.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, TaskFailed, info.Status)
+	assert.NotNil(t, info.Finished)
+	err = task.saveInfo()
+	assert.NoError(t, err)
+	info, err = LoadTaskInfo(task.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, TaskRunning, info.Status)
+	os.RemoveAll("./diskv")
+}
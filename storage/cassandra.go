@@ -14,8 +14,8 @@ type cassandra struct {
 }
 
 const (
-	readQuery                      = "SELECT value from %s WHERE key='%s'"
-	writeQuery                     = "INSERT INTO %s (key, value) VALUES ('%s', '%s') USING TTL %d"
+	readQuery                      = "SELECT value from %s WHERE key=?"
+	writeQuery                     = "INSERT INTO %s (key, value) VALUES (?, ?) USING TTL %d"
 	writeIntermediateQuery         = "INSERT INTO Intermediate (payloadHash, pageID, blockID, fields) VALUES(?, ?, ?, ?) USING TTL %d"
 	writeIntermediateMapQuery      = "INSERT INTO intermediatemaps (payloadHash, map) VALUES(?, ?) USING TTL %d"
 	readIntermediateResultQuery    = "SELECT fields FROM Intermediate WHERE payloadhash=? AND pageID=? AND blockID=?"
@@ -25,6 +25,9 @@ const (
 	deleteIntermediateMapsRowQuery = "DELETE FROM intermediatemaps WHERE payloadhash=?"
 	deleteCacheRowQuery            = "DELETE FROM cache WHERE key=?"
 	deleteCookiesRowQuery          = "DELETE FROM cookies WHERE key=?"
+	deleteArtifactRowQuery         = "DELETE FROM artifact WHERE key=?"
+	readArtifactQuery              = "SELECT value FROM artifact WHERE key=?"
+	writeArtifactQuery             = "INSERT INTO artifact (key, value) VALUES (?, ?) USING TTL %d"
 	getTTLQuery                    = "SELECT TTL(%s) from %s"
 )
 
@@ -44,9 +47,14 @@ func (c cassandra) Read(rec Record) (value []byte, err error) {
 	if rec.Type == INTERMEDIATE {
 		return c.readIntermediate(rec.Key)
 	}
+	//artifacts are binary so they are kept in blob column
+	if rec.Type == ARTIFACT {
+		err = c.session.Query(readArtifactQuery, rec.Key).Scan(&value)
+		return value, err
+	}
 	var val string
-	query := fmt.Sprintf(readQuery, rec.Type, rec.Key)
-	err = c.session.Query(query).Scan(&val)
+	query := fmt.Sprintf(readQuery, rec.Type)
+	err = c.session.Query(query, rec.Key).Scan(&val)
 	return []byte(val), err
 }
 
@@ -55,8 +63,12 @@ func (c cassandra) Write(rec Record) error {
 	if rec.Type == INTERMEDIATE {
 		return c.writeIntermediate(rec.Key, rec.Value, rec.ExpTime)
 	}
-	query := fmt.Sprintf(writeQuery, rec.Type, rec.Key, string(rec.Value), rec.ExpTime)
-	err := c.session.Query(query).Exec()
+	if rec.Type == ARTIFACT {
+		return c.session.Query(fmt.Sprintf(writeArtifactQuery, rec.ExpTime), rec.Key, rec.Value).Exec()
+	}
+	//values like cached pages and task errors may contain quotes, so they are bound rather than formatted into the query
+	query := fmt.Sprintf(writeQuery, rec.Type, rec.ExpTime)
+	err := c.session.Query(query, rec.Key, string(rec.Value)).Exec()
 	return err
 }
 
@@ -160,6 +172,8 @@ func (c cassandra) Delete(rec Record) error {
 		// if err != nil {
 		// 	logger.Warningf("Failed delete cookies row: key %s. %s", keys[0], err.Error())
 		// }
+	case ARTIFACT:
+		_ = c.session.Query(deleteArtifactRowQuery, rec.Key).Exec()
 	}
 	return nil
 }
@@ -187,6 +201,8 @@ func (c cassandra) DeleteAll() error {
 	// if err != nil {
 	// 	logger.Warningf("Failed truncate cookies table. %s", err.Error())
 	// }
+	query = fmt.Sprintf(truncateTableQuery, "artifact")
+	_ = c.session.Query(query).Exec()
 	return nil
 }
 
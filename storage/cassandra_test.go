@@ -48,6 +48,17 @@ func Test_cassandra(t *testing.T) {
 		Key:  rec.Key,
 	})
 	assert.Equal(t, testValue, value, "Expected equal")
+	//values with quotes are stored as is
+	task := Record{
+		Type:  TASK,
+		Key:   "task'1",
+		Value: []byte(`{"errors":["Can't fetch 'http://example.com'"]}`),
+	}
+	err = c.Write(task)
+	assert.NoError(t, err)
+	value, err = c.Read(task)
+	assert.NoError(t, err)
+	assert.Equal(t, task.Value, value)
 	//read records
 	for _, r := range recs {
 		value, err := c.Read(r)
@@ -20,6 +20,8 @@ const (
 	CACHE        = "Cache"
 	COOKIES      = "Cookies"
 	INTERMEDIATE = "Intermediate"
+	TASK         = "Task"
+	ARTIFACT     = "Artifact"
 )
 
 // Record struct keeps Key/Value and expiration time of specified type
@@ -39,7 +41,7 @@ type Store interface {
 	//expTime set Metadata Expires value for S3Storage
 	Write(rec Record) error
 	//Is key expired ? It checks if parse results storage item is expired. Set up  Expiration as "ITEM_EXPIRE_IN" environment variable.
-	//html pages cache stores this info in sResponse.Expires . It is not used for fetch endpoint.
+	//Fetch cache evicts pages expired in storage. Freshness of cached pages is calculated from their caching headers.
 	Expired(rec Record) bool
 	//Delete deletes specified item from the store
 	Delete(rec Record) error
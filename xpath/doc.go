@@ -0,0 +1,24 @@
+// Dataflow kit - xpath
+//
+// Copyright © 2017-2018 Slotix s.r.o. <dm@slotix.sk>
+//
+//
+// All rights reserved. Use of this source code is governed
+// by the BSD 3-Clause License license.
+
+// Package xpath of the Dataflow kit evaluates XPath 1.0 expressions against html.Node trees parsed for goquery.
+// Expressions are evaluated by github.com/antchfx/xpath, this package adapts them to goquery selections.
+//
+// Selectors of fields, paginators and details are XPath expressions if they start with "xpath:" prefix.
+// Otherwise they are CSS selectors passed to goquery as usual:
+//
+// "selector":"xpath://h2[contains(text(), 'Price')]/following-sibling::span[1]"
+//
+// Functions lang() and id() are not supported. Element and attribute names are matched in lower case in HTML documents, as HTML parser lowercases them.
+// Names are case-sensitive in XML documents.
+//
+// Attributes, text nodes and results of expressions which are not node-sets like "xpath:normalize-space(h1)" are returned as text nodes, so they are extracted with text extractor.
+//
+package xpath
+
+// EOF
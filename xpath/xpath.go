@@ -0,0 +1,179 @@
+package xpath
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
+	"github.com/antchfx/htmlquery"
+	"github.com/antchfx/xpath"
+	"golang.org/x/net/html"
+)
+
+// Prefix marks selectors which are XPath expressions.
+const Prefix = "xpath:"
+
+// IsXPath checks if selector has XPath prefix.
+func IsXPath(selector string) bool {
+	return strings.HasPrefix(selector, Prefix)
+}
+
+// Validate checks syntax of XPath or CSS selector. isHTML is true if the selector is used on HTML documents.
+func Validate(selector string, isHTML bool) error {
+	if !IsXPath(selector) {
+		if _, err := cascadia.Compile(selector); err != nil {
+			return fmt.Errorf("Invalid CSS selector %s. %s", selector, err.Error())
+		}
+		return nil
+	}
+	_, err := compile(selector, isHTML)
+	return err
+}
+
+// compile parses XPath selector. Compiled expressions keep the state of evaluation, so they are not shared between goroutines.
+// Names are lowercased in expressions for HTML documents only. Names of XML elements and attributes are case-sensitive.
+func compile(selector string, isHTML bool) (e *xpath.Expr, err error) {
+	s := strings.TrimPrefix(selector, Prefix)
+	//parser of antchfx/xpath panics on some malformed expressions
+	defer func() {
+		if r := recover(); r != nil {
+			e, err = nil, fmt.Errorf("Invalid XPath %s. %v", s, r)
+		}
+	}()
+	expr := s
+	if isHTML {
+		expr = lowerNames(s)
+	}
+	e, err = xpath.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid XPath %s. %s", s, err.Error())
+	}
+	return e, nil
+}
+
+// lowerNames converts the expression to lower case except for string literals, as HTML parser lowercases element and attribute names.
+func lowerNames(s string) string {
+	b := []byte(s)
+	var quote byte
+	for i, c := range b {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case 'A' <= c && c <= 'Z':
+			b[i] = c + 'a' - 'A'
+		}
+	}
+	return string(b)
+}
+
+// Find returns nodes matching selector within the selection. Selectors without XPath prefix are passed to goquery Find.
+// XPath expression is evaluated for every node of the selection. The node is the root of the expression, so absolute paths like "//h2" are restricted to its descendants as CSS selectors are.
+// Attributes and results of expressions which are not node-sets are returned as text nodes. Empty selection is returned if selector is invalid.
+// isHTML is true if the selection belongs to HTML document. XPath expressions are case-sensitive for XML documents.
+func Find(sel *goquery.Selection, selector string, isHTML bool) *goquery.Selection {
+	if !IsXPath(selector) {
+		return sel.Find(selector)
+	}
+	nodes := []*html.Node{}
+	e, err := compile(selector, isHTML)
+	if err == nil {
+		for _, n := range sel.Nodes {
+			nodes = append(nodes, evaluate(e, n)...)
+		}
+	}
+	//FilterNodes without nodes returns an empty selection which does not share nodes with sel
+	return sel.FilterNodes().AddNodes(nodes...)
+}
+
+// evaluate evaluates the expression with n as the root. Attribute nodes are converted to text nodes whose parent is the element of the attribute.
+func evaluate(e *xpath.Expr, n *html.Node) []*html.Node {
+	switch v := e.Evaluate(htmlquery.CreateXPathNavigator(n)).(type) {
+	case *xpath.NodeIterator:
+		nodes := []*html.Node{}
+		for v.MoveNext() {
+			nav := v.Current().(*htmlquery.NodeNavigator)
+			if nav.NodeType() == xpath.AttributeNode {
+				nodes = append(nodes, &html.Node{Type: html.TextNode, Data: nav.Value(), Parent: nav.Current()})
+				continue
+			}
+			nodes = append(nodes, nav.Current())
+		}
+		//results of unions and reverse axes are not ordered by antchfx/xpath
+		sort.SliceStable(nodes, func(i, j int) bool {
+			return before(nodes[i], nodes[j])
+		})
+		return nodes
+	case string:
+		return textNode(v, n)
+	case bool:
+		return textNode(strconv.FormatBool(v), n)
+	case float64:
+		return textNode(formatNumber(v), n)
+	}
+	return nil
+}
+
+// textNode returns the result of expression as a text node unless it is empty.
+func textNode(s string, parent *html.Node) []*html.Node {
+	if s == "" {
+		return nil
+	}
+	return []*html.Node{{Type: html.TextNode, Data: s, Parent: parent}}
+}
+
+// formatNumber converts a number to string as XPath string() function does.
+func formatNumber(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// before checks if node a precedes node b in document order. Text nodes of attributes precede children of their elements.
+func before(a, b *html.Node) bool {
+	pa, pb := ancestors(a), ancestors(b)
+	i := 0
+	for i < len(pa) && i < len(pb) && pa[i] == pb[i] {
+		i++
+	}
+	if i == len(pa) || i == len(pb) {
+		return len(pa) < len(pb)
+	}
+	x, y := pa[i], pb[i]
+	if detached(x) || detached(y) {
+		return !detached(y)
+	}
+	for s := x.NextSibling; s != nil; s = s.NextSibling {
+		if s == y {
+			return true
+		}
+	}
+	return false
+}
+
+// ancestors returns the path from the root of the document to n.
+func ancestors(n *html.Node) []*html.Node {
+	path := []*html.Node{}
+	for ; n != nil; n = n.Parent {
+		path = append([]*html.Node{n}, path...)
+	}
+	return path
+}
+
+// detached checks if n is not a child of its parent, like text nodes made of attributes.
+func detached(n *html.Node) bool {
+	return n.Parent != nil && n.PrevSibling == nil && n.Parent.FirstChild != n
+}
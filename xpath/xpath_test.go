@@ -0,0 +1,116 @@
+package xpath
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/html"
+)
+
+const page = `<html><body>
+<div class="item" id="a"><h2>Book</h2><span class="label">Price:</span><span>12.50</span><a href="/book">more</a></div>
+<div class="item" id="b"><h2>Pen</h2><span class="label">Price:</span><span>2</span><a href="/pen">more</a></div>
+<div class="item" id="c"><h2> Blue  pencil </h2><!-- sold out --></div>
+<a rel="next" href="?page=2">Next</a>
+</body></html>`
+
+func texts(t *testing.T, doc *goquery.Document, selector string) []string {
+	assert.NoError(t, Validate(selector, true), selector)
+	result := []string{}
+	Find(doc.Selection, selector, true).Each(func(i int, s *goquery.Selection) {
+		result = append(result, s.Text())
+	})
+	return result
+}
+
+func TestFind(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(page))
+	assert.NoError(t, err)
+	for selector, expected := range map[string][]string{
+		"xpath://h2":                     {"Book", "Pen", " Blue  pencil "},
+		"xpath:/html/body/div[2]/h2":     {"Pen"},
+		"xpath://div[@id='b']/h2/text()": {"Pen"},
+		"xpath://div/@id":                {"a", "b", "c"},
+		"xpath://span[text()='Price:']/following-sibling::span[1]":      {"12.50", "2"},
+		"xpath://span[contains(@class, 'label')]/preceding-sibling::h2": {"Book", "Pen"},
+		"xpath://div[last()]/h2":                                {" Blue  pencil "},
+		"xpath://div[position() < 3]/@id":                       {"a", "b"},
+		"xpath://div[span[2] > 10]/h2":                          {"Book"},
+		"xpath://div[not(a)]/@id":                               {"c"},
+		"xpath://h2 | //a[@rel]":                                {"Book", "Pen", " Blue  pencil ", "Next"},
+		"xpath:(//a)[last()]/@href":                             {"?page=2"},
+		"xpath:normalize-space(//div[3]/h2)":                    {"Blue pencil"},
+		"xpath:count(//div[@class='item'])":                     {"3"},
+		"xpath:concat(//h2, ' ', sum(//span[not(@class)]) * 2)": {"Book 29"},
+		"xpath://h2[starts-with(., 'P')]/ancestor::div/@id":     {"b"},
+		"xpath://div[3]/preceding::h2[1]":                       {"Pen"},
+		"xpath://a[@rel] | //h2":                                {"Book", "Pen", " Blue  pencil ", "Next"},
+		"xpath://comment()/preceding-sibling::h2":               {" Blue  pencil "},
+		"xpath://DIV[@ID='a']/*[1]":                             {"Book"},
+		"xpath:substring(//div[1]/h2, 2, 2)":                    {"oo"},
+		"xpath:translate(//div[2]/h2, 'enP', 'ENp')":            {"pEN"},
+		"xpath://div[1]/following::a/@href":                     {"/pen", "?page=2"},
+		"div.item h2":                                           {"Book", "Pen", " Blue  pencil "},
+	} {
+		assert.Equal(t, expected, texts(t, doc, selector), selector)
+	}
+	assert.Empty(t, texts(t, doc, "xpath:string(//table)"))
+}
+
+func TestFindWithinBlocks(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(page))
+	assert.NoError(t, err)
+	blocks := doc.Find("div.item")
+	//absolute paths are restricted to the block as CSS selectors are, relative paths start at the block
+	assert.Equal(t, "Pen", Find(blocks.Eq(1), "xpath://h2", true).Text())
+	assert.Equal(t, "2", Find(blocks.Eq(1), "xpath:span[2]", true).Text())
+	assert.Equal(t, "/book", Find(blocks.First(), "xpath:.//a/@href", true).Text())
+	assert.Equal(t, 0, Find(blocks.Eq(2), "xpath://a", true).Length())
+	assert.Equal(t, 3, Find(blocks, "xpath:h2", true).Length())
+	//attributes and text nodes are children of their elements
+	assert.Equal(t, "b", Find(blocks, "xpath:@id", true).Eq(1).Text())
+	assert.Equal(t, "div", goquery.NodeName(Find(blocks, "xpath:h2/text()", true).Parent().Parent()))
+}
+
+func TestValidate(t *testing.T) {
+	for _, selector := range []string{
+		"xpath:",
+		"xpath://div[",
+		"xpath://div[@class='item'",
+		"xpath://div/",
+		"xpath:unknown(//div)",
+		"xpath:contains(//div)",
+		"xpath:namespace::x",
+		"xpath:$var",
+		"xpath:'unterminated",
+		"div[",
+		"a >> b",
+		"div:unknown",
+	} {
+		assert.Error(t, Validate(selector, true), selector)
+	}
+	assert.NoError(t, Validate("div > h2", true))
+	assert.NoError(t, Validate("xpath:/", true))
+	assert.NoError(t, Validate("xpath:child::div/descendant-or-self::node()/processing-instruction('x')", true))
+	assert.NoError(t, Validate("xpath://div[@id = 'a' or @id='b' and 3 mod 2 = 1 and -1 < 2 div 1]", true))
+	assert.Equal(t, 0, Find(&goquery.Selection{Nodes: []*html.Node{{Type: html.DocumentNode}}}, "xpath://div[", true).Length())
+}
+
+func TestFindXML(t *testing.T) {
+	//XML documents keep names of elements and attributes as they are
+	root := &html.Node{Type: html.DocumentNode}
+	feed := &html.Node{Type: html.ElementNode, Data: "Feed"}
+	entry := &html.Node{Type: html.ElementNode, Data: "Entry", Attr: []html.Attribute{{Key: "ID", Val: "1"}}}
+	entry.AppendChild(&html.Node{Type: html.TextNode, Data: "First"})
+	feed.AppendChild(entry)
+	root.AppendChild(feed)
+	doc := goquery.NewDocumentFromNode(root)
+
+	assert.NoError(t, Validate("xpath://Feed/Entry", false))
+	assert.Equal(t, "First", Find(doc.Selection, "xpath://Feed/Entry", false).Text())
+	assert.Equal(t, "1", Find(doc.Selection, "xpath://Entry/@ID", false).Text())
+	assert.Equal(t, 0, Find(doc.Selection, "xpath://entry", false).Length())
+	assert.Equal(t, 0, Find(doc.Selection, "xpath://Entry", true).Length())
+}